@@ -1,33 +1,650 @@
+// Package config loads the service configuration and holds the database
+// connection.
+//
+// Settings come from the environment, optionally completed by a file of
+// KEY=value lines named by CONFIG_FILE (default .env); variables already set
+// in the environment win over the file.
 package config
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
+	"golang.org/x/text/language"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
-func LoadEnv() {
-	_ = godotenv.Load()
+// Config is the service configuration.
+type Config struct {
+	// Port is the HTTP port, from PORT.
+	Port string
+	// ShutdownTimeout bounds the drain of requests and workers on shutdown.
+	ShutdownTimeout time.Duration
+	// MigrateOnStart is up, check or off.
+	MigrateOnStart string
+	// JWTSecret verifies user tokens. It is required to serve.
+	JWTSecret string
+	// InternalAPIToken guards the /internal endpoints, which reject every
+	// request when it is empty.
+	InternalAPIToken string
+	// CORSAllowedOrigins lists the browser origins allowed to call the API.
+	CORSAllowedOrigins []string
+	// ExportPseudonymSecret keys the pseudonyms of data exports, from
+	// EXPORT_PSEUDONYM_SECRET. It defaults to JWT_SECRET.
+	ExportPseudonymSecret string
+	Database              DatabaseConfig
+	Tracing               TracingConfig
+	Log                   LogConfig
+	Messages              MessagesConfig
+	Matches               MatchesConfig
+	Attachments           AttachmentsConfig
+	Retention             RetentionConfig
+	Moderation            ModerationConfig
+	Presence              PresenceConfig
 }
 
-func ConnectDB() {
-	LoadEnv()
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
-	user := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
-	dbname := os.Getenv("DB_NAME")
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC", host, user, password, dbname, port)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// MessagesConfig bounds messages, like notes and scheduled messages.
+type MessagesConfig struct {
+	// MaxRunes is the maximum length of a message, from MESSAGE_MAX_RUNES.
+	MaxRunes int
+	// LikeNoteMaxRunes is the maximum length of a like note. Notes become
+	// messages, so it may not exceed MaxRunes.
+	LikeNoteMaxRunes int
+	// IcebreakerDefaultLocale is the icebreaker locale used when the
+	// user's preference matches none of the catalogue.
+	IcebreakerDefaultLocale string
+	// ScheduledMaxAhead is how far ahead a message may be scheduled.
+	ScheduledMaxAhead time.Duration
+	// ScheduledMaxPending is the number of pending scheduled messages
+	// allowed per user.
+	ScheduledMaxPending int64
+	// ScheduledDispatchInterval is the time between two deliveries of due
+	// scheduled messages.
+	ScheduledDispatchInterval time.Duration
+	// ScheduledRetryDelay is the wait after the first transient delivery
+	// failure of a scheduled message; it doubles with every further one.
+	ScheduledRetryDelay time.Duration
+	// ScheduledMaxAttempts is the number of deliveries tried before a
+	// scheduled message is marked failed.
+	ScheduledMaxAttempts int
+}
+
+// MatchesConfig sets how long matches last and how far and how often
+// participants may extend them.
+type MatchesConfig struct {
+	// TTL is how long a new match lasts, from MATCH_TTL; 0 means matches do
+	// not expire, and then cannot be extended or rematched either.
+	TTL               time.Duration
+	ExtensionDuration time.Duration
+	// ExtensionsPerUser is per participant and match; 0 disables extensions.
+	ExtensionsPerUser int64
+}
+
+// AttachmentsConfig sets where attachments are stored and how they are
+// served and cleaned up.
+type AttachmentsConfig struct {
+	StorageDir string
+	MaxBytes   int64
+	// URLTTL is the lifetime of signed download URLs.
+	URLTTL time.Duration
+	// SigningSecret signs download URLs. It defaults to JWT_SECRET.
+	SigningSecret string
+	// OrphanTTL is how long an upload may wait to be sent before it is
+	// deleted.
+	OrphanTTL       time.Duration
+	CleanupInterval time.Duration
+}
+
+// RetentionConfig configures the message purge. A zero age disables the
+// corresponding rule.
+type RetentionConfig struct {
+	// DeletedMessagesAfter is read in days from
+	// RETENTION_DELETED_MESSAGES_DAYS.
+	DeletedMessagesAfter time.Duration
+	// UnmatchedConversationsAfter is read in days from
+	// RETENTION_UNMATCHED_CONVERSATIONS_DAYS.
+	UnmatchedConversationsAfter time.Duration
+	BatchSize                   int
+	PurgeInterval               time.Duration
+	DryRun                      bool
+}
+
+// ModerationConfig configures the built-in moderation filters. Actions are
+// allow, mask, hold or reject.
+type ModerationConfig struct {
+	BlockedWords []string
+	MaskedWords  []string
+	// ContactAction applies to phone numbers, e-mail addresses and payment
+	// handles.
+	ContactAction string
+	// URLAction applies to links.
+	URLAction string
+	// SpamThreshold rejects a message once its sender sent the same text
+	// that many times within SpamWindow; 0 disables the filter.
+	SpamThreshold int
+	SpamWindow    time.Duration
+}
+
+// PresenceConfig sets how long users count as online after their last
+// activity and how long their last-seen time is kept.
+type PresenceConfig struct {
+	OnlineTTL time.Duration
+	Retention time.Duration
+}
+
+// LogConfig selects the level and format of the service logs.
+type LogConfig struct {
+	// Level is debug, info, warn or error.
+	Level string
+	// Format is json or text. It defaults to json in release mode
+	// (GIN_MODE=release) and to text otherwise.
+	Format string
+}
+
+// TracingConfig selects where OpenTelemetry spans are exported.
+type TracingConfig struct {
+	// Exporter is none, stdout (pretty-printed spans, for local use) or otlp
+	// (OTLP over HTTP, configured by the standard OTEL_EXPORTER_OTLP_*
+	// variables).
+	Exporter    string
+	ServiceName string
+	// SampleRatio is the share of new traces recorded; requests whose
+	// caller sampled the trace are always recorded.
+	SampleRatio float64
+}
+
+// DatabaseConfig is the PostgreSQL connection and pool configuration.
+type DatabaseConfig struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+	// SSLMode is a libpq sslmode: disable, allow, prefer, require,
+	// verify-ca or verify-full.
+	SSLMode     string
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// redacted replaces secrets in Redacted.
+const redacted = "[redacted]"
+
+var sslModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true, "require": true, "verify-ca": true, "verify-full": true,
+}
+
+// env reads variables and records the ones that do not parse.
+type env struct{ errs []error }
+
+func (e *env) string(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func (e *env) int(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s must be an integer, not %q", key, v))
+		return def
+	}
+	return n
+}
+
+func (e *env) duration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s must be a duration such as 30s, not %q", key, v))
+		return def
+	}
+	return d
+}
+
+func (e *env) int64(key string, def int64) int64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s must be an integer, not %q", key, v))
+		return def
+	}
+	return n
+}
+
+// days reads a number of days.
+func (e *env) days(key string, def int) time.Duration {
+	return time.Duration(e.int(key, def)) * 24 * time.Hour
+}
+
+func (e *env) bool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s must be true or false, not %q", key, v))
+		return def
+	}
+	return b
+}
+
+func (e *env) float(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s must be a number, not %q", key, v))
+		return def
+	}
+	return f
+}
+
+func (e *env) list(key string, def []string) []string {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Load reads the configuration file, if any, then the environment, and
+// checks the settings every command needs. Use Validate before serving.
+func Load() (*Config, error) {
+	file := os.Getenv("CONFIG_FILE")
+	if file != "" {
+		if err := godotenv.Load(file); err != nil {
+			return nil, fmt.Errorf("reading CONFIG_FILE %s: %w", file, err)
+		}
+	} else {
+		_ = godotenv.Load()
+	}
+	e := &env{}
+	cfg := read(e)
+	errs := append(e.errs, cfg.Database.validate()...)
+	errs = append(errs, cfg.Log.validate()...)
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// FromEnv builds the configuration from the environment alone, with
+// defaults for malformed values. It does not validate.
+func FromEnv() *Config {
+	return read(&env{})
+}
+
+func read(e *env) *Config {
+	jwtSecret := os.Getenv("JWT_SECRET")
+	return &Config{
+		Port:                  e.string("PORT", "8082"),
+		ShutdownTimeout:       e.duration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		MigrateOnStart:        e.string("MIGRATE_ON_START", "up"),
+		JWTSecret:             jwtSecret,
+		InternalAPIToken:      os.Getenv("INTERNAL_API_TOKEN"),
+		CORSAllowedOrigins:    e.list("CORS_ALLOWED_ORIGINS", []string{"http://localhost:8083"}),
+		ExportPseudonymSecret: e.string("EXPORT_PSEUDONYM_SECRET", jwtSecret),
+		Database: DatabaseConfig{
+			Host:            e.string("DB_HOST", "localhost"),
+			Port:            e.string("DB_PORT", "5432"),
+			User:            os.Getenv("DB_USER"),
+			Password:        os.Getenv("DB_PASSWORD"),
+			Name:            os.Getenv("DB_NAME"),
+			SSLMode:         e.string("DB_SSLMODE", "disable"),
+			SSLRootCert:     os.Getenv("DB_SSLROOTCERT"),
+			SSLCert:         os.Getenv("DB_SSLCERT"),
+			SSLKey:          os.Getenv("DB_SSLKEY"),
+			MaxOpenConns:    e.int("DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:    e.int("DB_MAX_IDLE_CONNS", 10),
+			ConnMaxLifetime: e.duration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+			ConnMaxIdleTime: e.duration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+		},
+		Log: LogConfig{
+			Level:  e.string("LOG_LEVEL", "info"),
+			Format: e.string("LOG_FORMAT", defaultLogFormat()),
+		},
+		Tracing: TracingConfig{
+			Exporter:    e.string("TRACING_EXPORTER", "none"),
+			ServiceName: e.string("OTEL_SERVICE_NAME", "way-d-interactions"),
+			SampleRatio: e.float("TRACING_SAMPLE_RATIO", 1),
+		},
+		Messages: MessagesConfig{
+			MaxRunes:                  e.int("MESSAGE_MAX_RUNES", 2000),
+			LikeNoteMaxRunes:          e.int("LIKE_NOTE_MAX_RUNES", 200),
+			IcebreakerDefaultLocale:   e.string("ICEBREAKER_DEFAULT_LOCALE", "en"),
+			ScheduledMaxAhead:         e.duration("SCHEDULED_MESSAGE_MAX_AHEAD", 30*24*time.Hour),
+			ScheduledMaxPending:       e.int64("SCHEDULED_MESSAGE_MAX_PENDING", 50),
+			ScheduledDispatchInterval: e.duration("SCHEDULED_DISPATCH_INTERVAL", 15*time.Second),
+			ScheduledRetryDelay:       e.duration("SCHEDULED_RETRY_DELAY", time.Minute),
+			ScheduledMaxAttempts:      e.int("SCHEDULED_MAX_ATTEMPTS", 10),
+		},
+		Matches: MatchesConfig{
+			TTL:               e.duration("MATCH_TTL", 0),
+			ExtensionDuration: e.duration("MATCH_EXTENSION_DURATION", 24*time.Hour),
+			ExtensionsPerUser: e.int64("MATCH_EXTENSIONS_PER_USER", 1),
+		},
+		Attachments: AttachmentsConfig{
+			StorageDir:      e.string("ATTACHMENT_STORAGE_DIR", filepath.Join("data", "attachments")),
+			MaxBytes:        e.int64("ATTACHMENT_MAX_BYTES", 10<<20),
+			URLTTL:          e.duration("ATTACHMENT_URL_TTL", 15*time.Minute),
+			SigningSecret:   e.string("ATTACHMENT_SIGNING_SECRET", jwtSecret),
+			OrphanTTL:       e.duration("ATTACHMENT_ORPHAN_TTL", 24*time.Hour),
+			CleanupInterval: e.duration("ATTACHMENT_CLEANUP_INTERVAL", time.Hour),
+		},
+		Retention: RetentionConfig{
+			DeletedMessagesAfter:        e.days("RETENTION_DELETED_MESSAGES_DAYS", 30),
+			UnmatchedConversationsAfter: e.days("RETENTION_UNMATCHED_CONVERSATIONS_DAYS", 90),
+			BatchSize:                   e.int("RETENTION_BATCH_SIZE", 500),
+			PurgeInterval:               e.duration("RETENTION_PURGE_INTERVAL", 24*time.Hour),
+			DryRun:                      e.bool("RETENTION_DRY_RUN", false),
+		},
+		Moderation: ModerationConfig{
+			BlockedWords:  e.list("MODERATION_BLOCKED_WORDS", nil),
+			MaskedWords:   e.list("MODERATION_MASKED_WORDS", nil),
+			ContactAction: e.string("MODERATION_CONTACT_ACTION", "mask"),
+			URLAction:     e.string("MODERATION_URL_ACTION", "hold"),
+			SpamThreshold: e.int("MODERATION_SPAM_THRESHOLD", 3),
+			SpamWindow:    e.duration("MODERATION_SPAM_WINDOW", 10*time.Minute),
+		},
+		Presence: PresenceConfig{
+			OnlineTTL: e.duration("PRESENCE_ONLINE_TTL", time.Minute),
+			Retention: e.duration("PRESENCE_RETENTION", 30*24*time.Hour),
+		},
+	}
+}
+
+func (d DatabaseConfig) validate() []error {
+	var errs []error
+	if d.Name == "" {
+		errs = append(errs, errors.New("DB_NAME must be set"))
+	}
+	if d.User == "" {
+		errs = append(errs, errors.New("DB_USER must be set"))
+	}
+	if !sslModes[d.SSLMode] {
+		errs = append(errs, fmt.Errorf("DB_SSLMODE must be disable, allow, prefer, require, verify-ca or verify-full, not %q", d.SSLMode))
+	}
+	if (d.SSLCert == "") != (d.SSLKey == "") {
+		errs = append(errs, errors.New("DB_SSLCERT and DB_SSLKEY must be set together"))
+	}
+	for _, path := range []string{d.SSLRootCert, d.SSLCert, d.SSLKey} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			errs = append(errs, fmt.Errorf("database certificate: %w", err))
+		}
+	}
+	if d.MaxOpenConns < 0 || d.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("DB_MAX_OPEN_CONNS and DB_MAX_IDLE_CONNS must not be negative"))
+	} else if d.MaxOpenConns > 0 && d.MaxIdleConns > d.MaxOpenConns {
+		errs = append(errs, errors.New("DB_MAX_IDLE_CONNS must not exceed DB_MAX_OPEN_CONNS"))
+	}
+	return errs
+}
+
+func defaultLogFormat() string {
+	if os.Getenv("GIN_MODE") == "release" {
+		return "json"
+	}
+	return "text"
+}
+
+func (l LogConfig) validate() []error {
+	var errs []error
+	switch l.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error, not %q", l.Level))
+	}
+	if l.Format != "json" && l.Format != "text" {
+		errs = append(errs, fmt.Errorf("LOG_FORMAT must be json or text, not %q", l.Format))
+	}
+	return errs
+}
+
+// Validate checks the settings needed to serve requests.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.JWTSecret == "" {
+		errs = append(errs, errors.New("JWT_SECRET must be set"))
+	}
+	if port, err := strconv.Atoi(c.Port); err != nil || port <= 0 || port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT must be a TCP port, not %q", c.Port))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT must be positive"))
+	}
+	switch c.MigrateOnStart {
+	case "up", "check", "off":
+	default:
+		errs = append(errs, fmt.Errorf("MIGRATE_ON_START must be up, check or off, not %q", c.MigrateOnStart))
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("TRACING_EXPORTER must be none, stdout or otlp, not %q", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("TRACING_SAMPLE_RATIO must be between 0 and 1"))
+	}
+	for _, origin := range c.CORSAllowedOrigins {
+		// Credentials are allowed, so a wildcard origin is not an option.
+		if !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			errs = append(errs, fmt.Errorf("CORS_ALLOWED_ORIGINS: %q is not an http(s) origin", origin))
+		}
+	}
+	if c.ExportPseudonymSecret == "" {
+		errs = append(errs, errors.New("EXPORT_PSEUDONYM_SECRET or JWT_SECRET must be set"))
+	}
+	errs = append(errs, c.Messages.validate()...)
+	errs = append(errs, c.Matches.validate()...)
+	errs = append(errs, c.Attachments.validate()...)
+	errs = append(errs, c.Retention.validate()...)
+	errs = append(errs, c.Moderation.validate()...)
+	errs = append(errs, c.Presence.validate()...)
+	return errors.Join(errs...)
+}
+
+// positive appends an error to errs unless the duration d read from key is
+// positive.
+func positive(errs []error, key string, d time.Duration) []error {
+	if d <= 0 {
+		errs = append(errs, fmt.Errorf("%s must be positive", key))
+	}
+	return errs
+}
+
+func (m MessagesConfig) validate() []error {
+	var errs []error
+	if m.MaxRunes <= 0 {
+		errs = append(errs, errors.New("MESSAGE_MAX_RUNES must be positive"))
+	}
+	if m.LikeNoteMaxRunes <= 0 || m.LikeNoteMaxRunes > m.MaxRunes {
+		errs = append(errs, errors.New("LIKE_NOTE_MAX_RUNES must be positive and at most MESSAGE_MAX_RUNES"))
+	}
+	if _, err := language.Parse(m.IcebreakerDefaultLocale); err != nil {
+		errs = append(errs, fmt.Errorf("ICEBREAKER_DEFAULT_LOCALE must be a language tag such as en, not %q", m.IcebreakerDefaultLocale))
+	}
+	errs = positive(errs, "SCHEDULED_MESSAGE_MAX_AHEAD", m.ScheduledMaxAhead)
+	if m.ScheduledMaxPending <= 0 {
+		errs = append(errs, errors.New("SCHEDULED_MESSAGE_MAX_PENDING must be positive"))
+	}
+	errs = positive(errs, "SCHEDULED_DISPATCH_INTERVAL", m.ScheduledDispatchInterval)
+	errs = positive(errs, "SCHEDULED_RETRY_DELAY", m.ScheduledRetryDelay)
+	if m.ScheduledMaxAttempts <= 0 {
+		errs = append(errs, errors.New("SCHEDULED_MAX_ATTEMPTS must be positive"))
+	}
+	return errs
+}
+
+func (m MatchesConfig) validate() []error {
+	var errs []error
+	if m.TTL < 0 {
+		errs = append(errs, errors.New("MATCH_TTL must not be negative"))
+	}
+	errs = positive(errs, "MATCH_EXTENSION_DURATION", m.ExtensionDuration)
+	if m.ExtensionsPerUser < 0 {
+		errs = append(errs, errors.New("MATCH_EXTENSIONS_PER_USER must not be negative"))
+	}
+	return errs
+}
+
+func (a AttachmentsConfig) validate() []error {
+	var errs []error
+	if a.StorageDir == "" {
+		errs = append(errs, errors.New("ATTACHMENT_STORAGE_DIR must be set"))
+	}
+	if a.MaxBytes <= 0 {
+		errs = append(errs, errors.New("ATTACHMENT_MAX_BYTES must be positive"))
+	}
+	if a.SigningSecret == "" {
+		errs = append(errs, errors.New("ATTACHMENT_SIGNING_SECRET or JWT_SECRET must be set"))
+	}
+	errs = positive(errs, "ATTACHMENT_URL_TTL", a.URLTTL)
+	errs = positive(errs, "ATTACHMENT_ORPHAN_TTL", a.OrphanTTL)
+	return positive(errs, "ATTACHMENT_CLEANUP_INTERVAL", a.CleanupInterval)
+}
+
+func (r RetentionConfig) validate() []error {
+	var errs []error
+	if r.DeletedMessagesAfter < 0 || r.UnmatchedConversationsAfter < 0 {
+		errs = append(errs, errors.New("RETENTION_DELETED_MESSAGES_DAYS and RETENTION_UNMATCHED_CONVERSATIONS_DAYS must not be negative"))
+	}
+	if r.BatchSize <= 0 {
+		errs = append(errs, errors.New("RETENTION_BATCH_SIZE must be positive"))
+	}
+	return positive(errs, "RETENTION_PURGE_INTERVAL", r.PurgeInterval)
+}
+
+var moderationActions = map[string]bool{"allow": true, "mask": true, "hold": true, "reject": true}
+
+func (m ModerationConfig) validate() []error {
+	var errs []error
+	for _, setting := range [][2]string{{"MODERATION_CONTACT_ACTION", m.ContactAction}, {"MODERATION_URL_ACTION", m.URLAction}} {
+		if !moderationActions[strings.ToLower(setting[1])] {
+			errs = append(errs, fmt.Errorf("%s must be allow, mask, hold or reject, not %q", setting[0], setting[1]))
+		}
+	}
+	if m.SpamThreshold < 0 {
+		errs = append(errs, errors.New("MODERATION_SPAM_THRESHOLD must not be negative"))
+	}
+	if m.SpamThreshold > 0 && m.SpamWindow <= 0 {
+		errs = append(errs, errors.New("MODERATION_SPAM_WINDOW must be positive"))
+	}
+	return errs
+}
+
+func (p PresenceConfig) validate() []error {
+	errs := positive(nil, "PRESENCE_ONLINE_TTL", p.OnlineTTL)
+	return positive(errs, "PRESENCE_RETENTION", p.Retention)
+}
+
+// Redacted returns a copy of c with its secrets replaced, safe to log.
+func (c Config) Redacted() Config {
+	for _, secret := range []*string{&c.JWTSecret, &c.InternalAPIToken, &c.Database.Password, &c.ExportPseudonymSecret, &c.Attachments.SigningSecret} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+	c.CORSAllowedOrigins = append([]string(nil), c.CORSAllowedOrigins...)
+	return c
+}
+
+// DSN returns the PostgreSQL connection string for d.
+func (d DatabaseConfig) DSN() string {
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=UTC", d.Host, d.User, d.Password, d.Name, d.Port, d.SSLMode)
+	for _, opt := range [][2]string{{"sslrootcert", d.SSLRootCert}, {"sslcert", d.SSLCert}, {"sslkey", d.SSLKey}} {
+		if opt[1] != "" {
+			dsn += fmt.Sprintf(" %s=%s", opt[0], opt[1])
+		}
+	}
+	return dsn
+}
+
+var current struct {
+	once sync.Once
+	mu   sync.RWMutex
+	cfg  *Config
+}
+
+// Set installs cfg as the configuration returned by Get.
+func Set(cfg *Config) {
+	// Spend the once so that Get does not replace cfg.
+	current.once.Do(func() {})
+	current.mu.Lock()
+	defer current.mu.Unlock()
+	current.cfg = cfg
+}
+
+// Get returns the configuration installed by Set, or the one read from the
+// environment on first use when none was installed.
+func Get() *Config {
+	current.once.Do(func() {
+		current.mu.Lock()
+		defer current.mu.Unlock()
+		current.cfg = FromEnv()
+	})
+	current.mu.RLock()
+	defer current.mu.RUnlock()
+	return current.cfg
+}
+
+// ConnectDB opens the database described by cfg, sizes its connection pool
+// and installs it as DB.
+func ConnectDB(cfg DatabaseConfig) {
+	db, err := gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{})
+	if err != nil {
+		slog.Error("Database connection error", "error", err)
+		os.Exit(1)
+	}
+	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatalf("Database connection error: %v", err)
+		slog.Error("Database connection error", "error", err)
+		os.Exit(1)
 	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 	DB = db
 }
 
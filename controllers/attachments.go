@@ -0,0 +1,303 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"way-d-interactions/config"
+	"way-d-interactions/logging"
+	"way-d-interactions/models"
+	"way-d-interactions/storage"
+	"way-d-interactions/store"
+	"way-d-interactions/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// attachmentKinds maps the sniffed MIME types we accept to attachment kinds.
+var attachmentKinds = map[string]string{
+	"image/jpeg": models.AttachmentKindImage,
+	"image/png":  models.AttachmentKindImage,
+	"image/gif":  models.AttachmentKindImage,
+	"image/webp": models.AttachmentKindImage,
+	"audio/mpeg": models.AttachmentKindAudio,
+	"audio/wave": models.AttachmentKindAudio,
+	"audio/ogg":  models.AttachmentKindAudio,
+	"audio/mp4":  models.AttachmentKindAudio,
+}
+
+var errAttachmentSent = errors.New("attachment already sent")
+
+// maxAudioDurationMs bounds the client-reported length of a voice note.
+const maxAudioDurationMs = 10 * 60 * 1000
+
+// sniffMimeType detects the content type from the first bytes of a file,
+// telling M4A voice notes apart from MP4 video.
+func sniffMimeType(head []byte) string {
+	mimeType := http.DetectContentType(head)
+	switch {
+	case mimeType == "application/ogg":
+		return "audio/ogg"
+	case mimeType == "video/mp4" && len(head) >= 12 && string(head[8:11]) == "M4A":
+		return "audio/mp4"
+	}
+	return mimeType
+}
+
+// POST /matches/:id/attachments
+// @Summary Upload attachment
+// @Description Upload a photo or voice note to a match conversation as multipart field "file". The type is sniffed from the content (JPEG, PNG, GIF, WebP, MP3, WAV, Ogg, M4A) and the size is limited by ATTACHMENT_MAX_BYTES. Attach it by passing its ID as attachment_id to POST /message; unused uploads are deleted after a while.
+// @Tags interactions
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "Match ID"
+// @Param file formData file true "Photo or voice note"
+// @Param duration_ms formData int false "Voice note duration in milliseconds"
+// @Success 201 {object} models.Attachment
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/matches/{id}/attachments [post]
+func (h *Handler) PostAttachment(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ctx := c.Request.Context()
+	match, otherID, err := h.findParticipantMatch(ctx, c.Param("id"), userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No such match or not a participant"})
+		return
+	}
+	if err != nil {
+		logging.From(c).Error("Loading match", "match_id", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not store attachment"})
+		return
+	}
+	if match.ExpireAt != nil && match.ExpireAt.Before(time.Now()) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Match expired"})
+		return
+	}
+	blocked, err := h.isBlocked(ctx, userID, otherID)
+	if err != nil {
+		logging.From(c).Error("Checking blocks", "match_id", match.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not store attachment"})
+		return
+	}
+	if blocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Blocked"})
+		return
+	}
+	maxBytes := config.Get().Attachments.MaxBytes
+	// Leave room for the multipart envelope around the file.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+64<<10)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Attachment too large"})
+			return
+		}
+		respondInvalid(c, validation.NewError("file", "is required"))
+		return
+	}
+	if header.Size > maxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Attachment too large"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		respondInvalid(c, validation.NewError("file", "could not be read"))
+		return
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	head = head[:n]
+	mimeType := sniffMimeType(head)
+	kind, ok := attachmentKinds[mimeType]
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unsupported attachment type", "mime_type": mimeType})
+		return
+	}
+	attachment := models.Attachment{
+		ID:         uuid.New(),
+		UploaderID: uuid.MustParse(userID),
+		MatchID:    match.ID,
+		Kind:       kind,
+		MimeType:   mimeType,
+		CreatedAt:  time.Now(),
+	}
+	if kind == models.AttachmentKindAudio && c.PostForm("duration_ms") != "" {
+		duration, err := strconv.Atoi(c.PostForm("duration_ms"))
+		if err != nil || duration <= 0 || duration > maxAudioDurationMs {
+			respondInvalid(c, validation.NewError("duration_ms", "must be between 1 and "+strconv.Itoa(maxAudioDurationMs)))
+			return
+		}
+		attachment.DurationMs = &duration
+	}
+	attachment.StorageKey = match.ID.String() + "/" + attachment.ID.String()
+
+	hash := sha256.New()
+	files := storage.Default()
+	size, err := files.Put(ctx, attachment.StorageKey, io.TeeReader(io.MultiReader(bytes.NewReader(head), file), hash))
+	if err != nil {
+		logging.From(c).Error("Storing attachment", "attachment_id", attachment.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not store attachment"})
+		return
+	}
+	attachment.Size = size
+	attachment.Checksum = hex.EncodeToString(hash.Sum(nil))
+	if kind == models.AttachmentKindImage {
+		if rc, err := files.Open(ctx, attachment.StorageKey); err == nil {
+			if cfg, _, err := image.DecodeConfig(rc); err == nil {
+				attachment.Width, attachment.Height = &cfg.Width, &cfg.Height
+			}
+			rc.Close()
+		}
+	}
+	if err := h.Attachments.Create(ctx, &attachment); err != nil {
+		logging.From(c).Error("Saving attachment", "attachment_id", attachment.ID, "error", err)
+		files.Delete(ctx, attachment.StorageKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not store attachment"})
+		return
+	}
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// findVisibleAttachment loads an attachment that userID may download: userID
+// must take part in its match, which must not have expired, the pair must not
+// be blocked, and the attachment must belong to a message userID can see.
+// Only the uploader may see it before it is sent. Hidden attachments are
+// reported as store.ErrNotFound.
+func (h *Handler) findVisibleAttachment(ctx context.Context, attachmentID, userID string) (models.Attachment, error) {
+	id, err := uuid.Parse(attachmentID)
+	if err != nil {
+		return models.Attachment{}, store.ErrNotFound
+	}
+	attachment, err := h.Attachments.Find(ctx, id)
+	if err != nil {
+		return attachment, err
+	}
+	match, otherID, err := h.findParticipantMatch(ctx, attachment.MatchID.String(), userID)
+	if err != nil {
+		return attachment, err
+	}
+	if match.ExpireAt != nil && match.ExpireAt.Before(time.Now()) {
+		return attachment, store.ErrNotFound
+	}
+	if blocked, err := h.isBlocked(ctx, userID, otherID); err != nil || blocked {
+		if err == nil {
+			err = store.ErrNotFound
+		}
+		return attachment, err
+	}
+	if attachment.MessageID == nil {
+		if attachment.UploaderID.String() != userID {
+			return attachment, store.ErrNotFound
+		}
+		return attachment, nil
+	}
+	_, err = h.Messages.FindVisible(ctx, *attachment.MessageID, match.ID, uuid.MustParse(userID))
+	return attachment, err
+}
+
+// GET /attachments/:id/url
+// @Summary Get attachment download URL
+// @Description Returns a signed download URL valid for ATTACHMENT_URL_TTL that only the caller can use. Only participants of a live match can get one, and only for attachments of messages they can see.
+// @Tags interactions
+// @Produce json
+// @Param id path string true "Attachment ID"
+// @Success 200 {object} storage.SignedURL
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/attachments/{id}/url [get]
+func (h *Handler) GetAttachmentURL(c *gin.Context) {
+	userID := c.GetString("user_id")
+	attachment, err := h.findVisibleAttachment(c.Request.Context(), c.Param("id"), userID)
+	if err != nil {
+		respondAttachmentError(c, err)
+		return
+	}
+	cfg := config.Get().Attachments
+	signed := storage.Sign([]byte(cfg.SigningSecret), "/attachments/", attachment.ID.String(), userID, time.Now().Add(cfg.URLTTL))
+	c.JSON(http.StatusOK, signed)
+}
+
+// GET /attachments/:id
+// @Summary Download attachment
+// @Description Downloads an attachment through a signed URL from GET /api/attachments/{id}/url. The link is checked against the user it was issued to, who must still be a match participant.
+// @Tags interactions
+// @Produce octet-stream
+// @Param id path string true "Attachment ID"
+// @Success 200 {file} file
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /attachments/{id} [get]
+func (h *Handler) DownloadAttachment(c *gin.Context) {
+	attachmentID := c.Param("id")
+	userID, ok := storage.Verify([]byte(config.Get().Attachments.SigningSecret), attachmentID, c.Request.URL.Query(), time.Now())
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired link"})
+		return
+	}
+	attachment, err := h.findVisibleAttachment(c.Request.Context(), attachmentID, userID)
+	if err != nil {
+		respondAttachmentError(c, err)
+		return
+	}
+	rc, err := storage.Default().Open(c.Request.Context(), attachment.StorageKey)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No such attachment"})
+		return
+	}
+	defer rc.Close()
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.MimeType, rc, nil)
+}
+
+// respondAttachmentError answers a failed findVisibleAttachment.
+func respondAttachmentError(c *gin.Context, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No such attachment"})
+		return
+	}
+	logging.From(c).Error("Loading attachment", "attachment_id", c.Param("id"), "error", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load attachment"})
+}
+
+// loadAttachments fills in the Attachments of messages.
+func (h *Handler) loadAttachments(ctx context.Context, messages []models.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(messages))
+	index := make(map[uuid.UUID]int, len(messages))
+	for i, m := range messages {
+		ids[i] = m.ID
+		index[m.ID] = i
+	}
+	attachments, err := h.Attachments.ListForMessages(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		i := index[*a.MessageID]
+		messages[i].Attachments = append(messages[i].Attachments, a)
+	}
+	return nil
+}
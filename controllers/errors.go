@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"net/http"
+
+	"way-d-interactions/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondInvalid rejects the request with field-level validation errors:
+// {"error": "Invalid request", "fields": [{"field": "...", "message": "..."}]}.
+func respondInvalid(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, invalidBody(err))
+}
+
+func invalidBody(err error) gin.H {
+	return gin.H{"error": "Invalid request", "fields": validation.FromBindError(err)}
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"way-d-interactions/realtime"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// eventsHeartbeat keeps idle event streams alive through proxies.
+const eventsHeartbeat = 25 * time.Second
+
+// GET /events
+// @Summary Event stream
+// @Description Server-sent events for the current user, such as reactions to their messages, typing indicators and presence changes of their matches. A "ping" event is sent every 25 seconds. An open stream is what makes the user appear online.
+// @Tags interactions
+// @Produce text/event-stream
+// @Success 200 {object} realtime.Event
+// @Router /api/events [get]
+func (h *Handler) GetEvents(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		return
+	}
+	events, unsubscribe := realtime.Default().Subscribe(userID)
+	defer unsubscribe()
+	presence := realtime.DefaultPresence()
+	// The request context is done once the client leaves, but the last
+	// notification still has to go out.
+	ctx := context.WithoutCancel(c.Request.Context())
+	if presence.Connect(userID) {
+		h.notifyPresence(ctx, userID.String())
+	}
+	defer func() {
+		if presence.Disconnect(userID) {
+			h.notifyPresence(ctx, userID.String())
+		}
+	}()
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+		case <-heartbeat.C:
+			presence.Touch(userID)
+			c.SSEvent("ping", realtime.Event{Type: "ping", At: time.Now().UTC()})
+		}
+		return true
+	})
+}
@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"archive/zip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"way-d-interactions/config"
+	"way-d-interactions/logging"
+	"way-d-interactions/models"
+	"way-d-interactions/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// exportFile describes one JSON document of the export archive, holding the
+// rows of set. convert, when set, replaces each row before it is written.
+type exportFile struct {
+	set     store.ExportSet
+	label   string
+	count   int
+	convert func(row interface{}) interface{}
+}
+
+func (f *exportFile) name() string {
+	return string(f.set) + ".json"
+}
+
+// exportArrayWriter writes a JSON array one element at a time.
+type exportArrayWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (a *exportArrayWriter) write(v interface{}) error {
+	b, err := json.MarshalIndent(v, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := ",\n  "
+	if a.count == 0 {
+		sep = "[\n  "
+	}
+	if _, err := io.WriteString(a.w, sep); err != nil {
+		return err
+	}
+	if _, err := a.w.Write(b); err != nil {
+		return err
+	}
+	a.count++
+	return nil
+}
+
+func (a *exportArrayWriter) close() error {
+	end := "\n]\n"
+	if a.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(a.w, end)
+	return err
+}
+
+// exportPseudonymousInteraction is a received like, dislike or block whose
+// author is replaced by a pseudonym.
+type exportPseudonymousInteraction struct {
+	ID        uuid.UUID `json:"id"`
+	From      string    `json:"from"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// pseudonymFor returns an identifier for otherID that is stable within the
+// caller's exports but cannot be linked back to the real user ID.
+func pseudonymFor(callerID string, otherID uuid.UUID) string {
+	mac := hmac.New(sha256.New, []byte(config.Get().ExportPseudonymSecret))
+	mac.Write([]byte(callerID))
+	mac.Write(otherID[:])
+	return "anon-" + hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// GetExport streams the caller's interaction history as a zip archive.
+// @Summary Export my data
+// @Description Download a zip archive with one JSON file per entity (likes, dislikes, matches, messages, blocks) the caller authored or received, plus a human-readable summary. Users who liked you without a match, disliked you or blocked you are pseudonymised.
+// @Tags interactions
+// @Produce application/zip
+// @Success 200 {file} file
+// @Router /api/me/export [get]
+func (h *Handler) GetExport(c *gin.Context) {
+	userID := c.GetString("user_id")
+	uid := uuid.MustParse(userID)
+
+	pseudonymous := func(id, from uuid.UUID, createdAt time.Time) exportPseudonymousInteraction {
+		return exportPseudonymousInteraction{ID: id, From: pseudonymFor(userID, from), CreatedAt: createdAt}
+	}
+	files := []*exportFile{
+		{set: store.ExportLikesSent, label: "Likes sent"},
+		{set: store.ExportLikesReceived, label: "Likes received", convert: func(row interface{}) interface{} {
+			l := row.(*models.Like)
+			// The liker's identity is only known to the caller once they matched.
+			if l.Match {
+				return l
+			}
+			received := pseudonymous(l.ID, l.UserID, l.CreatedAt)
+			if !l.NoteHeld {
+				received.Note = l.Note
+			}
+			return received
+		}},
+		{set: store.ExportDislikesSent, label: "Dislikes sent"},
+		{set: store.ExportDislikesReceived, label: "Dislikes received", convert: func(row interface{}) interface{} {
+			d := row.(*models.Dislike)
+			return pseudonymous(d.ID, d.UserID, d.CreatedAt)
+		}},
+		{set: store.ExportMatches, label: "Matches"},
+		{set: store.ExportMatchExtensions, label: "Match extensions"},
+		{set: store.ExportRematchRequests, label: "Rematch requests sent or received"},
+		{set: store.ExportMessages, label: "Messages sent or received"},
+		{set: store.ExportScheduledMessages, label: "Scheduled messages"},
+		{set: store.ExportIcebreakersSent, label: "Icebreakers sent"},
+		{set: store.ExportReactionsSent, label: "Reactions"},
+		{set: store.ExportAttachmentsSent, label: "Attachments uploaded"},
+		{set: store.ExportReportsMade, label: "Messages reported"},
+		{set: store.ExportSettings, label: "Settings"},
+		{set: store.ExportBlocksMade, label: "Blocks made"},
+		{set: store.ExportBlocksReceived, label: "Blocks received", convert: func(row interface{}) interface{} {
+			b := row.(*models.Block)
+			return pseudonymous(b.ID, b.UserID, b.CreatedAt)
+		}},
+	}
+
+	generatedAt := time.Now().UTC()
+	filename := fmt.Sprintf("way-d-interactions-export-%s.zip", generatedAt.Format("20060102-150405"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	ctx := c.Request.Context()
+	zw := zip.NewWriter(c.Writer)
+	for _, f := range files {
+		entry, err := zw.Create(f.name())
+		if err == nil {
+			aw := &exportArrayWriter{w: entry}
+			err = h.Exports.Each(ctx, f.set, uid, func(row interface{}) error {
+				if f.convert != nil {
+					row = f.convert(row)
+				}
+				return aw.write(row)
+			})
+			if err == nil {
+				err = aw.close()
+			}
+			f.count = aw.count
+		}
+		if err != nil {
+			// Headers are already sent: leave the archive truncated so the
+			// client sees a corrupt download rather than a partial export.
+			logging.From(c).Error("Export failed", "file", f.name(), "error", err)
+			c.Abort()
+			return
+		}
+	}
+	summary, err := zw.Create("summary.txt")
+	if err == nil {
+		err = writeExportSummary(summary, userID, generatedAt, files)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		logging.From(c).Error("Export failed", "file", "summary.txt", "error", err)
+		c.Abort()
+	}
+}
+
+func writeExportSummary(w io.Writer, userID string, generatedAt time.Time, files []*exportFile) error {
+	var err error
+	printf := func(format string, args ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+	printf("Way-d interactions data export\n")
+	printf("Generated: %s\n", generatedAt.Format(time.RFC3339))
+	printf("User:      %s\n\n", userID)
+	for _, f := range files {
+		printf("%-28s %6d  (%s)\n", f.label+":", f.count, f.name())
+	}
+	printf("\nPeople who liked you without a match, disliked you or blocked you are\n")
+	printf("shown as pseudonyms (\"anon-...\"). A pseudonym always refers to the same\n")
+	printf("person within your exports but cannot be used to identify them.\n")
+	return err
+}
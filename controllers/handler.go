@@ -0,0 +1,16 @@
+package controllers
+
+import (
+	"way-d-interactions/store"
+)
+
+// Handler serves the HTTP endpoints of the service from its stores, so they
+// can run against PostgreSQL or against in-memory stores in tests.
+type Handler struct {
+	store.Stores
+}
+
+// NewHandler returns a handler using stores.
+func NewHandler(stores store.Stores) *Handler {
+	return &Handler{Stores: stores}
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"way-d-interactions/logging"
+	"way-d-interactions/migrations"
+	"way-d-interactions/workers"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// readinessTimeout bounds the checks of one readiness probe.
+const readinessTimeout = 2 * time.Second
+
+// Probes serves the liveness and readiness probes.
+type Probes struct {
+	DB *gorm.DB
+	// Migrations reads the applied migrations of DB. Its table is created
+	// at startup, so probes never run DDL.
+	Migrations *migrations.Migrator
+	// Workers are the background workers that must be running for the
+	// service to be ready. Nil skips the check.
+	Workers *workers.Group
+	// draining is closed once shutdown starts.
+	draining chan struct{}
+}
+
+// NewProbes returns probes checking db and group.
+func NewProbes(db *gorm.DB, group *workers.Group) (*Probes, error) {
+	m, err := migrations.New(db)
+	if err != nil {
+		return nil, err
+	}
+	return &Probes{DB: db, Migrations: m, Workers: group, draining: make(chan struct{})}, nil
+}
+
+// Drain makes the readiness probe fail from now on, so that no new traffic
+// is routed to the instance while it shuts down.
+func (p *Probes) Drain() {
+	select {
+	case <-p.draining:
+	default:
+		close(p.draining)
+	}
+}
+
+// GET /healthz
+// @Summary Liveness probe
+// @Description Reports that the process is up. It does not check dependencies.
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /healthz [get]
+func (p *Probes) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// GET /readyz
+// @Summary Readiness probe
+// @Description Reports whether the instance can serve traffic: the database answers, every migration is applied and the background workers are running. Fails with 503 while shutting down.
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 503 {object} map[string]interface{}
+// @Router /readyz [get]
+func (p *Probes) Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+	checks := gin.H{
+		"database":   p.checkDatabase(ctx),
+		"migrations": p.checkMigrations(ctx),
+		"workers":    p.checkWorkers(),
+	}
+	ready := true
+	for _, result := range checks {
+		ready = ready && result == "ok"
+	}
+	select {
+	case <-p.draining:
+		ready = false
+		checks["shutdown"] = "draining"
+	default:
+	}
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": checks})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready", "checks": checks})
+}
+
+// checkDatabase and checkMigrations log the errors they hit: probe
+// responses are unauthenticated and must not reveal connection details.
+func (p *Probes) checkDatabase(ctx context.Context) string {
+	sqlDB, err := p.DB.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Warn("Readiness: database check failed", "error", err)
+		return "database unavailable"
+	}
+	return "ok"
+}
+
+func (p *Probes) checkMigrations(ctx context.Context) string {
+	pending, err := p.Migrations.Pending(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Readiness: migration check failed", "error", err)
+		return "migration status unavailable"
+	}
+	if len(pending) > 0 {
+		return "pending migrations"
+	}
+	return "ok"
+}
+
+func (p *Probes) checkWorkers() string {
+	if p.Workers == nil {
+		return "ok"
+	}
+	if stopped := p.Workers.Stopped(); len(stopped) > 0 {
+		return "stopped: " + strings.Join(stopped, ", ")
+	}
+	return "ok"
+}
@@ -0,0 +1,319 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"way-d-interactions/config"
+	"way-d-interactions/logging"
+	"way-d-interactions/models"
+	"way-d-interactions/store"
+	"way-d-interactions/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/text/language"
+)
+
+const maxIcebreakerRunes = 300
+
+// defaultIcebreakers seeds an empty catalogue.
+var defaultIcebreakers = map[string][]string{
+	"en": {
+		"What's the best trip you've ever taken?",
+		"If you could master any skill overnight, what would it be?",
+		"What does your perfect Sunday look like?",
+		"What's a song you can't stop listening to right now?",
+		"Coffee or tea, and how do you take it?",
+		"What's the last thing that made you laugh out loud?",
+	},
+	"fr": {
+		"Quel est le plus beau voyage que tu aies fait ?",
+		"Si tu pouvais maîtriser une compétence du jour au lendemain, laquelle choisirais-tu ?",
+		"À quoi ressemble ton dimanche idéal ?",
+		"Quelle chanson écoutes-tu en boucle en ce moment ?",
+		"Café ou thé, et comment le prends-tu ?",
+		"Quelle est la dernière chose qui t'a fait rire aux éclats ?",
+	},
+}
+
+// SeedIcebreakers fills the icebreaker catalogue with the built-in prompts
+// when it is empty.
+func SeedIcebreakers(ctx context.Context, icebreakers store.IcebreakerStore) error {
+	existing, err := icebreakers.List(ctx, "")
+	if err != nil || len(existing) > 0 {
+		return err
+	}
+	now := time.Now()
+	for locale, texts := range defaultIcebreakers {
+		for _, text := range texts {
+			icebreaker := models.Icebreaker{ID: uuid.New(), Locale: locale, Text: text, Active: true, CreatedAt: now, UpdatedAt: now}
+			if err := icebreakers.Create(ctx, &icebreaker); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// icebreakerLocale picks the catalogue locale closest to the user's
+// preference: the token's locale claim, then Accept-Language, then
+// ICEBREAKER_DEFAULT_LOCALE.
+func (h *Handler) icebreakerLocale(c *gin.Context) (string, error) {
+	locales, err := h.Icebreakers.Locales(c.Request.Context())
+	if err != nil {
+		return "", err
+	}
+	names := []string{config.Get().Messages.IcebreakerDefaultLocale}
+	for _, l := range locales {
+		if l != names[0] {
+			names = append(names, l)
+		}
+	}
+	supported := make([]language.Tag, len(names))
+	for i, name := range names {
+		supported[i] = language.Make(name)
+	}
+	var preferred []language.Tag
+	if claim := c.GetString("locale"); claim != "" {
+		if tag, err := language.Parse(claim); err == nil {
+			preferred = append(preferred, tag)
+		}
+	}
+	if tags, _, err := language.ParseAcceptLanguage(c.GetHeader("Accept-Language")); err == nil {
+		preferred = append(preferred, tags...)
+	}
+	// The matcher falls back to the first supported tag, the default.
+	_, index, _ := language.NewMatcher(supported).Match(preferred...)
+	return names[index], nil
+}
+
+// GET /matches/:id/icebreakers
+// @Summary Icebreaker suggestions
+// @Description Suggested first messages for a match, in the locale from the token's locale claim or Accept-Language. Icebreakers already sent in the match are left out; the suggestions are stable for a given match. Send one by passing its id as icebreaker_id to POST /message.
+// @Tags interactions
+// @Produce json
+// @Param id path string true "Match ID"
+// @Param count query int false "Number of suggestions (default 3, max 10)"
+// @Success 200 {array} models.Icebreaker
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/matches/{id}/icebreakers [get]
+func (h *Handler) GetMatchIcebreakers(c *gin.Context) {
+	userID := c.GetString("user_id")
+	count, err := strconv.Atoi(c.DefaultQuery("count", "3"))
+	if err != nil || count <= 0 || count > 10 {
+		count = 3
+	}
+	match, _, ok := h.findLiveConversation(c, c.Param("id"), userID)
+	if !ok {
+		return
+	}
+	locale, err := h.icebreakerLocale(c)
+	if err != nil {
+		logging.From(c).Error("Listing icebreaker locales", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load icebreakers"})
+		return
+	}
+	candidates, err := h.Icebreakers.ListUnused(c.Request.Context(), locale, match.ID)
+	if err != nil {
+		logging.From(c).Error("Listing icebreakers", "match_id", match.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load icebreakers"})
+		return
+	}
+	if candidates == nil {
+		candidates = []models.Icebreaker{}
+	}
+	// Shuffle with a seed derived from the match so refreshing does not
+	// reshuffle, while different matches see different prompts.
+	seed := fnv.New64a()
+	seed.Write(match.ID[:])
+	rng := rand.New(rand.NewSource(int64(seed.Sum64())))
+	rng.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	if len(candidates) > count {
+		candidates = candidates[:count]
+	}
+	c.JSON(http.StatusOK, candidates)
+}
+
+type icebreakerInput struct {
+	Locale string `json:"locale" binding:"required"`
+	Text   string `json:"text" binding:"required"`
+	Active *bool  `json:"active"`
+}
+
+// validate normalises the locale to a BCP 47 tag and checks the text.
+func (in *icebreakerInput) validate() error {
+	tag, err := language.Parse(in.Locale)
+	if err != nil {
+		return validation.NewError("locale", "must be a BCP 47 language tag")
+	}
+	in.Locale = tag.String()
+	in.Text, err = validation.Text("text", in.Text, maxIcebreakerRunes)
+	return err
+}
+
+// GET /internal/icebreakers
+// @Summary List icebreakers
+// @Description Internal endpoint. Lists the icebreaker catalogue, including inactive entries.
+// @Tags internal
+// @Produce json
+// @Param locale query string false "Only this locale"
+// @Success 200 {array} models.Icebreaker
+// @Failure 500 {object} map[string]string
+// @Router /internal/icebreakers [get]
+func (h *Handler) ListIcebreakers(c *gin.Context) {
+	icebreakers, err := h.Icebreakers.List(c.Request.Context(), c.Query("locale"))
+	if err != nil {
+		logging.From(c).Error("Listing icebreakers", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not list icebreakers"})
+		return
+	}
+	if icebreakers == nil {
+		icebreakers = []models.Icebreaker{}
+	}
+	c.JSON(http.StatusOK, icebreakers)
+}
+
+// POST /internal/icebreakers
+// @Summary Create icebreaker
+// @Description Internal endpoint. Adds an icebreaker to the catalogue.
+// @Tags internal
+// @Accept json
+// @Produce json
+// @Param icebreaker body icebreakerInput true "Locale, text and optional active flag (default true)"
+// @Success 201 {object} models.Icebreaker
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /internal/icebreakers [post]
+func (h *Handler) CreateIcebreaker(c *gin.Context) {
+	var input icebreakerInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondInvalid(c, err)
+		return
+	}
+	if err := input.validate(); err != nil {
+		respondInvalid(c, err)
+		return
+	}
+	now := time.Now()
+	icebreaker := models.Icebreaker{ID: uuid.New(), Locale: input.Locale, Text: input.Text, Active: input.Active == nil || *input.Active, CreatedAt: now, UpdatedAt: now}
+	if err := h.Icebreakers.Create(c.Request.Context(), &icebreaker); err != nil {
+		logging.From(c).Error("Creating icebreaker", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create icebreaker"})
+		return
+	}
+	c.JSON(http.StatusCreated, icebreaker)
+}
+
+// PUT /internal/icebreakers/:id
+// @Summary Update icebreaker
+// @Description Internal endpoint. Replaces an icebreaker's locale, text and active flag.
+// @Tags internal
+// @Accept json
+// @Produce json
+// @Param id path string true "Icebreaker ID"
+// @Param icebreaker body icebreakerInput true "Locale, text and optional active flag (default true)"
+// @Success 200 {object} models.Icebreaker
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /internal/icebreakers/{id} [put]
+func (h *Handler) UpdateIcebreaker(c *gin.Context) {
+	var input icebreakerInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondInvalid(c, err)
+		return
+	}
+	if err := input.validate(); err != nil {
+		respondInvalid(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No such icebreaker"})
+		return
+	}
+	icebreaker, err := h.Icebreakers.Find(ctx, id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No such icebreaker"})
+		} else {
+			logging.From(c).Error("Loading icebreaker", "icebreaker_id", id, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update icebreaker"})
+		}
+		return
+	}
+	icebreaker.Locale = input.Locale
+	icebreaker.Text = input.Text
+	icebreaker.Active = input.Active == nil || *input.Active
+	icebreaker.UpdatedAt = time.Now()
+	if err := h.Icebreakers.Update(ctx, &icebreaker); err != nil {
+		logging.From(c).Error("Updating icebreaker", "icebreaker_id", id, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update icebreaker"})
+		return
+	}
+	c.JSON(http.StatusOK, icebreaker)
+}
+
+// DELETE /internal/icebreakers/:id
+// @Summary Retire icebreaker
+// @Description Internal endpoint. Deactivates an icebreaker: it is no longer suggested but its usage statistics are kept.
+// @Tags internal
+// @Param id path string true "Icebreaker ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /internal/icebreakers/{id} [delete]
+func (h *Handler) DeleteIcebreaker(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err == nil {
+		err = h.Icebreakers.Retire(c.Request.Context(), id, time.Now())
+	} else {
+		err = store.ErrNotFound
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No such icebreaker"})
+		return
+	}
+	if err != nil {
+		logging.From(c).Error("Retiring icebreaker", "icebreaker_id", id, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retire icebreaker"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GET /internal/icebreakers/stats
+// @Summary Icebreaker conversion
+// @Description Internal endpoint. For each icebreaker, how many times it was sent and how many of those got a reply from the other participant.
+// @Tags internal
+// @Produce json
+// @Param locale query string false "Only this locale"
+// @Success 200 {array} models.IcebreakerStats
+// @Failure 500 {object} map[string]string
+// @Router /internal/icebreakers/stats [get]
+func (h *Handler) GetIcebreakerStats(c *gin.Context) {
+	stats, err := h.Icebreakers.Stats(c.Request.Context(), c.Query("locale"))
+	if err != nil {
+		logging.From(c).Error("Computing icebreaker statistics", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not compute icebreaker statistics"})
+		return
+	}
+	if stats == nil {
+		stats = []models.IcebreakerStats{}
+	}
+	for i := range stats {
+		if stats[i].Sent > 0 {
+			stats[i].ReplyRate = float64(stats[i].Replied) / float64(stats[i].Sent)
+		}
+	}
+	c.JSON(http.StatusOK, stats)
+}
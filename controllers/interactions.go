@@ -1,12 +1,20 @@
 package controllers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"way-d-interactions/config"
+	"way-d-interactions/logging"
+	"way-d-interactions/metrics"
 	"way-d-interactions/models"
+	"way-d-interactions/moderation"
+	"way-d-interactions/store"
+	"way-d-interactions/validation"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -14,76 +22,146 @@ import (
 
 // PostLike handles liking a user and creates a match if reciprocal.
 // @Summary Like a user
-// @Description Like a user. If the other user has already liked you, a match is created. Cannot like yourself, users you blocked, or users who blocked you. Duplicate likes/dislikes are prevented.
+// @Description Like a user. If the other user has already liked you, a match is created. Cannot like yourself, users you blocked, or users who blocked you. Duplicate likes/dislikes are prevented. An optional note goes through the same validation and moderation as messages; the target sees it with the like and it becomes the first message on match.
 // @Tags interactions
 // @Accept json
 // @Produce json
-// @Param like body struct{target_id string} true "Target user ID"
+// @Param like body struct{target_id string; note string; super bool} true "Target user ID, optional note (at most 200 characters) and whether this is a super-like"
 // @Success 201 {object} models.Like
 // @Failure 400 {object} map[string]string
 // @Failure 403 {object} map[string]string
 // @Failure 409 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 500 {object} map[string]string
 // @Router /api/like [post]
-func PostLike(c *gin.Context) {
+func (h *Handler) PostLike(c *gin.Context) {
 	userID := c.GetString("user_id")
 	var input struct {
-		TargetID string `json:"target_id" binding:"required"`
+		TargetID string `json:"target_id" binding:"required,uuid"`
+		Note     string `json:"note"`
+		Super    bool   `json:"super"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondInvalid(c, err)
 		return
 	}
+	var note string
+	if input.Note != "" {
+		var err error
+		if note, err = validation.Text("note", input.Note, config.Get().Messages.LikeNoteMaxRunes); err != nil {
+			respondInvalid(c, err)
+			return
+		}
+	}
 	if userID == input.TargetID {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot like yourself"})
 		return
 	}
-	db := config.GetDB()
+	ctx := c.Request.Context()
+	uid, targetID := uuid.MustParse(userID), uuid.MustParse(input.TargetID)
 	// Check for block
-	var block models.Block
-	blockErr := db.Where("(user_id = ? AND blocked_id = ?) OR (user_id = ? AND blocked_id = ?)", userID, input.TargetID, input.TargetID, userID).First(&block).Error
-	fmt.Printf("[DEBUG] PostLike block check: userID=%s, targetID=%s, blockErr=%v\n", userID, input.TargetID, blockErr)
-	if blockErr == nil {
+	blocked, err := h.Blocks.Blocked(ctx, uid, targetID)
+	if err != nil {
+		logging.From(c).Error("Checking blocks", "target_id", input.TargetID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not like user"})
+		return
+	}
+	if blocked {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Blocked"})
 		return
 	}
 	// Prevent duplicate like
-	var existing models.Like
-	if err := db.Where("user_id = ? AND target_id = ?", userID, input.TargetID).First(&existing).Error; err == nil {
+	if _, err := h.Likes.FindLike(ctx, uid, targetID); err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "Already liked"})
 		return
 	}
 	// Prevent duplicate dislike
-	var dislike models.Dislike
-	if err := db.Where("user_id = ? AND target_id = ?", userID, input.TargetID).First(&dislike).Error; err == nil {
+	if _, err := h.Likes.FindDislike(ctx, uid, targetID); err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "Already disliked"})
 		return
 	}
 	like := models.Like{
 		ID:        uuid.New(),
-		UserID:    uuid.MustParse(userID),
-		TargetID:  uuid.MustParse(input.TargetID),
+		UserID:    uid,
+		TargetID:  targetID,
 		CreatedAt: time.Now(),
+		Super:     input.Super,
 	}
-	// Check for reciprocal like and create match if needed
-	var reciprocal models.Like
-	if err := db.Where("user_id = ? AND target_id = ?", input.TargetID, userID).First(&reciprocal).Error; err == nil {
-		like.Match = true
+	if note != "" {
+		verdict := moderation.Default().Run(ctx, moderation.Input{
+			SenderID:   like.UserID,
+			ReceiverID: like.TargetID,
+			Content:    note,
+		})
+		h.recordModeration(ctx, nil, userID, input.TargetID, verdict)
+		if verdict.Action == moderation.Reject {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Note rejected by moderation", "reason": verdict.Decisions[len(verdict.Decisions)-1].Reason})
+			return
+		}
+		like.Note = verdict.Content
+		like.NoteHeld = verdict.Action == moderation.Hold
+	}
+	// Check for reciprocal like; the like is stored first so that the unique
+	// index settles concurrent duplicates before any match is created.
+	reciprocal, err := h.Likes.FindLike(ctx, targetID, uid)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		logging.From(c).Error("Checking reciprocal like", "target_id", input.TargetID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not like user"})
+		return
+	}
+	mutual := err == nil
+	like.Match = mutual
+	var match models.Match
+	var notes []models.Message
+	// The like, the match and its first messages are kept together or not
+	// at all, so a failure cannot leave a matched like without its match.
+	err = h.Atomic(ctx, func(s store.Stores) error {
+		if err := s.Likes.CreateLike(ctx, &like); err != nil || !mutual {
+			return err
+		}
+		if err := s.Likes.MarkMatched(ctx, reciprocal.ID); err != nil {
+			return err
+		}
 		reciprocal.Match = true
-		db.Save(&reciprocal)
-		// Create match
-		match := models.Match{
-			ID:        uuid.New(),
-			User1ID:   like.UserID,
-			User2ID:   like.TargetID,
-			CreatedAt: time.Now(),
+		now := time.Now()
+		match = models.Match{
+			ID:               uuid.New(),
+			User1ID:          like.UserID,
+			User2ID:          like.TargetID,
+			CreatedAt:        now,
+			ExpireAt:         matchExpiry(now),
+			InitiatorID:      &reciprocal.UserID,
+			InitiatorLikedAt: &reciprocal.CreatedAt,
+			ResponderLikedAt: &like.CreatedAt,
+			Source:           models.MatchSourceLike,
+		}
+		if like.Super || reciprocal.Super {
+			match.Source = models.MatchSourceSuperLike
+		}
+		if err := s.Matches.Create(ctx, &match); err != nil {
+			return err
 		}
-		db.Create(&match)
+		// Notes open the conversation, the earlier like's first.
+		var err error
+		notes, err = createNoteMessages(ctx, s, match, reciprocal, like)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Already liked"})
+			return
+		}
+		logging.From(c).Error("Storing like", "target_id", input.TargetID, "mutual", mutual, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not like user"})
+		return
 	}
-	db.Create(&like)
+	metrics.Likes.WithLabelValues(strconv.FormatBool(like.Super)).Inc()
+	if mutual {
+		metrics.MatchesCreated.WithLabelValues(match.Source).Inc()
+		h.noteMessagesSent(ctx, notes)
+	}
+	logging.From(c).Debug("Like stored", "target_id", input.TargetID, "mutual", mutual, "super", like.Super)
 	c.JSON(http.StatusCreated, like)
-
-	// DEBUG: Print userID and input.TargetID for troubleshooting
-	fmt.Printf("[DEBUG] PostLike: userID=%s, targetID=%s\n", userID, input.TargetID)
 }
 
 // POST /dislike
@@ -98,142 +176,395 @@ func PostLike(c *gin.Context) {
 // @Failure 403 {object} map[string]string
 // @Failure 409 {object} map[string]string
 // @Router /api/dislike [post]
-func PostDislike(c *gin.Context) {
+func (h *Handler) PostDislike(c *gin.Context) {
 	userID := c.GetString("user_id")
 	var input struct {
-		TargetID string `json:"target_id" binding:"required"`
+		TargetID string `json:"target_id" binding:"required,uuid"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondInvalid(c, err)
 		return
 	}
 	if userID == input.TargetID {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot dislike yourself"})
 		return
 	}
-	db := config.GetDB()
+	ctx := c.Request.Context()
+	uid, targetID := uuid.MustParse(userID), uuid.MustParse(input.TargetID)
 	// Check for block
-	var block models.Block
-	if err := db.Where("(user_id = ? AND blocked_id = ?) OR (user_id = ? AND blocked_id = ?)", userID, input.TargetID, input.TargetID, userID).First(&block).Error; err == nil {
+	blocked, err := h.Blocks.Blocked(ctx, uid, targetID)
+	if err != nil {
+		logging.From(c).Error("Checking blocks", "target_id", input.TargetID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not dislike user"})
+		return
+	}
+	if blocked {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Blocked"})
 		return
 	}
 	// Prevent duplicate dislike
-	var existing models.Dislike
-	if err := db.Where("user_id = ? AND target_id = ?", userID, input.TargetID).First(&existing).Error; err == nil {
+	if _, err := h.Likes.FindDislike(ctx, uid, targetID); err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "Already disliked"})
 		return
 	}
 	// Prevent duplicate like
-	var like models.Like
-	if err := db.Where("user_id = ? AND target_id = ?", userID, input.TargetID).First(&like).Error; err == nil {
+	if _, err := h.Likes.FindLike(ctx, uid, targetID); err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "Already liked"})
 		return
 	}
 	dislike := models.Dislike{
 		ID:        uuid.New(),
-		UserID:    uuid.MustParse(userID),
-		TargetID:  uuid.MustParse(input.TargetID),
+		UserID:    uid,
+		TargetID:  targetID,
 		CreatedAt: time.Now(),
 	}
-	db.Create(&dislike)
+	if err := h.Likes.CreateDislike(ctx, &dislike); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Already disliked"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not dislike user"})
+		return
+	}
+	metrics.Dislikes.Inc()
 	c.JSON(http.StatusCreated, dislike)
 }
 
 // GET /matches
 // @Summary List matches
-// @Description Get all matches for the current user.
+// @Description Get all matches for the current user, with who liked first, when each side liked, the source (like, super_like or rematch), the time to match and when the first message was sent.
 // @Tags interactions
 // @Produce json
 // @Success 200 {array} models.Match
 // @Router /api/matches [get]
-func GetMatches(c *gin.Context) {
+func (h *Handler) GetMatches(c *gin.Context) {
 	userID := c.GetString("user_id")
-	var matches []models.Match
-	db := config.GetDB()
-	db.Where("user1_id = ? OR user2_id = ?", userID, userID).Find(&matches)
+	matches, err := h.Matches.ListForUser(c.Request.Context(), uuid.MustParse(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not list matches"})
+		return
+	}
+	if matches == nil {
+		matches = []models.Match{}
+	}
+	for i := range matches {
+		matches[i].FillDerived()
+	}
 	c.JSON(http.StatusOK, matches)
 }
 
+// messageInput is the body of POST /message.
+type messageInput struct {
+	MatchID      string     `json:"match_id" binding:"required,uuid"`
+	Content      string     `json:"content"`
+	AttachmentID string     `json:"attachment_id" binding:"omitempty,uuid"`
+	ReplyToID    string     `json:"reply_to_id" binding:"omitempty,uuid"`
+	IcebreakerID string     `json:"icebreaker_id" binding:"omitempty,uuid"`
+	SendAt       *time.Time `json:"send_at"`
+}
+
+// messageDraft is a message that passed validation and access checks.
+type messageDraft struct {
+	match      models.Match
+	senderID   string
+	receiverID string
+	content    string
+	attachment *models.Attachment
+	replyTo    *models.MessagePreview
+	icebreaker *models.Icebreaker
+}
+
+// sendError is a reason a message cannot be sent and the response it maps to.
+// cause is the underlying error of a server-side failure.
+type sendError struct {
+	status int
+	body   gin.H
+	cause  error
+}
+
+func (e *sendError) Error() string {
+	msg, _ := e.body["error"].(string)
+	if fields, ok := e.body["fields"].(validation.Errors); ok {
+		return fmt.Sprintf("%s: %v", msg, fields)
+	}
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %v", msg, e.cause)
+	}
+	return msg
+}
+
+func invalidMessage(err error) *sendError {
+	return &sendError{status: http.StatusBadRequest, body: invalidBody(err)}
+}
+
+// sendFailed reports an error of the stores while sending a message.
+func sendFailed(err error) *sendError {
+	return &sendError{status: http.StatusInternalServerError, body: gin.H{"error": "Could not send message"}, cause: err}
+}
+
+// prepareMessage checks that userID may send in to its match now: content,
+// participation, match expiry, blocks, attachment and reply target.
+func (h *Handler) prepareMessage(ctx context.Context, userID string, in messageInput) (messageDraft, *sendError) {
+	draft := messageDraft{senderID: userID}
+	// Content is optional when the message carries an attachment.
+	if in.Content != "" || in.AttachmentID == "" {
+		var err error
+		if draft.content, err = validation.MessageContent(in.Content); err != nil {
+			return draft, invalidMessage(err)
+		}
+	}
+	// Check match exists and user is part of it
+	match, otherID, err := h.findParticipantMatch(ctx, in.MatchID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return draft, &sendError{status: http.StatusForbidden, body: gin.H{"error": "No such match or not a participant"}}
+	}
+	if err != nil {
+		return draft, sendFailed(err)
+	}
+	if match.ExpireAt != nil && match.ExpireAt.Before(time.Now()) {
+		return draft, &sendError{status: http.StatusForbidden, body: gin.H{"error": "Match expired"}}
+	}
+	blocked, err := h.isBlocked(ctx, userID, otherID)
+	if err != nil {
+		return draft, sendFailed(err)
+	}
+	if blocked {
+		return draft, &sendError{status: http.StatusForbidden, body: gin.H{"error": "Blocked"}}
+	}
+	draft.match, draft.receiverID = match, otherID
+	uid := uuid.MustParse(userID)
+	if in.AttachmentID != "" {
+		attachment, err := h.Attachments.FindUnsent(ctx, uuid.MustParse(in.AttachmentID), uid, match.ID)
+		if errors.Is(err, store.ErrNotFound) {
+			return draft, invalidMessage(validation.NewError("attachment_id", "must be an unsent attachment uploaded to this match"))
+		}
+		if err != nil {
+			return draft, sendFailed(err)
+		}
+		draft.attachment = &attachment
+	}
+	if in.ReplyToID != "" {
+		quoted, err := h.Messages.FindVisible(ctx, uuid.MustParse(in.ReplyToID), match.ID, uid)
+		if errors.Is(err, store.ErrNotFound) {
+			return draft, invalidMessage(validation.NewError("reply_to_id", "must be a message of this match"))
+		}
+		if err != nil {
+			return draft, sendFailed(err)
+		}
+		attachments, err := h.Attachments.ListForMessages(ctx, []uuid.UUID{quoted.ID})
+		if err != nil {
+			return draft, sendFailed(err)
+		}
+		draft.replyTo = previewOf(quoted, len(attachments) > 0, userID)
+	}
+	if in.IcebreakerID != "" {
+		icebreaker, err := h.Icebreakers.FindActive(ctx, uuid.MustParse(in.IcebreakerID))
+		if errors.Is(err, store.ErrNotFound) {
+			return draft, invalidMessage(validation.NewError("icebreaker_id", "must be an active icebreaker"))
+		}
+		if err != nil {
+			return draft, sendFailed(err)
+		}
+		draft.icebreaker = &icebreaker
+	}
+	return draft, nil
+}
+
+// commitMessage moderates draft and stores it, claiming its attachment and
+// tracking icebreakers sent and replied to.
+func (h *Handler) commitMessage(ctx context.Context, draft messageDraft) (models.Message, *sendError) {
+	verdict := moderation.Result{Action: moderation.Allow, Content: draft.content}
+	if draft.content != "" {
+		verdict = moderation.Default().Run(ctx, moderation.Input{
+			SenderID:   uuid.MustParse(draft.senderID),
+			ReceiverID: uuid.MustParse(draft.receiverID),
+			Content:    draft.content,
+		})
+	}
+	if verdict.Action == moderation.Reject {
+		h.recordModeration(ctx, nil, draft.senderID, draft.receiverID, verdict)
+		return models.Message{}, &sendError{status: http.StatusUnprocessableEntity, body: gin.H{"error": "Message rejected by moderation", "reason": verdict.Decisions[len(verdict.Decisions)-1].Reason}}
+	}
+	msg := models.Message{
+		ID:         uuid.New(),
+		MatchID:    draft.match.ID,
+		SenderID:   uuid.MustParse(draft.senderID),
+		ReceiverID: uuid.MustParse(draft.receiverID),
+		Content:    verdict.Content,
+		CreatedAt:  time.Now(),
+		Seen:       false,
+		Deleted:    false,
+		Held:       verdict.Action == moderation.Hold,
+		ReplyTo:    draft.replyTo,
+	}
+	if draft.replyTo != nil {
+		msg.ReplyToID = &draft.replyTo.ID
+	}
+	err := h.Atomic(ctx, func(s store.Stores) error {
+		if err := s.Messages.Create(ctx, &msg); err != nil {
+			return err
+		}
+		if err := s.Matches.MarkFirstMessage(ctx, draft.match.ID, msg.CreatedAt); err != nil {
+			return err
+		}
+		// The other participant's icebreakers are now replied to.
+		if err := s.Icebreakers.MarkReplied(ctx, draft.match.ID, msg.ReceiverID, msg.CreatedAt); err != nil {
+			return err
+		}
+		if draft.icebreaker != nil {
+			err := s.Icebreakers.RecordUsage(ctx, &models.IcebreakerUsage{
+				ID:           uuid.New(),
+				IcebreakerID: draft.icebreaker.ID,
+				MatchID:      draft.match.ID,
+				MessageID:    msg.ID,
+				SenderID:     msg.SenderID,
+				SentAt:       msg.CreatedAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if draft.attachment == nil {
+			return nil
+		}
+		// Claim the attachment atomically so it cannot be sent twice.
+		if err := s.Attachments.Claim(ctx, draft.attachment.ID, msg.ID); err != nil {
+			if errors.Is(err, store.ErrConflict) {
+				return errAttachmentSent
+			}
+			return err
+		}
+		attachment := *draft.attachment
+		attachment.MessageID = &msg.ID
+		msg.Attachments = []models.Attachment{attachment}
+		return nil
+	})
+	if errors.Is(err, errAttachmentSent) {
+		return msg, &sendError{status: http.StatusConflict, body: gin.H{"error": "Attachment already sent"}}
+	}
+	if err != nil {
+		return msg, sendFailed(err)
+	}
+	metrics.MessagesSent.WithLabelValues("message").Inc()
+	h.recordModeration(ctx, &msg.ID, draft.senderID, draft.receiverID, verdict)
+	return msg, nil
+}
+
 // POST /message
 // @Summary Send message
-// @Description Send a message to a matched user. Blocked users cannot send/receive messages.
+// @Description Send a message to a matched user. Blocked users and expired matches cannot send/receive messages. Content is NFC-normalised and trimmed; it must be non-empty, free of control characters and at most MESSAGE_MAX_RUNES characters. Content then goes through moderation, which may mask parts of it, hold it for review (hidden from the receiver) or reject it. With a future send_at the message is scheduled instead (202) and goes through the same checks and moderation when it is delivered.
 // @Tags interactions
 // @Accept json
 // @Produce json
-// @Param message body struct{match_id string; content string} true "Message content and match ID"
+// @Param message body struct{match_id string; content string; attachment_id string; reply_to_id string; icebreaker_id string; send_at string} true "Match ID, content, optional attachment ID (content may be empty when an attachment is sent), optional ID of a message of the same match being replied to, optional ID of the icebreaker the content came from and optional RFC 3339 delivery time"
 // @Success 201 {object} models.Message
+// @Success 202 {object} models.ScheduledMessage
 // @Failure 400 {object} map[string]string
 // @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Router /api/message [post]
-func PostMessage(c *gin.Context) {
+func (h *Handler) PostMessage(c *gin.Context) {
 	userID := c.GetString("user_id")
-	var input struct {
-		MatchID string `json:"match_id" binding:"required"`
-		Content string `json:"content" binding:"required"`
-	}
+	var input messageInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondInvalid(c, err)
 		return
 	}
-	// Check match exists and user is part of it
-	var match models.Match
-	db := config.GetDB()
-	if err := db.Where("id = ? AND (user1_id = ? OR user2_id = ?)", input.MatchID, userID, userID).First(&match).Error; err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": "No such match or not a participant"})
+	ctx := c.Request.Context()
+	draft, failure := h.prepareMessage(ctx, userID, input)
+	if failure == nil && input.SendAt != nil {
+		h.scheduleMessage(c, draft, input)
 		return
 	}
-	// Check for block between users
-	var block models.Block
-	var otherID string
-	if match.User1ID.String() == userID {
-		otherID = match.User2ID.String()
-	} else {
-		otherID = match.User1ID.String()
+	var msg models.Message
+	if failure == nil {
+		msg, failure = h.commitMessage(ctx, draft)
 	}
-	if err := db.Where("(user_id = ? AND blocked_id = ?) OR (user_id = ? AND blocked_id = ?)", userID, otherID, otherID, userID).First(&block).Error; err == nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Blocked"})
+	if failure != nil {
+		if failure.status >= http.StatusInternalServerError {
+			logging.From(c).Error("Sending message", "match_id", input.MatchID, "error", failure.cause)
+		}
+		c.JSON(failure.status, failure.body)
 		return
 	}
-	msg := models.Message{
-		ID:         uuid.New(),
-		SenderID:   uuid.MustParse(userID),
-		ReceiverID: uuid.MustParse(otherID),
-		Content:    input.Content,
-		CreatedAt:  time.Now(),
-		Seen:       false,
-		Deleted:    false,
-	}
-	db.Create(&msg)
 	c.JSON(http.StatusCreated, msg)
 }
 
 // GET /messages/:match_id
 // @Summary List messages
-// @Description Get all messages for a match (must be a participant).
+// @Description Get all messages for a match (must be a participant), with their attachments, aggregated reaction counts and a preview of the message they reply to (a tombstone if it was deleted). Messages held for moderation are only shown to their sender.
 // @Tags interactions
 // @Produce json
 // @Param match_id path string true "Match ID"
 // @Success 200 {array} models.Message
 // @Failure 403 {object} map[string]string
 // @Router /api/messages/{match_id} [get]
-func GetMessages(c *gin.Context) {
+func (h *Handler) GetMessages(c *gin.Context) {
 	userID := c.GetString("user_id")
-	matchID := c.Param("match_id")
-	var match models.Match
-	if err := config.DB.Where("id = ? AND (user1_id = ? OR user2_id = ?)", matchID, userID, userID).First(&match).Error; err != nil {
+	ctx := c.Request.Context()
+	uid := uuid.MustParse(userID)
+	matchID, err := uuid.Parse(c.Param("match_id"))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No such match or not a participant"})
+		return
+	}
+	match, err := h.Matches.FindForParticipant(ctx, matchID, uid)
+	if err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "No such match or not a participant"})
 		return
 	}
-	var messages []models.Message
-	db := config.GetDB()
-	db.Where(
-		"((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)) AND deleted = false",
-		match.User1ID, match.User2ID, match.User2ID, match.User1ID,
-	).Order("created_at asc").Find(&messages)
+	messages, err := h.Messages.ListConversation(ctx, match, uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not list messages"})
+		return
+	}
+	if messages == nil {
+		messages = []models.Message{}
+	}
+	if err := h.loadAttachments(ctx, messages); err == nil {
+		if err = h.loadReactionCounts(ctx, messages, userID); err == nil {
+			err = h.loadReplyPreviews(ctx, messages, userID)
+		}
+	}
+	if err != nil {
+		logging.From(c).Error("Loading message details", "match_id", matchID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not list messages"})
+		return
+	}
 	c.JSON(http.StatusOK, messages)
 }
 
+// DELETE /messages/:id
+// @Summary Delete message
+// @Description Soft-delete a message you sent. Deleted messages are hidden from the conversation and purged after the retention period.
+// @Tags interactions
+// @Produce json
+// @Param id path string true "Message ID"
+// @Success 200 {object} models.Message
+// @Failure 404 {object} map[string]string
+// @Router /api/messages/{id} [delete]
+func (h *Handler) DeleteMessage(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No such message"})
+		return
+	}
+	msg, err := h.Messages.FindSent(ctx, id, uuid.MustParse(userID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No such message"})
+		return
+	}
+	if err := h.Messages.SoftDelete(ctx, &msg, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not delete message"})
+		return
+	}
+	c.JSON(http.StatusOK, msg)
+}
+
 // POST /block
 // @Summary Block a user
 // @Description Block a user. Cleans up likes, dislikes, matches, and messages between users. Cannot block yourself or block twice.
@@ -245,38 +576,51 @@ func GetMessages(c *gin.Context) {
 // @Failure 400 {object} map[string]string
 // @Failure 409 {object} map[string]string
 // @Router /api/block [post]
-func PostBlock(c *gin.Context) {
+func (h *Handler) PostBlock(c *gin.Context) {
 	userID := c.GetString("user_id")
 	var input struct {
-		BlockedID string `json:"blocked_id" binding:"required"`
+		BlockedID string `json:"blocked_id" binding:"required,uuid"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondInvalid(c, err)
 		return
 	}
 	if userID == input.BlockedID {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot block yourself"})
 		return
 	}
-	db := config.GetDB()
+	ctx := c.Request.Context()
+	uid, blockedID := uuid.MustParse(userID), uuid.MustParse(input.BlockedID)
 	// Prevent duplicate block
-	var existing models.Block
-	if err := db.Where("user_id = ? AND blocked_id = ?", userID, input.BlockedID).First(&existing).Error; err == nil {
+	if _, err := h.Blocks.Find(ctx, uid, blockedID); err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "Already blocked"})
 		return
 	}
 	block := models.Block{
 		ID:        uuid.New(),
-		UserID:    uuid.MustParse(userID),
-		BlockedID: uuid.MustParse(input.BlockedID),
+		UserID:    uid,
+		BlockedID: blockedID,
 		CreatedAt: time.Now(),
 	}
-	db.Create(&block)
-	// Cleanup: delete likes, dislikes, matches, messages between users
-	db.Where("(user_id = ? AND target_id = ?) OR (user_id = ? AND target_id = ?)", userID, input.BlockedID, input.BlockedID, userID).Delete(&models.Like{})
-	db.Where("(user_id = ? AND target_id = ?) OR (user_id = ? AND target_id = ?)", userID, input.BlockedID, input.BlockedID, userID).Delete(&models.Dislike{})
-	db.Where("(user1_id = ? AND user2_id = ?) OR (user1_id = ? AND user2_id = ?)", userID, input.BlockedID, input.BlockedID, userID).Delete(&models.Match{})
-	db.Where("(sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)", userID, input.BlockedID, input.BlockedID, userID).Delete(&models.Message{})
+	// The block and the cleanup of likes, dislikes, matches and messages
+	// between the users are kept together, so a failed cleanup cannot leave
+	// a blocked pair still matched.
+	err := h.Atomic(ctx, func(s store.Stores) error {
+		if err := s.Blocks.Create(ctx, &block); err != nil {
+			return err
+		}
+		return deletePair(ctx, s, uid, blockedID)
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Already blocked"})
+			return
+		}
+		logging.From(c).Error("Storing block", "blocked_id", blockedID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not block user"})
+		return
+	}
+	metrics.Blocks.Inc()
 	c.JSON(http.StatusCreated, block)
 }
 
@@ -287,11 +631,16 @@ func PostBlock(c *gin.Context) {
 // @Produce json
 // @Success 200 {array} models.Block
 // @Router /api/blocks [get]
-func GetBlocks(c *gin.Context) {
+func (h *Handler) GetBlocks(c *gin.Context) {
 	userID := c.GetString("user_id")
-	var blocks []models.Block
-	db := config.GetDB()
-	db.Where("user_id = ?", userID).Find(&blocks)
+	blocks, err := h.Blocks.ListByUser(c.Request.Context(), uuid.MustParse(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not list blocks"})
+		return
+	}
+	if blocks == nil {
+		blocks = []models.Block{}
+	}
 	c.JSON(http.StatusOK, blocks)
 }
 
@@ -301,21 +650,39 @@ func GetBlocks(c *gin.Context) {
 // @Tags interactions
 // @Produce json
 // @Success 200 {array} string
+// @Failure 500 {object} map[string]string
 // @Router /api/exclusions [get]
-func GetExclusions(c *gin.Context) {
+func (h *Handler) GetExclusions(c *gin.Context) {
 	userID := c.GetString("user_id")
+	excluded, err := h.Likes.Excluded(c.Request.Context(), uuid.MustParse(userID))
+	if err != nil {
+		logging.From(c).Error("Listing exclusions", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not list exclusions"})
+		return
+	}
 	var exclusions []string
-	db := config.GetDB()
-	db.Raw(`
-		SELECT target_id FROM likes WHERE user_id = ?
-		UNION
-		SELECT target_id FROM dislikes WHERE user_id = ?
-		UNION
-		SELECT CASE WHEN user1_id = ? THEN user2_id ELSE user1_id END FROM matches WHERE user1_id = ? OR user2_id = ?
-		UNION
-		SELECT blocked_id FROM blocks WHERE user_id = ?
-		UNION
-		SELECT user_id FROM blocks WHERE blocked_id = ?
-	`, userID, userID, userID, userID, userID, userID, userID).Scan(&exclusions)
+	for _, id := range excluded {
+		exclusions = append(exclusions, id.String())
+	}
 	c.JSON(http.StatusOK, exclusions)
 }
+
+// deletePair deletes the likes, dislikes, matches and messages between a and
+// b. Messages go first, through the matches they were sent in.
+func deletePair(ctx context.Context, s store.Stores, a, b uuid.UUID) error {
+	matches, err := s.Matches.ListBetween(ctx, a, b)
+	if err != nil {
+		return err
+	}
+	matchIDs := make([]uuid.UUID, len(matches))
+	for i, match := range matches {
+		matchIDs[i] = match.ID
+	}
+	if err := s.Messages.DeleteForMatches(ctx, matchIDs); err != nil {
+		return err
+	}
+	if err := s.Matches.DeletePair(ctx, a, b); err != nil {
+		return err
+	}
+	return s.Likes.DeletePair(ctx, a, b)
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"way-d-interactions/logging"
+	"way-d-interactions/metrics"
+	"way-d-interactions/models"
+	"way-d-interactions/moderation"
+	"way-d-interactions/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// createNoteMessages turns the notes of likes into the first messages of
+// match, stored through s. Held notes become held messages, so that
+// noteMessagesSent puts them in the moderation queue.
+func createNoteMessages(ctx context.Context, s store.Stores, match models.Match, likes ...models.Like) ([]models.Message, error) {
+	var messages []models.Message
+	for _, like := range likes {
+		if like.Note == "" {
+			continue
+		}
+		msg := models.Message{
+			ID:         uuid.New(),
+			MatchID:    match.ID,
+			SenderID:   like.UserID,
+			ReceiverID: like.TargetID,
+			Content:    like.Note,
+			CreatedAt:  time.Now(),
+			Held:       like.NoteHeld,
+		}
+		if err := s.Messages.Create(ctx, &msg); err != nil {
+			return nil, err
+		}
+		if err := s.Matches.MarkFirstMessage(ctx, match.ID, msg.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// noteMessagesSent counts the messages created from notes and puts the held
+// ones in the moderation queue.
+func (h *Handler) noteMessagesSent(ctx context.Context, messages []models.Message) {
+	for _, msg := range messages {
+		metrics.MessagesSent.WithLabelValues("note").Inc()
+		if msg.Held {
+			h.recordModeration(ctx, &msg.ID, msg.SenderID.String(), msg.ReceiverID.String(), moderation.Result{
+				Decisions: []moderation.Decision{{Filter: "like_note", Action: moderation.Hold, Reason: "note was held when the like was sent"}},
+			})
+		}
+	}
+}
+
+// receivedLike is an incoming like awaiting an answer. The liker is only
+// identified by a pseudonym until the two users match.
+type receivedLike struct {
+	ID        uuid.UUID `json:"id"`
+	From      string    `json:"from"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GET /likes/received
+// @Summary Received likes
+// @Description Likes you received and have not answered yet, newest first, with their notes. Likers are shown as pseudonyms until you match. Notes held by moderation are left out, and likes from users you blocked, who blocked you or whom you disliked are hidden.
+// @Tags interactions
+// @Produce json
+// @Param limit query int false "Maximum number of likes (default 50, max 200)"
+// @Success 200 {array} receivedLike
+// @Failure 500 {object} map[string]string
+// @Router /api/likes/received [get]
+func (h *Handler) GetReceivedLikes(c *gin.Context) {
+	userID := c.GetString("user_id")
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	likes, err := h.Likes.ListReceived(c.Request.Context(), uuid.MustParse(userID), limit)
+	if err != nil {
+		logging.From(c).Error("Listing received likes", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not list likes"})
+		return
+	}
+	received := make([]receivedLike, len(likes))
+	for i, l := range likes {
+		received[i] = receivedLike{ID: l.ID, From: pseudonymFor(userID, l.UserID), CreatedAt: l.CreatedAt}
+		if !l.NoteHeld {
+			received[i].Note = l.Note
+		}
+	}
+	c.JSON(http.StatusOK, received)
+}
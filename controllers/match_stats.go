@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"way-d-interactions/logging"
+	"way-d-interactions/models"
+	"way-d-interactions/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /internal/analytics/matches
+// @Summary Match analytics
+// @Description Internal endpoint. Per match source, the number of matches created in the period, how many got a first message, the average time between the two likes and the average time from match to first message.
+// @Tags internal
+// @Produce json
+// @Param since query string false "RFC 3339 start of the period (default 30 days ago)"
+// @Param until query string false "RFC 3339 end of the period (default now)"
+// @Success 200 {array} models.MatchSourceStats
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /internal/analytics/matches [get]
+func (h *Handler) GetMatchAnalytics(c *gin.Context) {
+	until := time.Now()
+	since := until.AddDate(0, 0, -30)
+	for _, p := range []struct {
+		name string
+		dest *time.Time
+	}{{"since", &since}, {"until", &until}} {
+		if v := c.Query(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				respondInvalid(c, validation.NewError(p.name, "must be an RFC 3339 timestamp"))
+				return
+			}
+			*p.dest = t
+		}
+	}
+	stats, err := h.Matches.Stats(c.Request.Context(), since, until)
+	if err != nil {
+		logging.From(c).Error("Computing match analytics", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not compute match analytics"})
+		return
+	}
+	if stats == nil {
+		stats = []models.MatchSourceStats{}
+	}
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"way-d-interactions/config"
+	"way-d-interactions/models"
+	"way-d-interactions/store"
+
+	"github.com/google/uuid"
+)
+
+// findParticipantMatch loads matchID if userID takes part in it and returns
+// the other participant's ID. A matchID that is not a UUID is not found.
+func (h *Handler) findParticipantMatch(ctx context.Context, matchID, userID string) (models.Match, string, error) {
+	id, err := uuid.Parse(matchID)
+	if err != nil {
+		return models.Match{}, "", store.ErrNotFound
+	}
+	match, err := h.Matches.FindForParticipant(ctx, id, uuid.MustParse(userID))
+	if err != nil {
+		return match, "", err
+	}
+	if match.User1ID.String() == userID {
+		return match, match.User2ID.String(), nil
+	}
+	return match, match.User1ID.String(), nil
+}
+
+// matchExpiry returns when a match created at createdAt expires, or nil when
+// MATCH_TTL is 0 and matches do not expire.
+func matchExpiry(createdAt time.Time) *time.Time {
+	ttl := config.Get().Matches.TTL
+	if ttl <= 0 {
+		return nil
+	}
+	expireAt := createdAt.Add(ttl)
+	return &expireAt
+}
+
+// isBlocked reports whether either user blocked the other.
+func (h *Handler) isBlocked(ctx context.Context, userID, otherID string) (bool, error) {
+	return h.Blocks.Blocked(ctx, uuid.MustParse(userID), uuid.MustParse(otherID))
+}
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"way-d-interactions/logging"
+	"way-d-interactions/models"
+	"way-d-interactions/moderation"
+	"way-d-interactions/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// recordModeration stores every non-allow decision of a moderation verdict.
+// The verdict already took effect, so failures are only logged.
+func (h *Handler) recordModeration(ctx context.Context, messageID *uuid.UUID, senderID, receiverID string, verdict moderation.Result) {
+	for _, d := range verdict.Decisions {
+		err := h.Moderation.Record(ctx, &models.ModerationDecision{
+			ID:         uuid.New(),
+			MessageID:  messageID,
+			SenderID:   uuid.MustParse(senderID),
+			ReceiverID: uuid.MustParse(receiverID),
+			Filter:     d.Filter,
+			Action:     d.Action.String(),
+			Reason:     d.Reason,
+			CreatedAt:  time.Now(),
+		})
+		if err != nil {
+			logging.FromContext(ctx).Error("Recording moderation decision", "filter", d.Filter, "message_id", messageID, "error", err)
+		}
+	}
+}
+
+type moderationQueueItem struct {
+	Message   models.Message              `json:"message"`
+	Decisions []models.ModerationDecision `json:"decisions"`
+}
+
+// GET /internal/moderation/queue
+// @Summary Moderation queue
+// @Description Internal endpoint. Lists messages held for review, oldest first, with the decisions that held them.
+// @Tags internal
+// @Produce json
+// @Param limit query int false "Maximum number of items (default 50, max 200)"
+// @Success 200 {array} moderationQueueItem
+// @Failure 500 {object} map[string]string
+// @Router /internal/moderation/queue [get]
+func (h *Handler) GetModerationQueue(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	ctx := c.Request.Context()
+	held, err := h.Messages.ListHeld(ctx, limit)
+	if err != nil {
+		logging.From(c).Error("Listing held messages", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not list moderation queue"})
+		return
+	}
+	ids := make([]uuid.UUID, len(held))
+	for i, m := range held {
+		ids[i] = m.ID
+	}
+	decisions, err := h.Moderation.ListForMessages(ctx, ids)
+	if err != nil {
+		logging.From(c).Error("Listing moderation decisions", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not list moderation queue"})
+		return
+	}
+	byMessage := make(map[uuid.UUID][]models.ModerationDecision)
+	for _, d := range decisions {
+		byMessage[*d.MessageID] = append(byMessage[*d.MessageID], d)
+	}
+	items := make([]moderationQueueItem, len(held))
+	for i, m := range held {
+		items[i] = moderationQueueItem{Message: m, Decisions: byMessage[m.ID]}
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// POST /internal/moderation/messages/:id/review
+// @Summary Review held message
+// @Description Internal endpoint. Approve a held message to deliver it, or reject it to delete it. The review is recorded as a decision.
+// @Tags internal
+// @Accept json
+// @Produce json
+// @Param id path string true "Message ID"
+// @Param review body struct{action string} true "approve or reject"
+// @Success 200 {object} models.Message
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /internal/moderation/messages/{id}/review [post]
+func (h *Handler) PostModerationReview(c *gin.Context) {
+	var input struct {
+		Action string `json:"action" binding:"required,oneof=approve reject"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondInvalid(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No such held message"})
+		return
+	}
+	msg, err := h.Messages.FindHeld(ctx, id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No such held message"})
+			return
+		}
+		logging.From(c).Error("Loading held message", "message_id", id, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not review message"})
+		return
+	}
+	action := moderation.Allow
+	if input.Action == "approve" {
+		err = h.Messages.Release(ctx, &msg)
+	} else {
+		action = moderation.Reject
+		err = h.Messages.SoftDelete(ctx, &msg, time.Now())
+	}
+	if err != nil {
+		logging.From(c).Error("Reviewing held message", "message_id", id, "action", input.Action, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not review message"})
+		return
+	}
+	h.recordModeration(ctx, &msg.ID, msg.SenderID.String(), msg.ReceiverID.String(), moderation.Result{
+		Decisions: []moderation.Decision{{Filter: "manual_review", Action: action, Reason: "reviewed by a moderator"}},
+	})
+	c.JSON(http.StatusOK, msg)
+}
@@ -0,0 +1,240 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"way-d-interactions/logging"
+	"way-d-interactions/models"
+	"way-d-interactions/realtime"
+	"way-d-interactions/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Realtime event types for typing indicators and presence.
+const (
+	EventTyping          = "typing"
+	EventPresenceChanged = "presence.changed"
+)
+
+// typingTTL is how long clients should show a typing indicator without a new
+// typing event. Clients refresh it while the user keeps typing.
+const typingTTL = 6 * time.Second
+
+type typingEvent struct {
+	MatchID     uuid.UUID `json:"match_id"`
+	UserID      uuid.UUID `json:"user_id"`
+	Typing      bool      `json:"typing"`
+	ExpiresInMS int64     `json:"expires_in_ms"`
+}
+
+// PresenceStatus is a match participant's presence. LastSeen is omitted when
+// either user hides their last seen time.
+type PresenceStatus struct {
+	UserID   uuid.UUID  `json:"user_id"`
+	Online   bool       `json:"online"`
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+}
+
+// hidesLastSeen reports whether userID turned on the hide_last_seen setting.
+func (h *Handler) hidesLastSeen(ctx context.Context, userID string) (bool, error) {
+	settings, err := h.Settings.Find(ctx, uuid.MustParse(userID))
+	return settings.HideLastSeen, err
+}
+
+// presenceFor returns userID's presence as seen by viewerID. Hiding last
+// seen works both ways, as in most messengers.
+func (h *Handler) presenceFor(ctx context.Context, userID, viewerID string) (PresenceStatus, error) {
+	id := uuid.MustParse(userID)
+	out := PresenceStatus{UserID: id}
+	status, ok := realtime.DefaultPresence().Status(id)
+	if !ok {
+		return out, nil
+	}
+	out.Online = status.Online
+	if status.Online {
+		return out, nil
+	}
+	for _, user := range []string{userID, viewerID} {
+		if hidden, err := h.hidesLastSeen(ctx, user); err != nil || hidden {
+			return out, err
+		}
+	}
+	lastSeen := status.LastSeen.UTC()
+	out.LastSeen = &lastSeen
+	return out, nil
+}
+
+// findLiveConversation loads matchID for userID, provided the match has not
+// expired and neither participant blocked the other. It writes the error
+// response and returns false otherwise.
+func (h *Handler) findLiveConversation(c *gin.Context, matchID, userID string) (models.Match, string, bool) {
+	ctx := c.Request.Context()
+	match, otherID, err := h.findParticipantMatch(ctx, matchID, userID)
+	if err == nil && match.ExpireAt != nil && match.ExpireAt.Before(time.Now()) {
+		err = store.ErrNotFound
+	}
+	var blocked bool
+	if err == nil {
+		blocked, err = h.isBlocked(ctx, userID, otherID)
+	}
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "No such match"})
+		return match, "", false
+	case err != nil:
+		logging.From(c).Error("Loading conversation", "match_id", matchID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load match"})
+		return match, "", false
+	case blocked:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Blocked"})
+		return match, "", false
+	}
+	return match, otherID, true
+}
+
+// notifyPresence sends userID's new presence to the participants of their
+// live, unblocked matches that are currently connected. Presence is best
+// effort, so failures are only logged.
+func (h *Handler) notifyPresence(ctx context.Context, userID string) {
+	matches, err := h.Matches.ListOpen(ctx, uuid.MustParse(userID))
+	if err != nil {
+		logging.FromContext(ctx).Error("Listing matches to notify of presence", "error", err)
+		return
+	}
+	hub := realtime.Default()
+	for _, m := range matches {
+		other := m.User1ID
+		if other.String() == userID {
+			other = m.User2ID
+		}
+		if !hub.Connected(other) {
+			continue
+		}
+		presence, err := h.presenceFor(ctx, userID, other.String())
+		if err != nil {
+			logging.FromContext(ctx).Error("Loading presence", "match_id", m.ID, "error", err)
+			continue
+		}
+		hub.Publish(other, EventPresenceChanged, presence)
+	}
+}
+
+// POST /matches/:id/typing
+// @Summary Typing indicator
+// @Description Tell the other participant you started or stopped typing. They receive a typing event that expires after expires_in_ms unless refreshed. Nothing is stored.
+// @Tags interactions
+// @Accept json
+// @Param id path string true "Match ID"
+// @Param typing body struct{typing bool} false "Defaults to true"
+// @Success 204
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/matches/{id}/typing [post]
+func (h *Handler) PostTyping(c *gin.Context) {
+	userID := c.GetString("user_id")
+	var input struct {
+		Typing *bool `json:"typing"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			respondInvalid(c, err)
+			return
+		}
+	}
+	match, otherID, ok := h.findLiveConversation(c, c.Param("id"), userID)
+	if !ok {
+		return
+	}
+	typing := input.Typing == nil || *input.Typing
+	realtime.Default().Publish(uuid.MustParse(otherID), EventTyping, typingEvent{
+		MatchID:     match.ID,
+		UserID:      uuid.MustParse(userID),
+		Typing:      typing,
+		ExpiresInMS: typingTTL.Milliseconds(),
+	})
+	c.Status(http.StatusNoContent)
+}
+
+// GET /matches/:id/presence
+// @Summary Match presence
+// @Description Whether the other participant is online and, unless either of you hides it, when they were last seen.
+// @Tags interactions
+// @Produce json
+// @Param id path string true "Match ID"
+// @Success 200 {object} PresenceStatus
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/matches/{id}/presence [get]
+func (h *Handler) GetPresence(c *gin.Context) {
+	userID := c.GetString("user_id")
+	_, otherID, ok := h.findLiveConversation(c, c.Param("id"), userID)
+	if !ok {
+		return
+	}
+	presence, err := h.presenceFor(c.Request.Context(), otherID, userID)
+	if err != nil {
+		logging.From(c).Error("Loading presence", "match_id", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load presence"})
+		return
+	}
+	c.JSON(http.StatusOK, presence)
+}
+
+// GET /me/settings
+// @Summary Get settings
+// @Description Your privacy settings.
+// @Tags interactions
+// @Produce json
+// @Success 200 {object} models.UserSettings
+// @Failure 500 {object} map[string]string
+// @Router /api/me/settings [get]
+func (h *Handler) GetSettings(c *gin.Context) {
+	userID := c.GetString("user_id")
+	settings, err := h.Settings.Find(c.Request.Context(), uuid.MustParse(userID))
+	if err != nil {
+		logging.From(c).Error("Loading settings", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load settings"})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+// PUT /me/settings
+// @Summary Update settings
+// @Description Update your privacy settings. With hide_last_seen, match participants only see whether you are online, and you no longer see their last seen time.
+// @Tags interactions
+// @Accept json
+// @Produce json
+// @Param settings body struct{hide_last_seen bool} true "Settings"
+// @Success 200 {object} models.UserSettings
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/me/settings [put]
+func (h *Handler) PutSettings(c *gin.Context) {
+	userID := c.GetString("user_id")
+	var input struct {
+		HideLastSeen *bool `json:"hide_last_seen" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondInvalid(c, err)
+		return
+	}
+	settings := models.UserSettings{
+		UserID:       uuid.MustParse(userID),
+		HideLastSeen: *input.HideLastSeen,
+		UpdatedAt:    time.Now(),
+	}
+	if err := h.Settings.Save(c.Request.Context(), &settings); err != nil {
+		logging.From(c).Error("Saving settings", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not save settings"})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
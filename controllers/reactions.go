@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"way-d-interactions/logging"
+	"way-d-interactions/models"
+	"way-d-interactions/realtime"
+	"way-d-interactions/store"
+	"way-d-interactions/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// allowedReactions is the bounded set of emoji users can react with.
+var allowedReactions = []string{"❤️", "😂", "😮", "😢", "😡", "👍", "🔥"}
+
+// Realtime event types for reactions.
+const (
+	EventReactionAdded   = "reaction.added"
+	EventReactionRemoved = "reaction.removed"
+)
+
+type reactionEvent struct {
+	MessageID uuid.UUID `json:"message_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Emoji     string    `json:"emoji,omitempty"`
+}
+
+// findReactableMessage loads a message userID sent or received, provided the
+// match it was sent in is still live and neither user blocked the other.
+// Held messages can only be reacted to by their sender. Messages userID
+// cannot react to are reported as store.ErrNotFound.
+func (h *Handler) findReactableMessage(ctx context.Context, messageID, userID string) (models.Message, string, error) {
+	id, err := uuid.Parse(messageID)
+	if err != nil {
+		return models.Message{}, "", store.ErrNotFound
+	}
+	msg, err := h.Messages.FindForParticipant(ctx, id, uuid.MustParse(userID))
+	if err != nil {
+		return msg, "", err
+	}
+	if msg.Held && msg.SenderID.String() != userID {
+		return msg, "", store.ErrNotFound
+	}
+	// The conversation of an earlier match stays closed after a rematch.
+	match, otherID, err := h.findParticipantMatch(ctx, msg.MatchID.String(), userID)
+	if err != nil {
+		return msg, "", err
+	}
+	if match.ExpireAt != nil && match.ExpireAt.Before(time.Now()) {
+		return msg, "", store.ErrNotFound
+	}
+	blocked, err := h.isBlocked(ctx, userID, otherID)
+	if err != nil {
+		return msg, "", err
+	}
+	if blocked {
+		return msg, "", store.ErrNotFound
+	}
+	return msg, otherID, nil
+}
+
+// respondReactionError answers a failed findReactableMessage.
+func respondReactionError(c *gin.Context, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No such message"})
+		return
+	}
+	logging.From(c).Error("Loading message", "message_id", c.Param("id"), "error", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load message"})
+}
+
+// POST /messages/:id/reactions
+// @Summary React to message
+// @Description React to a message in one of your matches. A user has one reaction per message; reacting again replaces it. The other participant receives a reaction.added event.
+// @Tags interactions
+// @Accept json
+// @Produce json
+// @Param id path string true "Message ID"
+// @Param reaction body struct{emoji string} true "One of ❤️ 😂 😮 😢 😡 👍 🔥"
+// @Success 200 {object} models.Reaction
+// @Success 201 {object} models.Reaction
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/messages/{id}/reactions [post]
+func (h *Handler) PostReaction(c *gin.Context) {
+	userID := c.GetString("user_id")
+	var input struct {
+		Emoji string `json:"emoji" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondInvalid(c, err)
+		return
+	}
+	allowed := false
+	for _, e := range allowedReactions {
+		allowed = allowed || e == input.Emoji
+	}
+	if !allowed {
+		respondInvalid(c, validation.NewError("emoji", "must be one of: "+strings.Join(allowedReactions, " ")))
+		return
+	}
+	ctx := c.Request.Context()
+	msg, otherID, err := h.findReactableMessage(ctx, c.Param("id"), userID)
+	if err != nil {
+		respondReactionError(c, err)
+		return
+	}
+	status := http.StatusOK
+	reaction, err := h.Reactions.Find(ctx, msg.ID, uuid.MustParse(userID))
+	switch {
+	case err == nil:
+		err = h.Reactions.SetEmoji(ctx, &reaction, input.Emoji)
+	case errors.Is(err, store.ErrNotFound):
+		reaction = models.Reaction{
+			ID:        uuid.New(),
+			MessageID: msg.ID,
+			UserID:    uuid.MustParse(userID),
+			Emoji:     input.Emoji,
+			CreatedAt: time.Now(),
+		}
+		err = h.Reactions.Create(ctx, &reaction)
+		status = http.StatusCreated
+		// A concurrent request created the reaction first; replace it as if
+		// it had been found.
+		if errors.Is(err, store.ErrConflict) {
+			status = http.StatusOK
+			if reaction, err = h.Reactions.Find(ctx, msg.ID, uuid.MustParse(userID)); err == nil {
+				err = h.Reactions.SetEmoji(ctx, &reaction, input.Emoji)
+			}
+		}
+	}
+	if err != nil {
+		logging.From(c).Error("Saving reaction failed", "message_id", msg.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not save reaction"})
+		return
+	}
+	realtime.Default().Publish(uuid.MustParse(otherID), EventReactionAdded, reactionEvent{MessageID: msg.ID, UserID: reaction.UserID, Emoji: reaction.Emoji})
+	c.JSON(status, reaction)
+}
+
+// DELETE /messages/:id/reactions
+// @Summary Remove reaction
+// @Description Remove your reaction to a message. The other participant receives a reaction.removed event.
+// @Tags interactions
+// @Produce json
+// @Param id path string true "Message ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/messages/{id}/reactions [delete]
+func (h *Handler) DeleteReaction(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ctx := c.Request.Context()
+	msg, otherID, err := h.findReactableMessage(ctx, c.Param("id"), userID)
+	if err != nil {
+		respondReactionError(c, err)
+		return
+	}
+	err = h.Reactions.Delete(ctx, msg.ID, uuid.MustParse(userID))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No reaction to remove"})
+		return
+	}
+	if err != nil {
+		logging.From(c).Error("Removing reaction failed", "message_id", msg.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not remove reaction"})
+		return
+	}
+	realtime.Default().Publish(uuid.MustParse(otherID), EventReactionRemoved, reactionEvent{MessageID: msg.ID, UserID: uuid.MustParse(userID)})
+	c.Status(http.StatusNoContent)
+}
+
+// loadReactionCounts fills in the aggregated Reactions of messages as seen
+// by userID.
+func (h *Handler) loadReactionCounts(ctx context.Context, messages []models.Message, userID string) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(messages))
+	index := make(map[uuid.UUID]int, len(messages))
+	for i, m := range messages {
+		ids[i] = m.ID
+		index[m.ID] = i
+	}
+	counts, err := h.Reactions.Counts(ctx, ids, uuid.MustParse(userID))
+	if err != nil {
+		return err
+	}
+	for _, rc := range counts {
+		i := index[rc.MessageID]
+		messages[i].Reactions = append(messages[i].Reactions, rc)
+	}
+	return nil
+}
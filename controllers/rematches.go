@@ -0,0 +1,318 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"way-d-interactions/config"
+	"way-d-interactions/logging"
+	"way-d-interactions/metrics"
+	"way-d-interactions/models"
+	"way-d-interactions/realtime"
+	"way-d-interactions/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Realtime event types for match extensions and rematches.
+const (
+	EventMatchExtended    = "match.extended"
+	EventRematchRequested = "rematch.requested"
+	EventRematchAccepted  = "rematch.accepted"
+)
+
+var errExtensionQuota = errors.New("extension quota used")
+
+// POST /matches/:id/extend
+// @Summary Extend match
+// @Description Push back the expiry of a match you take part in by MATCH_EXTENSION_DURATION. Each participant may extend a match MATCH_EXTENSIONS_PER_USER times. The other participant receives a match.extended event.
+// @Tags interactions
+// @Produce json
+// @Param id path string true "Match ID"
+// @Success 200 {object} models.Match
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/matches/{id}/extend [post]
+func (h *Handler) PostMatchExtension(c *gin.Context) {
+	userID := c.GetString("user_id")
+	match, otherID, ok := h.findLiveConversation(c, c.Param("id"), userID)
+	if !ok {
+		return
+	}
+	if match.ExpireAt == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Match does not expire"})
+		return
+	}
+	duration, perUser := config.Get().Matches.ExtensionDuration, config.Get().Matches.ExtensionsPerUser
+	ctx := c.Request.Context()
+	uid := uuid.MustParse(userID)
+	err := h.Atomic(ctx, func(s store.Stores) error {
+		// Lock the match so concurrent extensions are counted one at a time.
+		locked, err := s.Matches.Lock(ctx, match.ID)
+		if err != nil {
+			return err
+		}
+		used, err := s.Matches.CountExtensions(ctx, match.ID, uid)
+		if err != nil {
+			return err
+		}
+		if used >= perUser {
+			return errExtensionQuota
+		}
+		extension := models.MatchExtension{
+			ID:               uuid.New(),
+			MatchID:          match.ID,
+			UserID:           uid,
+			PreviousExpireAt: *locked.ExpireAt,
+			ExpireAt:         locked.ExpireAt.Add(duration),
+			CreatedAt:        time.Now(),
+		}
+		if err := s.Matches.Extend(ctx, &extension); err != nil {
+			return err
+		}
+		locked.ExpireAt = &extension.ExpireAt
+		match = locked
+		return nil
+	})
+	if errors.Is(err, errExtensionQuota) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Extension quota used for this match"})
+		return
+	}
+	if err != nil {
+		logging.From(c).Error("Extending match", "match_id", match.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not extend match"})
+		return
+	}
+	realtime.Default().Publish(uuid.MustParse(otherID), EventMatchExtended, match)
+	c.JSON(http.StatusOK, match)
+}
+
+// POST /matches/:id/rematch
+// @Summary Request rematch
+// @Description Ask the other participant of an expired match to revive it. Each participant can ask once per match. The other participant receives a rematch.requested event.
+// @Tags interactions
+// @Produce json
+// @Param id path string true "Expired match ID"
+// @Success 201 {object} models.RematchRequest
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/matches/{id}/rematch [post]
+func (h *Handler) PostRematchRequest(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ctx := c.Request.Context()
+	match, otherID, err := h.findParticipantMatch(ctx, c.Param("id"), userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No such match"})
+		return
+	}
+	var blocked bool
+	if err == nil {
+		blocked, err = h.isBlocked(ctx, userID, otherID)
+	}
+	if err != nil {
+		logging.From(c).Error("Loading match", "match_id", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not request rematch"})
+		return
+	}
+	if blocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Blocked"})
+		return
+	}
+	now := time.Now()
+	if match.ExpireAt == nil || match.ExpireAt.After(now) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Match has not expired"})
+		return
+	}
+	uid, otherUID := uuid.MustParse(userID), uuid.MustParse(otherID)
+	live, err := h.Matches.HasLive(ctx, uid, otherUID)
+	if err != nil {
+		logging.From(c).Error("Checking live matches", "match_id", match.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not request rematch"})
+		return
+	}
+	if live {
+		c.JSON(http.StatusConflict, gin.H{"error": "Already matched again"})
+		return
+	}
+	if _, err := h.Rematches.Find(ctx, match.ID, uid); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Rematch already requested"})
+		return
+	}
+	request := models.RematchRequest{
+		ID:          uuid.New(),
+		MatchID:     match.ID,
+		RequesterID: uid,
+		TargetID:    otherUID,
+		Status:      models.RematchStatusPending,
+		CreatedAt:   now,
+	}
+	if err := h.Rematches.Create(ctx, &request); err != nil {
+		if store.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Rematch already requested"})
+			return
+		}
+		logging.From(c).Error("Saving rematch request", "match_id", match.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not request rematch"})
+		return
+	}
+	realtime.Default().Publish(request.TargetID, EventRematchRequested, request)
+	c.JSON(http.StatusCreated, request)
+}
+
+// GET /rematches
+// @Summary Pending rematch requests
+// @Description Rematch requests you received and have not answered yet, newest first.
+// @Tags interactions
+// @Produce json
+// @Success 200 {array} models.RematchRequest
+// @Failure 500 {object} map[string]string
+// @Router /api/rematches [get]
+func (h *Handler) GetRematchRequests(c *gin.Context) {
+	userID := c.GetString("user_id")
+	requests, err := h.Rematches.ListPending(c.Request.Context(), uuid.MustParse(userID))
+	if err != nil {
+		logging.From(c).Error("Listing rematch requests", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not list rematch requests"})
+		return
+	}
+	if requests == nil {
+		requests = []models.RematchRequest{}
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+// findPendingRematch loads a pending rematch request addressed to userID. It
+// writes the error response and returns false otherwise.
+func (h *Handler) findPendingRematch(c *gin.Context, userID string) (models.RematchRequest, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	var request models.RematchRequest
+	if err == nil {
+		request, err = h.Rematches.FindPending(c.Request.Context(), id, uuid.MustParse(userID))
+	} else {
+		err = store.ErrNotFound
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No such rematch request"})
+		return request, false
+	}
+	if err != nil {
+		logging.From(c).Error("Loading rematch request", "rematch_id", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load rematch request"})
+		return request, false
+	}
+	return request, true
+}
+
+// POST /rematches/:id/accept
+// @Summary Accept rematch
+// @Description Accept a rematch request: a new match is created with the expired match as previous_match_id and the same lifetime as the expired one. The requester receives a rematch.accepted event.
+// @Tags interactions
+// @Produce json
+// @Param id path string true "Rematch request ID"
+// @Success 201 {object} models.Match
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/rematches/{id}/accept [post]
+func (h *Handler) AcceptRematchRequest(c *gin.Context) {
+	userID := c.GetString("user_id")
+	request, ok := h.findPendingRematch(c, userID)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	requesterID := request.RequesterID.String()
+	blocked, err := h.isBlocked(ctx, userID, requesterID)
+	var live bool
+	if err == nil && !blocked {
+		live, err = h.Matches.HasLive(ctx, request.TargetID, request.RequesterID)
+	}
+	if err != nil {
+		logging.From(c).Error("Checking rematch", "rematch_id", request.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not accept rematch"})
+		return
+	}
+	if blocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Blocked"})
+		return
+	}
+	if live {
+		c.JSON(http.StatusConflict, gin.H{"error": "Already matched again"})
+		return
+	}
+	previous, err := h.Matches.Find(ctx, request.MatchID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No such match"})
+		return
+	}
+	if err != nil {
+		logging.From(c).Error("Loading expired match", "match_id", request.MatchID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not accept rematch"})
+		return
+	}
+	now := time.Now()
+	match := models.Match{
+		ID:              uuid.New(),
+		User1ID:         request.RequesterID,
+		User2ID:         request.TargetID,
+		CreatedAt:       now,
+		ExpireAt:        matchExpiry(now),
+		PreviousMatchID: &previous.ID,
+		InitiatorID:     &request.RequesterID,
+		// For a rematch the "likes" are the request and its acceptance.
+		InitiatorLikedAt: &request.CreatedAt,
+		ResponderLikedAt: &now,
+		Source:           models.MatchSourceRematch,
+	}
+	err = h.Atomic(ctx, func(s store.Stores) error {
+		// A crossed request from the other side is answered by this match too.
+		if err := s.Rematches.Accept(ctx, request, match.ID, now); err != nil {
+			return err
+		}
+		return s.Matches.Create(ctx, &match)
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No such rematch request"})
+		return
+	}
+	if err != nil {
+		logging.From(c).Error("Accepting rematch", "rematch_id", request.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not accept rematch"})
+		return
+	}
+	metrics.MatchesCreated.WithLabelValues(match.Source).Inc()
+	match.FillDerived()
+	realtime.Default().Publish(request.RequesterID, EventRematchAccepted, match)
+	c.JSON(http.StatusCreated, match)
+}
+
+// POST /rematches/:id/decline
+// @Summary Decline rematch
+// @Description Decline a rematch request. The requester is not notified and cannot ask again for the same match.
+// @Tags interactions
+// @Produce json
+// @Param id path string true "Rematch request ID"
+// @Success 200 {object} models.RematchRequest
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/rematches/{id}/decline [post]
+func (h *Handler) DeclineRematchRequest(c *gin.Context) {
+	userID := c.GetString("user_id")
+	request, ok := h.findPendingRematch(c, userID)
+	if !ok {
+		return
+	}
+	if err := h.Rematches.Decline(c.Request.Context(), &request, time.Now()); err != nil {
+		logging.From(c).Error("Declining rematch", "rematch_id", request.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not decline rematch"})
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"way-d-interactions/models"
+
+	"github.com/google/uuid"
+)
+
+// replyPreviewRunes is the length of the quoted content shown in a preview.
+const replyPreviewRunes = 100
+
+// previewOf returns the preview of quoted as seen by userID.
+func previewOf(quoted models.Message, hasAttachment bool, userID string) *models.MessagePreview {
+	if quoted.Deleted || (quoted.Held && quoted.SenderID.String() != userID) {
+		return &models.MessagePreview{ID: quoted.ID, Deleted: true}
+	}
+	content := quoted.Content
+	if utf8.RuneCountInString(content) > replyPreviewRunes {
+		content = string([]rune(content)[:replyPreviewRunes]) + "…"
+	}
+	senderID := quoted.SenderID
+	return &models.MessagePreview{ID: quoted.ID, SenderID: &senderID, Content: content, HasAttachment: hasAttachment}
+}
+
+// loadReplyPreviews fills in the ReplyTo previews of messages as seen by
+// userID. Quoted messages that no longer exist become tombstones.
+func (h *Handler) loadReplyPreviews(ctx context.Context, messages []models.Message, userID string) error {
+	var ids []uuid.UUID
+	for _, m := range messages {
+		if m.ReplyToID != nil {
+			ids = append(ids, *m.ReplyToID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	quoted, err := h.Messages.ListByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	attachments, err := h.Attachments.ListForMessages(ctx, ids)
+	if err != nil {
+		return err
+	}
+	hasAttachment := make(map[uuid.UUID]bool, len(attachments))
+	for _, a := range attachments {
+		hasAttachment[*a.MessageID] = true
+	}
+	byID := make(map[uuid.UUID]models.Message, len(quoted))
+	for _, q := range quoted {
+		byID[q.ID] = q
+	}
+	for i, m := range messages {
+		if m.ReplyToID == nil {
+			continue
+		}
+		if q, ok := byID[*m.ReplyToID]; ok {
+			messages[i].ReplyTo = previewOf(q, hasAttachment[q.ID], userID)
+		} else {
+			messages[i].ReplyTo = &models.MessagePreview{ID: *m.ReplyToID, Deleted: true}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"way-d-interactions/config"
+	"way-d-interactions/logging"
+	"way-d-interactions/models"
+	"way-d-interactions/store"
+	"way-d-interactions/validation"
+	"way-d-interactions/workers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxReportReasonRunes bounds the free-text reason of a report.
+const maxReportReasonRunes = 500
+
+// POST /messages/:id/report
+// @Summary Report message
+// @Description Report a message you received. Reported messages are kept until the report is closed, even if deleted.
+// @Tags interactions
+// @Accept json
+// @Produce json
+// @Param id path string true "Message ID"
+// @Param report body struct{reason string} true "Reason"
+// @Success 201 {object} models.Report
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/messages/{id}/report [post]
+func (h *Handler) PostReport(c *gin.Context) {
+	userID := c.GetString("user_id")
+	var input struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondInvalid(c, err)
+		return
+	}
+	reason, err := validation.Text("reason", input.Reason, maxReportReasonRunes)
+	if err != nil {
+		respondInvalid(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	uid := uuid.MustParse(userID)
+	id, err := uuid.Parse(c.Param("id"))
+	var msg models.Message
+	if err == nil {
+		msg, err = h.Messages.FindReceived(ctx, id, uid)
+	} else {
+		err = store.ErrNotFound
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No such message"})
+		return
+	}
+	if err == nil {
+		_, err = h.Reports.FindOpen(ctx, msg.ID, uid)
+		if err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Already reported"})
+			return
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			err = nil
+		}
+	}
+	if err != nil {
+		logging.From(c).Error("Loading reported message", "message_id", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not report message"})
+		return
+	}
+	report := models.Report{
+		ID:         uuid.New(),
+		MessageID:  msg.ID,
+		ReporterID: uid,
+		Reason:     reason,
+		Status:     models.ReportStatusOpen,
+		CreatedAt:  time.Now(),
+	}
+	if err := h.Reports.Create(ctx, &report); err != nil {
+		logging.From(c).Error("Saving report", "message_id", msg.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not report message"})
+		return
+	}
+	c.JSON(http.StatusCreated, report)
+}
+
+// POST /internal/reports/:id/close
+// @Summary Close report
+// @Description Internal endpoint. Closes a report so the retention purge may remove the reported message.
+// @Tags internal
+// @Produce json
+// @Param id path string true "Report ID"
+// @Success 200 {object} models.Report
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /internal/reports/{id}/close [post]
+func (h *Handler) CloseReport(c *gin.Context) {
+	var report models.Report
+	id, err := uuid.Parse(c.Param("id"))
+	if err == nil {
+		report, err = h.Reports.Close(c.Request.Context(), id, time.Now())
+	} else {
+		err = store.ErrNotFound
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No such open report"})
+		return
+	}
+	if err != nil {
+		logging.From(c).Error("Closing report", "report_id", id, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not close report"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// POST /internal/retention/purge
+// @Summary Run retention purge
+// @Description Internal endpoint. Runs the message retention purge once with the configured policy. Pass dry_run=true to only count the messages that would be removed.
+// @Tags internal
+// @Produce json
+// @Param dry_run query bool false "Count without deleting"
+// @Success 200 {object} workers.PurgeReport
+// @Failure 500 {object} map[string]string
+// @Router /internal/retention/purge [post]
+func (h *Handler) PostRetentionPurge(c *gin.Context) {
+	policy := workers.RetentionPolicyFrom(config.Get().Retention)
+	if c.Query("dry_run") == "true" {
+		policy.DryRun = true
+	}
+	report, err := h.Messages.Purge(c.Request.Context(), policy)
+	if err != nil {
+		logging.From(c).Error("Retention purge failed", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Purge failed", "report": report})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
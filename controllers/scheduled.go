@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"way-d-interactions/config"
+	"way-d-interactions/logging"
+	"way-d-interactions/models"
+	"way-d-interactions/store"
+	"way-d-interactions/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// scheduleMessage stores draft, which passed prepareMessage, for delivery at
+// in.SendAt.
+func (h *Handler) scheduleMessage(c *gin.Context, draft messageDraft, in messageInput) {
+	userID := draft.senderID
+	now := time.Now()
+	maxAhead, maxPending := config.Get().Messages.ScheduledMaxAhead, config.Get().Messages.ScheduledMaxPending
+	if !in.SendAt.After(now) {
+		respondInvalid(c, validation.NewError("send_at", "must be in the future"))
+		return
+	}
+	if in.SendAt.After(now.Add(maxAhead)) {
+		respondInvalid(c, validation.NewError("send_at", "must be within "+maxAhead.String()))
+		return
+	}
+	ctx := c.Request.Context()
+	pending, err := h.Scheduled.CountPending(ctx, uuid.MustParse(userID))
+	if err != nil {
+		logging.From(c).Error("Counting scheduled messages", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not schedule message"})
+		return
+	}
+	if pending >= maxPending {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many scheduled messages"})
+		return
+	}
+	scheduled := models.ScheduledMessage{
+		ID:        uuid.New(),
+		SenderID:  uuid.MustParse(userID),
+		MatchID:   uuid.MustParse(in.MatchID),
+		Content:   draft.content,
+		SendAt:    in.SendAt.UTC(),
+		Status:    models.ScheduledStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if in.AttachmentID != "" {
+		id := uuid.MustParse(in.AttachmentID)
+		scheduled.AttachmentID = &id
+	}
+	if in.ReplyToID != "" {
+		id := uuid.MustParse(in.ReplyToID)
+		scheduled.ReplyToID = &id
+	}
+	if draft.icebreaker != nil {
+		scheduled.IcebreakerID = &draft.icebreaker.ID
+	}
+	if err := h.Scheduled.Create(ctx, &scheduled); err != nil {
+		logging.From(c).Error("Scheduling message", "match_id", scheduled.MatchID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not schedule message"})
+		return
+	}
+	c.JSON(http.StatusAccepted, scheduled)
+}
+
+// DeliverScheduledMessage sends a due scheduled message with the same checks
+// as POST /message, re-checking the match, its expiry and blocks. failure is
+// a permanent reason the message cannot be sent; err is a transient error
+// worth retrying.
+func (h *Handler) DeliverScheduledMessage(ctx context.Context, scheduled models.ScheduledMessage) (messageID uuid.UUID, failure string, err error) {
+	in := messageInput{MatchID: scheduled.MatchID.String(), Content: scheduled.Content}
+	if scheduled.AttachmentID != nil {
+		in.AttachmentID = scheduled.AttachmentID.String()
+	}
+	if scheduled.ReplyToID != nil {
+		in.ReplyToID = scheduled.ReplyToID.String()
+	}
+	if scheduled.IcebreakerID != nil {
+		in.IcebreakerID = scheduled.IcebreakerID.String()
+	}
+	draft, sendErr := h.prepareMessage(ctx, scheduled.SenderID.String(), in)
+	if sendErr == nil {
+		var msg models.Message
+		if msg, sendErr = h.commitMessage(ctx, draft); sendErr == nil {
+			return msg.ID, "", nil
+		}
+	}
+	if sendErr.status >= http.StatusInternalServerError {
+		return uuid.Nil, "", sendErr
+	}
+	return uuid.Nil, sendErr.Error(), nil
+}
+
+// GET /messages/scheduled
+// @Summary List scheduled messages
+// @Description Your scheduled messages that are still pending, soonest first. With status=failed, those that could not be delivered and why.
+// @Tags interactions
+// @Produce json
+// @Param status query string false "pending (default) or failed"
+// @Success 200 {array} models.ScheduledMessage
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/messages/scheduled [get]
+func (h *Handler) GetScheduledMessages(c *gin.Context) {
+	userID := c.GetString("user_id")
+	status := c.DefaultQuery("status", models.ScheduledStatusPending)
+	if status != models.ScheduledStatusPending && status != models.ScheduledStatusFailed {
+		respondInvalid(c, validation.NewError("status", "must be pending or failed"))
+		return
+	}
+	scheduled, err := h.Scheduled.List(c.Request.Context(), uuid.MustParse(userID), status)
+	if err != nil {
+		logging.From(c).Error("Listing scheduled messages", "status", status, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not list scheduled messages"})
+		return
+	}
+	if scheduled == nil {
+		scheduled = []models.ScheduledMessage{}
+	}
+	c.JSON(http.StatusOK, scheduled)
+}
+
+// DELETE /messages/scheduled/:id
+// @Summary Cancel scheduled message
+// @Description Cancel a pending scheduled message, or dismiss a failed one. Attachments it referenced are released for cleanup.
+// @Tags interactions
+// @Param id path string true "Scheduled message ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/messages/scheduled/{id} [delete]
+func (h *Handler) DeleteScheduledMessage(c *gin.Context) {
+	userID := c.GetString("user_id")
+	id, err := uuid.Parse(c.Param("id"))
+	if err == nil {
+		// The dispatcher locks the row while delivering, so a message being
+		// sent right now is either cancelled after delivery failed or
+		// already gone.
+		err = h.Scheduled.Delete(c.Request.Context(), id, uuid.MustParse(userID))
+	} else {
+		err = store.ErrNotFound
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No such scheduled message"})
+		return
+	}
+	if err != nil {
+		logging.From(c).Error("Deleting scheduled message", "scheduled_id", id, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not delete scheduled message"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"way-d-interactions/logging"
+	"way-d-interactions/models"
+	"way-d-interactions/store"
+	"way-d-interactions/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	searchDefaultLimit = 20
+	searchMaxLimit     = 50
+	searchMaxQueryRune = 200
+)
+
+// GET /messages/search
+// @Summary Search messages
+// @Description Full-text search over the messages of your current matches. Expired matches, deleted messages, messages held for moderation and blocked pairs are excluded. Snippets are HTML-escaped with matches wrapped in <mark>.
+// @Tags interactions
+// @Produce json
+// @Param q query string true "Search terms (web search syntax: quotes, OR, -)"
+// @Param limit query int false "Results per page (default 20, max 50)"
+// @Param offset query int false "Results to skip"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/messages/search [get]
+func (h *Handler) SearchMessages(c *gin.Context) {
+	userID := c.GetString("user_id")
+	query, err := validation.Text("q", c.Query("q"), searchMaxQueryRune)
+	if err != nil {
+		respondInvalid(c, err)
+		return
+	}
+	limit, offset := searchDefaultLimit, 0
+	if v := c.Query("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > searchMaxLimit {
+			respondInvalid(c, validation.NewError("limit", "must be between 1 and "+strconv.Itoa(searchMaxLimit)))
+			return
+		}
+	}
+	if v := c.Query("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			respondInvalid(c, validation.NewError("offset", "must be a non-negative integer"))
+			return
+		}
+	}
+	// Fetch one extra row to know whether another page exists.
+	results, err := h.Messages.Search(c.Request.Context(), uuid.MustParse(userID), query, limit+1, offset)
+	if err != nil {
+		logging.From(c).Error("Searching messages", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Search failed"})
+		return
+	}
+	if results == nil {
+		results = []models.SearchResult{}
+	}
+	hasMore := len(results) > limit
+	if hasMore {
+		results = results[:limit]
+	}
+	for i := range results {
+		snippet := html.EscapeString(results[i].Snippet)
+		snippet = strings.ReplaceAll(snippet, store.HighlightStart, "<mark>")
+		results[i].Snippet = strings.ReplaceAll(snippet, store.HighlightStop, "</mark>")
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results, "limit": limit, "offset": offset, "has_more": hasMore})
+}
@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"net/http"
+
+	"way-d-interactions/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// DELETE /internal/users/:id
+// @Summary Erase a deleted user's data
+// @Description Internal endpoint called when an account is deleted. Erases the user's likes, dislikes, matches, messages and blocks they made; blocks made against them are kept. Idempotent and resumable.
+// @Tags internal
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} erasure.Result
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /internal/users/{id} [delete]
+func (h *Handler) DeleteUserData(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	result, err := h.Erasure.Erase(c.Request.Context(), userID)
+	if err != nil {
+		logging.From(c).Error("Erasing user data", "erased_user_id", userID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erasure interrupted, retry to resume", "result": result})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
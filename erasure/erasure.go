@@ -0,0 +1,245 @@
+// Package erasure removes a deleted account's interaction data.
+//
+// Policy, applied in order:
+//   - likes:    every like sent by or to the user is deleted.
+//   - dislikes: every dislike sent by or to the user is deleted.
+//   - scheduled messages: messages the user scheduled, and messages
+//     scheduled in the user's matches, are deleted.
+//   - icebreaker usage: icebreakers sent by the user or in the user's
+//     matches are forgotten.
+//   - extensions and rematch requests of the user's matches are deleted.
+//   - moderation decisions on messages the user sent or received are deleted.
+//   - reports:  reports made by the user are deleted.
+//   - attachments: attachments the user uploaded are anonymised rather than
+//     deleted, so that the orphaned attachment cleanup still removes their
+//     files once their message is gone or, if never sent, once they expire.
+//   - reactions: reactions by the user, and reactions to messages of the
+//     user's matches, are deleted.
+//   - messages: every message of the user's matches is deleted; the
+//     conversation cannot be reached without the match anyway. Their
+//     attachments are then removed by the orphaned attachment cleanup.
+//   - matches:  every match the user is part of is deleted.
+//   - blocks:   blocks made by the user are deleted. Blocks made against the
+//     user are kept so the other user stays protected if the account comes
+//     back.
+//   - settings: the user's privacy settings are deleted.
+//
+// Rows are deleted in batches and the current step is recorded in
+// models.UserErasure, so an interrupted erasure resumes where it stopped.
+// Erasing an already erased user is a no-op that succeeds.
+package erasure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"way-d-interactions/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	StatusInProgress = "in_progress"
+	StatusCompleted  = "completed"
+)
+
+// BatchSize is the maximum number of rows deleted per statement.
+var BatchSize = 500
+
+// Result reports how many rows an erasure run removed, or anonymised for
+// attachments.
+type Result struct {
+	UserID              uuid.UUID `json:"user_id"`
+	Status              string    `json:"status"`
+	Likes               int64     `json:"likes"`
+	Dislikes            int64     `json:"dislikes"`
+	Matches             int64     `json:"matches"`
+	ScheduledMessages   int64     `json:"scheduled_messages"`
+	IcebreakerUsages    int64     `json:"icebreaker_usages"`
+	MatchExtensions     int64     `json:"match_extensions"`
+	RematchRequests     int64     `json:"rematch_requests"`
+	ModerationDecisions int64     `json:"moderation_decisions"`
+	Reports             int64     `json:"reports"`
+	Attachments         int64     `json:"attachments"`
+	Reactions           int64     `json:"reactions"`
+	Messages            int64     `json:"messages"`
+	Blocks              int64     `json:"blocks"`
+	Settings            int64     `json:"settings"`
+}
+
+type step struct {
+	name  string
+	model interface{}
+	where string
+	count func(*Result) *int64
+	// key is the primary key column, "id" when empty.
+	key string
+	// anonymise, when set, is the column set to the nil UUID instead of
+	// deleting the rows.
+	anonymise string
+}
+
+// erasedMessages selects the messages of the user's matches, and the ones
+// they sent or received whose match is unknown (a NULL match_id left by
+// migration 0004).
+const erasedMessages = "sender_id = @id OR receiver_id = @id OR match_id IN (SELECT id FROM matches WHERE user1_id = @id OR user2_id = @id)"
+
+var steps = []step{
+	{"likes", &models.Like{}, "user_id = @id OR target_id = @id", func(r *Result) *int64 { return &r.Likes }, "", ""},
+	{"dislikes", &models.Dislike{}, "user_id = @id OR target_id = @id", func(r *Result) *int64 { return &r.Dislikes }, "", ""},
+	{"scheduled_messages", &models.ScheduledMessage{}, "sender_id = @id OR match_id IN (SELECT id FROM matches WHERE user1_id = @id OR user2_id = @id)", func(r *Result) *int64 { return &r.ScheduledMessages }, "", ""},
+	{"icebreaker_usages", &models.IcebreakerUsage{}, "sender_id = @id OR match_id IN (SELECT id FROM matches WHERE user1_id = @id OR user2_id = @id)", func(r *Result) *int64 { return &r.IcebreakerUsages }, "", ""},
+	{"match_extensions", &models.MatchExtension{}, "user_id = @id OR match_id IN (SELECT id FROM matches WHERE user1_id = @id OR user2_id = @id)", func(r *Result) *int64 { return &r.MatchExtensions }, "", ""},
+	{"rematch_requests", &models.RematchRequest{}, "requester_id = @id OR target_id = @id", func(r *Result) *int64 { return &r.RematchRequests }, "", ""},
+	{"moderation_decisions", &models.ModerationDecision{}, "sender_id = @id OR receiver_id = @id", func(r *Result) *int64 { return &r.ModerationDecisions }, "", ""},
+	{"reports", &models.Report{}, "reporter_id = @id", func(r *Result) *int64 { return &r.Reports }, "", ""},
+	{"attachments", &models.Attachment{}, "uploader_id = @id", func(r *Result) *int64 { return &r.Attachments }, "", "uploader_id"},
+	{"reactions", &models.Reaction{}, "user_id = @id OR message_id IN (SELECT id FROM messages WHERE " + erasedMessages + ")", func(r *Result) *int64 { return &r.Reactions }, "", ""},
+	{"messages", &models.Message{}, erasedMessages, func(r *Result) *int64 { return &r.Messages }, "", ""},
+	{"matches", &models.Match{}, "user1_id = @id OR user2_id = @id", func(r *Result) *int64 { return &r.Matches }, "", ""},
+	{"blocks", &models.Block{}, "user_id = @id", func(r *Result) *int64 { return &r.Blocks }, "", ""},
+	{"settings", &models.UserSettings{}, "user_id = @id", func(r *Result) *int64 { return &r.Settings }, "user_id", ""},
+}
+
+// EraseUser applies the erasure policy to userID. It resumes from the last
+// recorded step if a previous run was interrupted.
+func EraseUser(ctx context.Context, db *gorm.DB, userID uuid.UUID) (Result, error) {
+	db = db.WithContext(ctx)
+	result := Result{UserID: userID, Status: StatusInProgress}
+
+	now := time.Now()
+	record := models.UserErasure{UserID: userID}
+	if err := db.Where(models.UserErasure{UserID: userID}).
+		Attrs(models.UserErasure{Status: StatusInProgress, Step: steps[0].name, StartedAt: now}).
+		FirstOrCreate(&record).Error; err != nil {
+		return result, err
+	}
+	start := 0
+	if record.Status == StatusInProgress {
+		for i, s := range steps {
+			if s.name == record.Step {
+				start = i
+			}
+		}
+	} else {
+		// Already completed: run every step again to catch rows created since.
+		record.Status = StatusInProgress
+		record.CompletedAt = nil
+	}
+
+	for _, s := range steps[start:] {
+		record.Step = s.name
+		if err := db.Save(&record).Error; err != nil {
+			return result, err
+		}
+		key := s.key
+		if key == "" {
+			key = "id"
+		}
+		var n int64
+		var err error
+		if s.anonymise != "" {
+			n, err = anonymiseInBatches(ctx, db, s.model, key, s.where, s.anonymise, userID)
+		} else {
+			n, err = deleteInBatches(ctx, db, s.model, key, s.where, userID)
+		}
+		*s.count(&result) += n
+		if err != nil {
+			return result, fmt.Errorf("erase %s: %w", s.name, err)
+		}
+	}
+
+	completedAt := time.Now()
+	record.Status = StatusCompleted
+	record.Step = ""
+	record.CompletedAt = &completedAt
+	if err := db.Save(&record).Error; err != nil {
+		return result, err
+	}
+	result.Status = StatusCompleted
+	return result, nil
+}
+
+func deleteInBatches(ctx context.Context, db *gorm.DB, model interface{}, key, where string, userID uuid.UUID) (int64, error) {
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		batch := db.Model(model).Select(key).Where(where, map[string]interface{}{"id": userID}).Limit(BatchSize)
+		res := db.Where(key+" IN (?)", batch).Delete(model)
+		if res.Error != nil {
+			return total, res.Error
+		}
+		total += res.RowsAffected
+		if res.RowsAffected < int64(BatchSize) {
+			return total, nil
+		}
+	}
+}
+
+// anonymiseInBatches sets column to the nil UUID on the rows matching where.
+// Updated rows no longer match, so batches move on like deletions do.
+func anonymiseInBatches(ctx context.Context, db *gorm.DB, model interface{}, key, where, column string, userID uuid.UUID) (int64, error) {
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		batch := db.Model(model).Select(key).Where(where, map[string]interface{}{"id": userID}).Limit(BatchSize)
+		res := db.Model(model).Where(key+" IN (?)", batch).Update(column, uuid.Nil)
+		if res.Error != nil {
+			return total, res.Error
+		}
+		total += res.RowsAffected
+		if res.RowsAffected < int64(BatchSize) {
+			return total, nil
+		}
+	}
+}
+
+// ResumePending finishes every erasure left in progress, e.g. after a crash.
+func ResumePending(ctx context.Context, db *gorm.DB) error {
+	var pending []models.UserErasure
+	if err := db.WithContext(ctx).Where("status = ?", StatusInProgress).Find(&pending).Error; err != nil {
+		return err
+	}
+	for _, p := range pending {
+		if _, err := EraseUser(ctx, db, p.UserID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UserDeletedEvent is the payload published when an account is deleted
+// elsewhere in Way-d.
+type UserDeletedEvent struct {
+	Type   string `json:"type"`
+	UserID string `json:"user_id"`
+}
+
+// EventUserDeleted is the event type handled by HandleEvent.
+const EventUserDeleted = "user.deleted"
+
+// HandleEvent is the consumer hook for account events. Events of other types
+// are ignored so the hook can be attached to a shared topic.
+func HandleEvent(ctx context.Context, db *gorm.DB, payload []byte) error {
+	var event UserDeletedEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return fmt.Errorf("decode event: %w", err)
+	}
+	if event.Type != EventUserDeleted {
+		return nil
+	}
+	userID, err := uuid.Parse(event.UserID)
+	if err != nil {
+		return errors.New("user.deleted event has an invalid user_id")
+	}
+	_, err = EraseUser(ctx, db, userID)
+	return err
+}
@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"way-d-interactions/tracing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the request ID, accepted from the caller or
+// generated, and returned on every response.
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID bounds the IDs accepted from callers, so that a header
+// cannot forge log lines or grow them without limit.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// Middleware gives each request an ID and a logger carrying it along with
+// the method, route and trace ID, installed in the request context, and
+// logs the request once served.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, requestID)
+
+		attrs := []any{"request_id", requestID, "method", c.Request.Method, "route", c.FullPath()}
+		if traceID := tracing.TraceID(c.Request.Context()); traceID != "" {
+			attrs = append(attrs, "trace_id", traceID)
+		}
+		ctx := WithLogger(c.Request.Context(), slog.Default().With(attrs...))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		FromContext(c.Request.Context()).Log(c.Request.Context(), level, "Request served",
+			"path", c.Request.URL.Path,
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
+
+// With adds args to the logger of the request, for the rest of its
+// handlers and its request log line.
+func With(c *gin.Context, args ...any) {
+	ctx := c.Request.Context()
+	c.Request = c.Request.WithContext(WithLogger(ctx, FromContext(ctx).With(args...)))
+}
+
+// From returns the logger of the request.
+func From(c *gin.Context) *slog.Logger {
+	return FromContext(c.Request.Context())
+}
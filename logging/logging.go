@@ -0,0 +1,64 @@
+// Package logging sets up the service's structured logger and the
+// per-request loggers carried in request contexts.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"unicode/utf8"
+
+	"way-d-interactions/config"
+)
+
+// redactedKeys name the attributes that may hold what users wrote. Their
+// values are never logged, only their length.
+var redactedKeys = map[string]bool{
+	"content": true,
+	"note":    true,
+	"text":    true,
+	"body":    true,
+}
+
+// New returns a logger writing to w in the format and from the level of cfg.
+func New(w io.Writer, cfg config.LogConfig) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+	if cfg.Format == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// Setup installs the logger for cfg as the default, which the standard log
+// package then writes through as well.
+func Setup(cfg config.LogConfig) {
+	slog.SetDefault(New(os.Stderr, cfg))
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if redactedKeys[a.Key] && a.Value.Kind() == slog.KindString {
+		return slog.String(a.Key, fmt.Sprintf("[redacted, %d characters]", utf8.RuneCountInString(a.Value.String())))
+	}
+	return a
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying l.
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
@@ -1,34 +1,149 @@
-// Main entrypoint for the interactions service. Sets up Gin, config, routes, and runs the server.
+// Main entrypoint for the interactions service. Sets up Gin, config, routes, and runs the server until SIGINT or SIGTERM, then shuts down gracefully.
 
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"way-d-interactions/config"
+	"way-d-interactions/controllers"
+	"way-d-interactions/erasure"
+	"way-d-interactions/logging"
+	"way-d-interactions/metrics"
 	"way-d-interactions/models"
+	"way-d-interactions/realtime"
 	"way-d-interactions/routes"
+	"way-d-interactions/storage"
+	"way-d-interactions/store"
+	"way-d-interactions/tracing"
+	"way-d-interactions/workers"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 func main() {
-	config.ConnectDB()
-	if err := config.DB.AutoMigrate(
-		&models.Like{},
-		&models.Dislike{},
-		&models.Match{},
-		&models.Message{},
-		&models.Block{},
-	); err != nil {
-		log.Fatalf("Migration error: %v", err)
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+	config.Set(cfg)
+	logging.Setup(cfg.Log)
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		fmt.Printf("%+v\n", cfg.Redacted())
+		if err := cfg.Validate(); err != nil {
+			fatal("Configuration error", "error", err)
+		}
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		fatal("Configuration error", "error", err)
+	}
+	config.ConnectDB(cfg.Database)
+	if err := config.DB.Use(tracing.GormPlugin{}); err != nil {
+		fatal("Tracing database queries", "error", err)
+	}
+	if sqlDB, err := config.DB.DB(); err == nil {
+		metrics.RegisterDB(sqlDB)
 	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(config.DB, os.Args[2:]); err != nil {
+			fatal("Migration error", "error", err)
+		}
+		return
+	}
+	slog.Info("Configuration", "config", fmt.Sprintf("%+v", cfg.Redacted()))
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("Tracing setup", "error", err)
+	}
+	migrateOnStart(config.DB, cfg.MigrateOnStart)
+	if err := controllers.SeedIcebreakers(context.Background(), store.NewGorm(config.DB).Icebreakers); err != nil {
+		fatal("Seeding icebreakers", "error", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
+	group := workers.NewGroup(context.Background())
+	// Finish account erasures interrupted by a previous shutdown.
+	group.Task(func(ctx context.Context) {
+		if err := erasure.ResumePending(ctx, config.DB); err != nil && ctx.Err() == nil {
+			slog.Error("Resuming pending erasures", "error", err)
+		}
+	})
+	purger := &workers.Purger{DB: config.DB, Policy: workers.RetentionPolicyFrom(cfg.Retention)}
+	group.Go("retention_purge", purger.Run)
+	janitor := workers.NewAttachmentJanitor(config.DB, storage.Default(), cfg.Attachments)
+	group.Go("attachment_cleanup", janitor.Run)
+	dispatcher := workers.NewScheduledDispatcher(config.DB, deliverScheduled, cfg.Messages)
+	group.Go("scheduled_dispatch", dispatcher.Run)
+
+	metrics.RegisterWorkers(group)
 	r := routes.SetupRouter() // Use SetupRouter to ensure CORS and all middleware are applied
-	routes.RegisterRoutes(r)  // Register all /api routes
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8082"
+	probes, err := controllers.NewProbes(config.DB, group)
+	if err != nil {
+		fatal("Loading migrations", "error", err)
+	}
+	routes.RegisterProbes(r, probes)
+	routes.RegisterRoutes(r) // Register all /api routes
+	port := cfg.Port
+	srv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
 	}
-	log.Printf("Way-d Interactions service running on port %s", port)
-	r.Run(":" + port)
+	// Event streams only end when their client leaves; close them so they
+	// do not hold up the drain.
+	srv.RegisterOnShutdown(realtime.Default().Close)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("Way-d Interactions service running", "port", port)
+		serveErr <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			fatal("Server error", "error", err)
+		}
+	case <-ctx.Done():
+	}
+	stop()
+
+	timeout := cfg.ShutdownTimeout
+	slog.Info("Shutting down", "drain_timeout", timeout)
+	probes.Drain()
+	drainCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(drainCtx); err != nil {
+		slog.Error("Draining requests", "error", err)
+	}
+	if err := group.Shutdown(drainCtx); err != nil {
+		slog.Error("Stopping background workers", "error", err)
+	}
+	if err := shutdownTracing(drainCtx); err != nil {
+		slog.Error("Flushing traces", "error", err)
+	}
+	slog.Info("Shutdown complete")
+}
+
+// deliverScheduled sends scheduled through stores bound to the dispatcher's
+// transaction.
+func deliverScheduled(ctx context.Context, tx *gorm.DB, scheduled models.ScheduledMessage) (uuid.UUID, string, error) {
+	return controllers.NewHandler(store.NewGorm(tx)).DeliverScheduledMessage(ctx, scheduled)
+}
+
+// fatal logs msg as an error and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
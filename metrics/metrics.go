@@ -0,0 +1,140 @@
+// Package metrics exposes the service's Prometheus metrics: HTTP requests
+// per route, business counters, database pool statistics and the state of
+// the background workers.
+package metrics
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every metric of the service.
+const namespace = "wayd_interactions"
+
+// Registry holds the service's metrics, apart from the default registry so
+// that only what is registered here is exposed.
+var Registry = prometheus.NewRegistry()
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// Likes counts likes sent, by whether they were super-likes.
+	Likes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "likes_total",
+		Help:      "Likes sent, by whether they were super-likes.",
+	}, []string{"super"})
+	// Dislikes counts dislikes sent.
+	Dislikes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dislikes_total",
+		Help:      "Dislikes sent.",
+	})
+	// MatchesCreated counts matches created, by source.
+	MatchesCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "matches_created_total",
+		Help:      "Matches created, by source.",
+	}, []string{"source"})
+	// MessagesSent counts messages stored, by kind: message for those sent
+	// directly or on schedule, note for like notes opening a conversation.
+	MessagesSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_sent_total",
+		Help:      "Messages stored, by kind (message or note).",
+	}, []string{"kind"})
+	// Blocks counts blocks made.
+	Blocks = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "blocks_total",
+		Help:      "Blocks made.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requests, requestDuration,
+		Likes, Dislikes, MatchesCreated, MessagesSent, Blocks,
+	)
+}
+
+// unmatchedRoute labels requests that matched no route, so that arbitrary
+// paths cannot create new series.
+const unmatchedRoute = "unmatched"
+
+// Middleware records the count and latency of every request, labelled with
+// the route pattern (e.g. /api/messages/:match_id) rather than the path.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		requests.WithLabelValues(c.Request.Method, route, status).Inc()
+		requestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the registered metrics in the Prometheus text format.
+func Handler() gin.HandlerFunc {
+	h := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+	return gin.WrapH(h)
+}
+
+// RegisterDB exposes the connection pool statistics of db.
+func RegisterDB(db *sql.DB) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, "interactions"))
+}
+
+// WorkerStatus reports the background workers and whether each is running.
+type WorkerStatus interface {
+	Running() map[string]bool
+}
+
+// workerCollector exposes a WorkerStatus as a gauge per worker.
+type workerCollector struct {
+	status WorkerStatus
+	desc   *prometheus.Desc
+}
+
+func (w workerCollector) Describe(ch chan<- *prometheus.Desc) { ch <- w.desc }
+
+func (w workerCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, running := range w.status.Running() {
+		value := 0.0
+		if running {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(w.desc, prometheus.GaugeValue, value, name)
+	}
+}
+
+// RegisterWorkers exposes whether each worker of status is running.
+func RegisterWorkers(status WorkerStatus) error {
+	return Registry.Register(workerCollector{
+		status: status,
+		desc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "worker_running"),
+			"Whether each background worker is running (1) or stopped (0).", []string{"worker"}, nil),
+	})
+}
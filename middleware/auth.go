@@ -1,17 +1,20 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
-	"os"
 	"strings"
 
+	"way-d-interactions/config"
+	"way-d-interactions/logging"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type JWTClaims struct {
 	UserID string `json:"user_id"`
+	// Locale is the user's preferred language as a BCP 47 tag, e.g. "fr-CA".
+	Locale string `json:"locale,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -27,7 +30,7 @@ func AuthRequired() gin.HandlerFunc {
 			return
 		}
 		tokenStr := strings.TrimPrefix(header, "Bearer ")
-		secret := os.Getenv("JWT_SECRET")
+		secret := config.Get().JWTSecret
 		token, err := jwt.ParseWithClaims(tokenStr, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
 			return []byte(secret), nil
 		})
@@ -36,9 +39,11 @@ func AuthRequired() gin.HandlerFunc {
 			return
 		}
 		if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
-			// DEBUG: Print extracted user ID
-			fmt.Printf("[DEBUG] JWT middleware extracted user_id=%s\n", claims.UserID)
 			c.Set("user_id", claims.UserID)
+			logging.With(c, "user_id", claims.UserID)
+			if claims.Locale != "" {
+				c.Set("locale", claims.Locale)
+			}
 			c.Next()
 			return
 		}
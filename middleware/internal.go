@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"way-d-interactions/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InternalOnly protects service-to-service endpoints with the shared
+// INTERNAL_API_TOKEN sent in the X-Internal-Token header. Every request is
+// rejected when no token is configured.
+func InternalOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := config.Get().InternalAPIToken
+		got := c.GetHeader("X-Internal-Token")
+		if expected == "" || subtle.ConstantTimeCompare([]byte(got), []byte(expected)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing or invalid internal token"})
+			return
+		}
+		c.Next()
+	}
+}
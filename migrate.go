@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"way-d-interactions/migrations"
+
+	"gorm.io/gorm"
+)
+
+const migrateUsage = "usage: wayd-interactions migrate up | down | status | to <version>"
+
+// runMigrate implements the migrate subcommand.
+func runMigrate(db *gorm.DB, args []string) error {
+	m, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	switch args[0] {
+	case "up":
+		ran, err := m.Up(ctx)
+		printMigrations("Applied", ran)
+		return err
+	case "down":
+		reverted, err := m.Down(ctx)
+		if reverted != nil {
+			fmt.Printf("Reverted %04d_%s\n", reverted.Version, reverted.Name)
+		} else if err == nil {
+			fmt.Println("No migration to revert")
+		}
+		return err
+	case "to":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		ran, err := m.To(ctx, version)
+		printMigrations("Ran", ran)
+		return err
+	case "status":
+		if err := m.Prepare(ctx); err != nil {
+			return err
+		}
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Printf("%04d_%-40s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	}
+	return errors.New(migrateUsage)
+}
+
+func printMigrations(verb string, ran []migrations.Migration) {
+	if len(ran) == 0 {
+		fmt.Println("Schema is up to date")
+	}
+	for _, mig := range ran {
+		fmt.Printf("%s %04d_%s\n", verb, mig.Version, mig.Name)
+	}
+}
+
+// migrateOnStart brings the schema up to date according to
+// MIGRATE_ON_START: "up" (default) applies pending migrations, "check"
+// refuses to start while any are pending, and "off" does neither. In every
+// mode the schema_migrations table is created, for the readiness probe.
+func migrateOnStart(db *gorm.DB, mode string) {
+	m, err := migrations.New(db)
+	if err != nil {
+		fatal("Loading migrations", "error", err)
+	}
+	if err := m.Prepare(context.Background()); err != nil {
+		fatal("Preparing the migrations table", "error", err)
+	}
+	switch mode {
+	case "up":
+		ran, err := m.Up(context.Background())
+		if err != nil {
+			fatal("Migration error", "error", err)
+		}
+		for _, mig := range ran {
+			slog.Info("Applied migration", "version", mig.Version, "name", mig.Name)
+		}
+	case "check":
+		pending, err := m.Pending(context.Background())
+		if err != nil {
+			fatal("Checking migrations", "error", err)
+		}
+		if len(pending) > 0 {
+			fatal("Schema is behind; run the migrate subcommand", "pending", len(pending), "first_version", pending[0].Version, "first_name", pending[0].Name)
+		}
+	case "off":
+	default:
+		fatal("MIGRATE_ON_START must be up, check or off", "value", mode)
+	}
+}
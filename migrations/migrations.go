@@ -0,0 +1,277 @@
+// Package migrations applies the versioned SQL migrations embedded in the
+// binary.
+//
+// Migrations live in sql/ as NNNN_name.up.sql and NNNN_name.down.sql. The
+// applied versions are recorded in schema_migrations. Each migration runs in
+// its own transaction, and a PostgreSQL advisory lock keeps replicas from
+// migrating concurrently.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockKey identifies the advisory lock held while migrating.
+const lockKey = 7209413385114275139
+
+// Migration is one schema version.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration and whether it was applied.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Load returns the embedded migrations ordered by version. Every version
+// needs both an up and a down file.
+func Load() ([]Migration, error) {
+	names, err := fs.Glob(files, "sql/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	for _, name := range names {
+		base := path.Base(name)
+		var direction string
+		switch {
+		case strings.HasSuffix(base, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(base, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migration %s: want NNNN_name.up.sql or NNNN_name.down.sql", base)
+		}
+		stem := strings.TrimSuffix(base, "."+direction+".sql")
+		number, label, ok := strings.Cut(stem, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: want a positive version prefix", base)
+		}
+		body, err := files.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		} else if m.Name != label {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, m.Name, label)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// schemaMigration is a row of schema_migrations.
+type schemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"type:text;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string { return "schema_migrations" }
+
+// Migrator applies the embedded migrations to DB.
+type Migrator struct {
+	DB         *gorm.DB
+	Migrations []Migration
+}
+
+// New returns a migrator for db with the embedded migrations.
+func New(db *gorm.DB) (*Migrator, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// ensureTable creates schema_migrations if needed.
+func ensureTable(db *gorm.DB) error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL
+	)`).Error
+}
+
+// applied returns the applied versions and when they were applied.
+func applied(db *gorm.DB) (map[int]time.Time, error) {
+	var rows []schemaMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	versions := make(map[int]time.Time, len(rows))
+	for _, r := range rows {
+		versions[r.Version] = r.AppliedAt
+	}
+	return versions, nil
+}
+
+// locked runs fn on a single connection holding the migration lock.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *gorm.DB, done map[int]time.Time) error) error {
+	return m.DB.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error; err != nil {
+			return fmt.Errorf("acquiring migration lock: %w", err)
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", lockKey)
+		if err := ensureTable(conn); err != nil {
+			return err
+		}
+		done, err := applied(conn)
+		if err != nil {
+			return err
+		}
+		return fn(conn, done)
+	})
+}
+
+// up applies mig and records it.
+func up(conn *gorm.DB, mig Migration) error {
+	return conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(mig.Up).Error; err != nil {
+			return fmt.Errorf("applying %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+		return tx.Create(&schemaMigration{Version: mig.Version, Name: mig.Name, AppliedAt: time.Now()}).Error
+	})
+}
+
+// down reverts mig and forgets it.
+func down(conn *gorm.DB, mig Migration) error {
+	return conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(mig.Down).Error; err != nil {
+			return fmt.Errorf("reverting %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+		return tx.Delete(&schemaMigration{}, "version = ?", mig.Version).Error
+	})
+}
+
+// Up applies every pending migration and returns them.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	latest := 0
+	if len(m.Migrations) > 0 {
+		latest = m.Migrations[len(m.Migrations)-1].Version
+	}
+	return m.To(ctx, latest)
+}
+
+// Down reverts the latest applied migration and returns it, or nil when
+// none is applied.
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	var reverted *Migration
+	err := m.locked(ctx, func(conn *gorm.DB, done map[int]time.Time) error {
+		for i := len(m.Migrations) - 1; i >= 0; i-- {
+			if _, ok := done[m.Migrations[i].Version]; ok {
+				reverted = &m.Migrations[i]
+				return down(conn, *reverted)
+			}
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// To applies or reverts migrations until version is the latest applied
+// one, and returns the migrations it ran. Version 0 reverts everything.
+func (m *Migrator) To(ctx context.Context, version int) ([]Migration, error) {
+	known := version == 0
+	for _, mig := range m.Migrations {
+		known = known || mig.Version == version
+	}
+	if !known {
+		return nil, fmt.Errorf("unknown migration version %d", version)
+	}
+	var ran []Migration
+	err := m.locked(ctx, func(conn *gorm.DB, done map[int]time.Time) error {
+		for i := len(m.Migrations) - 1; i >= 0; i-- {
+			mig := m.Migrations[i]
+			if _, ok := done[mig.Version]; ok && mig.Version > version {
+				if err := down(conn, mig); err != nil {
+					return err
+				}
+				ran = append(ran, mig)
+			}
+		}
+		for _, mig := range m.Migrations {
+			if _, ok := done[mig.Version]; !ok && mig.Version <= version {
+				if err := up(conn, mig); err != nil {
+					return err
+				}
+				ran = append(ran, mig)
+			}
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// Prepare creates the schema_migrations table if needed. Up, Down and To
+// do so themselves; Status and Pending only read the table, so call Prepare
+// once before them on a database that may never have been migrated.
+func (m *Migrator) Prepare(ctx context.Context) error {
+	return ensureTable(m.DB.WithContext(ctx))
+}
+
+// Status lists the embedded migrations and when each was applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	db := m.DB.WithContext(ctx)
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(m.Migrations))
+	for i, mig := range m.Migrations {
+		statuses[i].Migration = mig
+		if at, ok := done[mig.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations not applied yet.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			pending = append(pending, s.Migration)
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,16 @@
+DROP TABLE IF EXISTS rematch_requests;
+DROP TABLE IF EXISTS match_extensions;
+DROP TABLE IF EXISTS icebreaker_usages;
+DROP TABLE IF EXISTS icebreakers;
+DROP TABLE IF EXISTS scheduled_messages;
+DROP TABLE IF EXISTS user_settings;
+DROP TABLE IF EXISTS user_erasures;
+DROP TABLE IF EXISTS moderation_decisions;
+DROP TABLE IF EXISTS reports;
+DROP TABLE IF EXISTS reactions;
+DROP TABLE IF EXISTS attachments;
+DROP TABLE IF EXISTS blocks;
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS matches;
+DROP TABLE IF EXISTS dislikes;
+DROP TABLE IF EXISTS likes;
@@ -0,0 +1,209 @@
+-- Schema as created by GORM AutoMigrate before versioned migrations. Every
+-- statement is guarded so databases created by AutoMigrate adopt it as is;
+-- the columns added to existing tables since the first release are added at
+-- the end for databases AutoMigrated before them.
+
+CREATE TABLE IF NOT EXISTS likes (
+	id uuid PRIMARY KEY,
+	user_id uuid NOT NULL,
+	target_id uuid NOT NULL,
+	created_at timestamptz,
+	match boolean,
+	note text,
+	note_held boolean NOT NULL DEFAULT false,
+	super boolean NOT NULL DEFAULT false
+);
+
+CREATE TABLE IF NOT EXISTS dislikes (
+	id uuid PRIMARY KEY,
+	user_id uuid NOT NULL,
+	target_id uuid NOT NULL,
+	created_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS matches (
+	id uuid PRIMARY KEY,
+	user1_id uuid NOT NULL,
+	user2_id uuid NOT NULL,
+	created_at timestamptz,
+	expire_at timestamptz,
+	previous_match_id uuid,
+	initiator_id uuid,
+	initiator_liked_at timestamptz,
+	responder_liked_at timestamptz,
+	source text NOT NULL DEFAULT 'like',
+	first_message_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS messages (
+	id uuid PRIMARY KEY,
+	sender_id uuid NOT NULL,
+	receiver_id uuid NOT NULL,
+	content text,
+	created_at timestamptz,
+	seen boolean,
+	deleted boolean,
+	deleted_at timestamptz,
+	held boolean NOT NULL DEFAULT false,
+	reply_to_id uuid
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_content_fts ON messages USING GIN (to_tsvector('simple', content));
+
+CREATE TABLE IF NOT EXISTS blocks (
+	id uuid PRIMARY KEY,
+	user_id uuid NOT NULL,
+	blocked_id uuid NOT NULL,
+	reason text,
+	created_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS attachments (
+	id uuid PRIMARY KEY,
+	uploader_id uuid NOT NULL,
+	match_id uuid NOT NULL,
+	message_id uuid,
+	kind text NOT NULL,
+	mime_type text NOT NULL,
+	size bigint,
+	checksum text NOT NULL,
+	width bigint,
+	height bigint,
+	duration_ms bigint,
+	storage_key text NOT NULL,
+	created_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS reactions (
+	id uuid PRIMARY KEY,
+	message_id uuid NOT NULL,
+	user_id uuid NOT NULL,
+	emoji text NOT NULL,
+	created_at timestamptz
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reactions_message_user ON reactions (message_id, user_id);
+
+CREATE TABLE IF NOT EXISTS reports (
+	id uuid PRIMARY KEY,
+	message_id uuid NOT NULL,
+	reporter_id uuid NOT NULL,
+	reason text,
+	status text NOT NULL,
+	created_at timestamptz,
+	closed_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS moderation_decisions (
+	id uuid PRIMARY KEY,
+	message_id uuid,
+	sender_id uuid NOT NULL,
+	receiver_id uuid NOT NULL,
+	filter text NOT NULL,
+	action text NOT NULL,
+	reason text,
+	created_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS user_erasures (
+	user_id uuid PRIMARY KEY,
+	status text NOT NULL,
+	step text,
+	started_at timestamptz,
+	updated_at timestamptz,
+	completed_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS user_settings (
+	user_id uuid PRIMARY KEY,
+	hide_last_seen boolean NOT NULL DEFAULT false,
+	updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS scheduled_messages (
+	id uuid PRIMARY KEY,
+	sender_id uuid NOT NULL,
+	match_id uuid NOT NULL,
+	content text,
+	attachment_id uuid,
+	reply_to_id uuid,
+	icebreaker_id uuid,
+	send_at timestamptz NOT NULL,
+	status text NOT NULL,
+	failure_reason text,
+	created_at timestamptz,
+	updated_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_messages_sender_id ON scheduled_messages (sender_id);
+CREATE INDEX IF NOT EXISTS idx_scheduled_messages_due ON scheduled_messages (status, send_at);
+
+CREATE TABLE IF NOT EXISTS icebreakers (
+	id uuid PRIMARY KEY,
+	locale text NOT NULL,
+	text text NOT NULL,
+	active boolean NOT NULL DEFAULT true,
+	created_at timestamptz,
+	updated_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_icebreakers_locale ON icebreakers (locale);
+
+CREATE TABLE IF NOT EXISTS icebreaker_usages (
+	id uuid PRIMARY KEY,
+	icebreaker_id uuid NOT NULL,
+	match_id uuid NOT NULL,
+	message_id uuid NOT NULL,
+	sender_id uuid NOT NULL,
+	sent_at timestamptz,
+	replied_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_icebreaker_usages_icebreaker_id ON icebreaker_usages (icebreaker_id);
+CREATE INDEX IF NOT EXISTS idx_icebreaker_usages_match_id ON icebreaker_usages (match_id);
+
+CREATE TABLE IF NOT EXISTS match_extensions (
+	id uuid PRIMARY KEY,
+	match_id uuid NOT NULL,
+	user_id uuid NOT NULL,
+	previous_expire_at timestamptz,
+	expire_at timestamptz,
+	created_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_match_extensions_match_id ON match_extensions (match_id);
+
+CREATE TABLE IF NOT EXISTS rematch_requests (
+	id uuid PRIMARY KEY,
+	match_id uuid NOT NULL,
+	requester_id uuid NOT NULL,
+	target_id uuid NOT NULL,
+	status text NOT NULL,
+	new_match_id uuid,
+	created_at timestamptz,
+	responded_at timestamptz
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_rematch_requests_match_requester ON rematch_requests (match_id, requester_id);
+CREATE INDEX IF NOT EXISTS idx_rematch_requests_target_id ON rematch_requests (target_id);
+
+ALTER TABLE likes
+	ADD COLUMN IF NOT EXISTS note text,
+	ADD COLUMN IF NOT EXISTS note_held boolean NOT NULL DEFAULT false,
+	ADD COLUMN IF NOT EXISTS super boolean NOT NULL DEFAULT false;
+
+ALTER TABLE matches
+	ADD COLUMN IF NOT EXISTS previous_match_id uuid,
+	ADD COLUMN IF NOT EXISTS initiator_id uuid,
+	ADD COLUMN IF NOT EXISTS initiator_liked_at timestamptz,
+	ADD COLUMN IF NOT EXISTS responder_liked_at timestamptz,
+	ADD COLUMN IF NOT EXISTS source text NOT NULL DEFAULT 'like',
+	ADD COLUMN IF NOT EXISTS first_message_at timestamptz;
+
+ALTER TABLE messages
+	ADD COLUMN IF NOT EXISTS deleted_at timestamptz,
+	ADD COLUMN IF NOT EXISTS held boolean NOT NULL DEFAULT false,
+	ADD COLUMN IF NOT EXISTS reply_to_id uuid;
+
+ALTER TABLE scheduled_messages
+	ADD COLUMN IF NOT EXISTS icebreaker_id uuid;
@@ -0,0 +1,2 @@
+-- The backfilled values cannot be told apart from recorded ones; they are
+-- kept.
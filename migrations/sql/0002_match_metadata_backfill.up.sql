@@ -0,0 +1,25 @@
+-- Fill the initiator, like timestamps, source and first message time of
+-- matches created before they were recorded. Matches used to store the
+-- second liker as user1_id.
+
+UPDATE matches SET source = 'rematch', initiator_id = user1_id
+WHERE initiator_id IS NULL AND previous_match_id IS NOT NULL;
+
+UPDATE matches SET initiator_id = user2_id WHERE initiator_id IS NULL;
+
+UPDATE matches SET initiator_liked_at = likes.created_at FROM likes
+WHERE matches.initiator_liked_at IS NULL AND matches.source <> 'rematch'
+	AND likes.user_id = matches.initiator_id
+	AND likes.target_id = CASE WHEN matches.initiator_id = matches.user1_id THEN matches.user2_id ELSE matches.user1_id END;
+
+UPDATE matches SET responder_liked_at = likes.created_at FROM likes
+WHERE matches.responder_liked_at IS NULL AND matches.source <> 'rematch'
+	AND likes.target_id = matches.initiator_id
+	AND likes.user_id = CASE WHEN matches.initiator_id = matches.user1_id THEN matches.user2_id ELSE matches.user1_id END;
+
+UPDATE matches SET first_message_at = (
+	SELECT MIN(messages.created_at) FROM messages
+	WHERE ((messages.sender_id = matches.user1_id AND messages.receiver_id = matches.user2_id)
+		OR (messages.sender_id = matches.user2_id AND messages.receiver_id = matches.user1_id))
+		AND messages.created_at >= matches.created_at)
+WHERE first_message_at IS NULL;
@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_messages_receiver;
+DROP INDEX IF EXISTS idx_messages_conversation;
+ALTER TABLE matches DROP CONSTRAINT IF EXISTS chk_matches_distinct_users;
+DROP INDEX IF EXISTS idx_matches_user2;
+DROP INDEX IF EXISTS idx_matches_user1;
+ALTER TABLE blocks DROP CONSTRAINT IF EXISTS chk_blocks_not_self;
+DROP INDEX IF EXISTS idx_blocks_blocked;
+DROP INDEX IF EXISTS idx_blocks_user_blocked;
+ALTER TABLE dislikes DROP CONSTRAINT IF EXISTS chk_dislikes_not_self;
+DROP INDEX IF EXISTS idx_dislikes_target;
+DROP INDEX IF EXISTS idx_dislikes_user_target;
+ALTER TABLE likes DROP CONSTRAINT IF EXISTS chk_likes_not_self;
+DROP INDEX IF EXISTS idx_likes_target;
+DROP INDEX IF EXISTS idx_likes_user_target;
@@ -0,0 +1,44 @@
+-- Unique and lookup indexes for the checks in PostLike, PostDislike and
+-- PostBlock, and check constraints against self-interactions. Duplicates and
+-- self-interactions that slipped in before are removed first, keeping the
+-- earliest row (or the like that led to a match).
+
+DELETE FROM likes WHERE user_id = target_id;
+DELETE FROM likes WHERE id IN (
+	SELECT id FROM (
+		SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id, target_id ORDER BY COALESCE(match, false) DESC, created_at, id) AS rank
+		FROM likes
+	) ranked WHERE rank > 1);
+
+DELETE FROM dislikes WHERE user_id = target_id;
+DELETE FROM dislikes WHERE id IN (
+	SELECT id FROM (
+		SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id, target_id ORDER BY created_at, id) AS rank
+		FROM dislikes
+	) ranked WHERE rank > 1);
+
+DELETE FROM blocks WHERE user_id = blocked_id;
+DELETE FROM blocks WHERE id IN (
+	SELECT id FROM (
+		SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id, blocked_id ORDER BY created_at, id) AS rank
+		FROM blocks
+	) ranked WHERE rank > 1);
+
+CREATE UNIQUE INDEX idx_likes_user_target ON likes (user_id, target_id);
+CREATE INDEX idx_likes_target ON likes (target_id, created_at);
+ALTER TABLE likes ADD CONSTRAINT chk_likes_not_self CHECK (user_id <> target_id);
+
+CREATE UNIQUE INDEX idx_dislikes_user_target ON dislikes (user_id, target_id);
+CREATE INDEX idx_dislikes_target ON dislikes (target_id);
+ALTER TABLE dislikes ADD CONSTRAINT chk_dislikes_not_self CHECK (user_id <> target_id);
+
+CREATE UNIQUE INDEX idx_blocks_user_blocked ON blocks (user_id, blocked_id);
+CREATE INDEX idx_blocks_blocked ON blocks (blocked_id);
+ALTER TABLE blocks ADD CONSTRAINT chk_blocks_not_self CHECK (user_id <> blocked_id);
+
+CREATE INDEX idx_matches_user1 ON matches (user1_id, user2_id);
+CREATE INDEX idx_matches_user2 ON matches (user2_id, user1_id);
+ALTER TABLE matches ADD CONSTRAINT chk_matches_distinct_users CHECK (user1_id <> user2_id);
+
+CREATE INDEX idx_messages_conversation ON messages (sender_id, receiver_id, created_at);
+CREATE INDEX idx_messages_receiver ON messages (receiver_id);
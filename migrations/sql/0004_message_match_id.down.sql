@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_messages_match;
+ALTER TABLE messages DROP COLUMN IF EXISTS match_id;
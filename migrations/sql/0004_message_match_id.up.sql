@@ -0,0 +1,26 @@
+-- Messages belong to a match instead of to the pair of users, so that a new
+-- match between the same users starts an empty conversation. Existing
+-- messages go to the latest match of their pair created before them, or to
+-- the pair's first match for notes and messages predating it. Messages whose
+-- pair has no match left keep a NULL match_id.
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS match_id uuid;
+
+UPDATE messages SET match_id = (
+	SELECT mt.id FROM matches mt
+	WHERE ((mt.user1_id = messages.sender_id AND mt.user2_id = messages.receiver_id)
+		OR (mt.user1_id = messages.receiver_id AND mt.user2_id = messages.sender_id))
+		AND mt.created_at <= messages.created_at
+	ORDER BY mt.created_at DESC
+	LIMIT 1)
+WHERE match_id IS NULL;
+
+UPDATE messages SET match_id = (
+	SELECT mt.id FROM matches mt
+	WHERE (mt.user1_id = messages.sender_id AND mt.user2_id = messages.receiver_id)
+		OR (mt.user1_id = messages.receiver_id AND mt.user2_id = messages.sender_id)
+	ORDER BY mt.created_at
+	LIMIT 1)
+WHERE match_id IS NULL;
+
+CREATE INDEX idx_messages_match ON messages (match_id, created_at);
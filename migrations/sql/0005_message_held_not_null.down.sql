@@ -0,0 +1 @@
+ALTER TABLE messages ALTER COLUMN held DROP NOT NULL, ALTER COLUMN held DROP DEFAULT;
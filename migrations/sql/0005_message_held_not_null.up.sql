@@ -0,0 +1,5 @@
+-- Messages stored before moderation, or inserted without the held flag,
+-- have a NULL held that every "held = false" filter would hide.
+
+UPDATE messages SET held = false WHERE held IS NULL;
+ALTER TABLE messages ALTER COLUMN held SET DEFAULT false, ALTER COLUMN held SET NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE scheduled_messages DROP COLUMN IF EXISTS next_attempt_at, DROP COLUMN IF EXISTS attempts;
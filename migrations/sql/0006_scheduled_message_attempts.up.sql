@@ -0,0 +1,7 @@
+-- A scheduled message whose delivery keeps failing with a transient error
+-- is retried with a growing delay and given up after a number of attempts,
+-- instead of being retried at the head of every dispatch.
+
+ALTER TABLE scheduled_messages
+	ADD COLUMN IF NOT EXISTS attempts integer NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS next_attempt_at timestamptz;
@@ -6,6 +6,9 @@
 // @property target_id string
 // @property created_at string
 // @property match bool
+// @property note string
+// @property note_held bool
+// @property super bool
 
 // Dislike represents a user disliking another user.
 // @Description Dislike model
@@ -23,17 +26,31 @@
 // @property user2_id string
 // @property created_at string
 // @property expire_at string
+// @property previous_match_id string
+// @property initiator_id string
+// @property initiator_liked_at string
+// @property responder_liked_at string
+// @property source string
+// @property first_message_at string
+// @property time_to_match_seconds integer
 
-// Message represents a message between matched users.
+// Message represents a message in the conversation of a match.
 // @Description Message model
 // @name Message
 // @property id string
+// @property match_id string
 // @property sender_id string
 // @property receiver_id string
 // @property content string
 // @property created_at string
 // @property seen bool
 // @property deleted bool
+// @property deleted_at string
+// @property held bool
+// @property attachments array
+// @property reactions array
+// @property reply_to_id string
+// @property reply_to object
 
 // Block represents a block between users.
 // @Description Block model
@@ -44,6 +61,130 @@
 // @property reason string
 // @property created_at string
 
+// Report represents a user reporting a message for review.
+// @Description Report model
+// @name Report
+// @property id string
+// @property message_id string
+// @property reporter_id string
+// @property reason string
+// @property status string
+// @property created_at string
+// @property closed_at string
+
+// Attachment represents a file uploaded to a match conversation.
+// @Description Attachment model
+// @name Attachment
+// @property id string
+// @property uploader_id string
+// @property match_id string
+// @property message_id string
+// @property kind string
+// @property mime_type string
+// @property size int
+// @property checksum string
+// @property width int
+// @property height int
+// @property duration_ms int
+// @property created_at string
+
+// Reaction represents a user reacting to a message with an emoji.
+// @Description Reaction model
+// @name Reaction
+// @property id string
+// @property message_id string
+// @property user_id string
+// @property emoji string
+// @property created_at string
+
+// ModerationDecision records a moderation filter's verdict on a message.
+// @Description ModerationDecision model
+// @name ModerationDecision
+// @property id string
+// @property message_id string
+// @property sender_id string
+// @property receiver_id string
+// @property filter string
+// @property action string
+// @property reason string
+// @property created_at string
+
+// UserErasure tracks the erasure of a deleted account.
+// @Description UserErasure model
+// @name UserErasure
+// @property user_id string
+// @property status string
+// @property step string
+// @property started_at string
+// @property updated_at string
+// @property completed_at string
+
+// ScheduledMessage is a message waiting to be delivered at a later time.
+// @Description ScheduledMessage model
+// @name ScheduledMessage
+// @property id string
+// @property sender_id string
+// @property match_id string
+// @property content string
+// @property attachment_id string
+// @property reply_to_id string
+// @property icebreaker_id string
+// @property send_at string
+// @property status string
+// @property failure_reason string
+// @property created_at string
+// @property updated_at string
+
+// Icebreaker is a suggested first message.
+// @Description Icebreaker model
+// @name Icebreaker
+// @property id string
+// @property locale string
+// @property text string
+// @property active bool
+// @property created_at string
+// @property updated_at string
+
+// IcebreakerUsage records an icebreaker sent in a match.
+// @Description IcebreakerUsage model
+// @name IcebreakerUsage
+// @property id string
+// @property icebreaker_id string
+// @property match_id string
+// @property message_id string
+// @property sender_id string
+// @property sent_at string
+// @property replied_at string
+
+// MatchExtension records a participant pushing back a match's expiry.
+// @Description MatchExtension model
+// @name MatchExtension
+// @property id string
+// @property match_id string
+// @property user_id string
+// @property previous_expire_at string
+// @property expire_at string
+// @property created_at string
+
+// RematchRequest asks to revive an expired match.
+// @Description RematchRequest model
+// @name RematchRequest
+// @property id string
+// @property match_id string
+// @property requester_id string
+// @property target_id string
+// @property status string
+// @property new_match_id string
+// @property created_at string
+// @property responded_at string
+
+// UserSettings holds a user's privacy preferences for this service.
+// @Description UserSettings model
+// @name UserSettings
+// @property user_id string
+// @property hide_last_seen bool
+// @property updated_at string
+
 package models
 
 import (
@@ -59,6 +200,14 @@ type Like struct {
 	TargetID  uuid.UUID `gorm:"type:uuid;not null" json:"target_id"`
 	CreatedAt time.Time `json:"created_at"`
 	Match     bool      `json:"match"`
+	// Note is an optional comment shown to the target with the like. It
+	// becomes the first message of the conversation on match.
+	Note string `gorm:"type:text" json:"note,omitempty"`
+	// NoteHeld is set when moderation held the note: the target does not see
+	// it, and its message is held for review on match.
+	NoteHeld bool `gorm:"not null;default:false" json:"note_held,omitempty"`
+	// Super marks a super-like; a match it leads to has source super_like.
+	Super bool `gorm:"not null;default:false" json:"super,omitempty"`
 }
 
 // Dislike represents a user disliking another user.
@@ -76,17 +225,125 @@ type Match struct {
 	User2ID   uuid.UUID  `gorm:"type:uuid;not null" json:"user2_id"`
 	CreatedAt time.Time  `json:"created_at"`
 	ExpireAt  *time.Time `json:"expire_at,omitempty"`
+	// PreviousMatchID is the expired match this one revives, if it was
+	// created by accepting a rematch request.
+	PreviousMatchID *uuid.UUID `gorm:"type:uuid" json:"previous_match_id,omitempty"`
+	// InitiatorID is the user who liked (or asked for the rematch) first.
+	InitiatorID *uuid.UUID `gorm:"type:uuid" json:"initiator_id,omitempty"`
+	// InitiatorLikedAt and ResponderLikedAt are when each side liked; for a
+	// rematch, when it was requested and accepted.
+	InitiatorLikedAt *time.Time `json:"initiator_liked_at,omitempty"`
+	ResponderLikedAt *time.Time `json:"responder_liked_at,omitempty"`
+	Source           string     `gorm:"type:text;not null;default:like" json:"source"`
+	FirstMessageAt   *time.Time `json:"first_message_at,omitempty"`
+	// TimeToMatchSeconds is derived from the like timestamps for responses.
+	TimeToMatchSeconds *int64 `gorm:"-" json:"time_to_match_seconds,omitempty"`
+}
+
+// Match sources.
+const (
+	MatchSourceLike      = "like"
+	MatchSourceSuperLike = "super_like"
+	MatchSourceRematch   = "rematch"
+)
+
+// FillDerived computes TimeToMatchSeconds.
+func (m *Match) FillDerived() {
+	if m.InitiatorLikedAt != nil && m.ResponderLikedAt != nil {
+		seconds := int64(m.ResponderLikedAt.Sub(*m.InitiatorLikedAt).Seconds())
+		m.TimeToMatchSeconds = &seconds
+	}
+}
+
+// MatchSourceStats aggregates the matches of one source.
+type MatchSourceStats struct {
+	Source                       string   `json:"source"`
+	Matches                      int64    `json:"matches"`
+	WithFirstMessage             int64    `json:"with_first_message"`
+	AvgTimeToMatchSeconds        *float64 `json:"avg_time_to_match_seconds"`
+	AvgTimeToFirstMessageSeconds *float64 `json:"avg_time_to_first_message_seconds"`
 }
 
 // Message represents a message between matched users.
 type Message struct {
-	ID         uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
-	SenderID   uuid.UUID `gorm:"type:uuid;not null" json:"sender_id"`
-	ReceiverID uuid.UUID `gorm:"type:uuid;not null" json:"receiver_id"`
-	Content    string    `gorm:"type:text" json:"content"`
-	CreatedAt  time.Time `json:"created_at"`
-	Seen       bool      `json:"seen"`
-	Deleted    bool      `json:"deleted"`
+	ID uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	// MatchID is the match whose conversation the message belongs to. A
+	// later match between the same users starts a new conversation.
+	MatchID    uuid.UUID  `gorm:"type:uuid" json:"match_id"`
+	SenderID   uuid.UUID  `gorm:"type:uuid;not null" json:"sender_id"`
+	ReceiverID uuid.UUID  `gorm:"type:uuid;not null" json:"receiver_id"`
+	Content    string     `gorm:"type:text" json:"content"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Seen       bool       `json:"seen"`
+	Deleted    bool       `json:"deleted"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	Held       bool       `gorm:"not null;default:false" json:"held"`
+	ReplyToID  *uuid.UUID `gorm:"type:uuid" json:"reply_to_id,omitempty"`
+
+	Attachments []Attachment    `gorm:"-" json:"attachments,omitempty"`
+	Reactions   []ReactionCount `gorm:"-" json:"reactions,omitempty"`
+	ReplyTo     *MessagePreview `gorm:"-" json:"reply_to,omitempty"`
+}
+
+// MessagePreview is a compact view of a quoted message. When the quoted
+// message was deleted it is a tombstone with only ID and Deleted set.
+type MessagePreview struct {
+	ID            uuid.UUID  `json:"id"`
+	SenderID      *uuid.UUID `json:"sender_id,omitempty"`
+	Content       string     `json:"content,omitempty"`
+	HasAttachment bool       `json:"has_attachment,omitempty"`
+	Deleted       bool       `json:"deleted"`
+}
+
+// SearchResult is one message matching a search.
+type SearchResult struct {
+	MessageID uuid.UUID `json:"message_id"`
+	MatchID   uuid.UUID `json:"match_id"`
+	SenderID  uuid.UUID `json:"sender_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Snippet   string    `json:"snippet"`
+}
+
+// Reaction represents a user reacting to a message with an emoji. A user has
+// at most one reaction per message.
+type Reaction struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	MessageID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_reactions_message_user" json:"message_id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_reactions_message_user" json:"user_id"`
+	Emoji     string    `gorm:"type:text;not null" json:"emoji"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReactionCount aggregates the reactions to a message for one emoji.
+type ReactionCount struct {
+	MessageID   uuid.UUID `json:"-"`
+	Emoji       string    `json:"emoji"`
+	Count       int       `json:"count"`
+	ReactedByMe bool      `json:"reacted_by_me"`
+}
+
+// Attachment kinds.
+const (
+	AttachmentKindImage = "image"
+	AttachmentKindAudio = "audio"
+)
+
+// Attachment represents a file uploaded to a match conversation. It is an
+// orphan until a message references it through MessageID.
+type Attachment struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UploaderID uuid.UUID  `gorm:"type:uuid;not null" json:"uploader_id"`
+	MatchID    uuid.UUID  `gorm:"type:uuid;not null" json:"match_id"`
+	MessageID  *uuid.UUID `gorm:"type:uuid" json:"message_id,omitempty"`
+	Kind       string     `gorm:"type:text;not null" json:"kind"`
+	MimeType   string     `gorm:"type:text;not null" json:"mime_type"`
+	Size       int64      `json:"size"`
+	Checksum   string     `gorm:"type:text;not null" json:"checksum"`
+	Width      *int       `json:"width,omitempty"`
+	Height     *int       `json:"height,omitempty"`
+	DurationMs *int       `json:"duration_ms,omitempty"`
+	StorageKey string     `gorm:"type:text;not null" json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
 }
 
 // Block represents a block between users.
@@ -97,3 +354,167 @@ type Block struct {
 	Reason    string    `gorm:"type:text" json:"reason"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+// Report statuses. Reported messages are kept by the retention purge while
+// their report is open.
+const (
+	ReportStatusOpen   = "open"
+	ReportStatusClosed = "closed"
+)
+
+// Report represents a user reporting a message for review.
+type Report struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	MessageID  uuid.UUID  `gorm:"type:uuid;not null" json:"message_id"`
+	ReporterID uuid.UUID  `gorm:"type:uuid;not null" json:"reporter_id"`
+	Reason     string     `gorm:"type:text" json:"reason"`
+	Status     string     `gorm:"type:text;not null" json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ClosedAt   *time.Time `json:"closed_at,omitempty"`
+}
+
+// ModerationDecision records a moderation filter's verdict on a message.
+// MessageID is nil when the message was rejected and never stored.
+type ModerationDecision struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	MessageID  *uuid.UUID `gorm:"type:uuid" json:"message_id,omitempty"`
+	SenderID   uuid.UUID  `gorm:"type:uuid;not null" json:"sender_id"`
+	ReceiverID uuid.UUID  `gorm:"type:uuid;not null" json:"receiver_id"`
+	Filter     string     `gorm:"type:text;not null" json:"filter"`
+	Action     string     `gorm:"type:text;not null" json:"action"`
+	Reason     string     `gorm:"type:text" json:"reason"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// UserErasure tracks the progress of erasing a deleted account's data so that
+// an interrupted erasure can be resumed.
+type UserErasure struct {
+	UserID      uuid.UUID  `gorm:"type:uuid;primaryKey" json:"user_id"`
+	Status      string     `gorm:"type:text;not null" json:"status"`
+	Step        string     `gorm:"type:text" json:"step"`
+	StartedAt   time.Time  `json:"started_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// Scheduled message statuses. Delivered scheduled messages are removed.
+const (
+	ScheduledStatusPending = "pending"
+	ScheduledStatusFailed  = "failed"
+)
+
+// ScheduledMessage is a message composed now and delivered by the dispatcher
+// at SendAt. It goes through the usual checks and moderation on delivery;
+// when they fail it is kept with status failed and the reason.
+type ScheduledMessage struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	SenderID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"sender_id"`
+	MatchID       uuid.UUID  `gorm:"type:uuid;not null" json:"match_id"`
+	Content       string     `gorm:"type:text" json:"content"`
+	AttachmentID  *uuid.UUID `gorm:"type:uuid" json:"attachment_id,omitempty"`
+	ReplyToID     *uuid.UUID `gorm:"type:uuid" json:"reply_to_id,omitempty"`
+	IcebreakerID  *uuid.UUID `gorm:"type:uuid" json:"icebreaker_id,omitempty"`
+	SendAt        time.Time  `gorm:"not null;index:idx_scheduled_messages_due,priority:2" json:"send_at"`
+	Status        string     `gorm:"type:text;not null;index:idx_scheduled_messages_due,priority:1" json:"status"`
+	FailureReason string     `gorm:"type:text" json:"failure_reason,omitempty"`
+	// Attempts counts deliveries that failed with a transient error; the
+	// next one is not tried before NextAttemptAt.
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// UserSettings holds a user's privacy preferences. Users without a row use
+// the defaults.
+type UserSettings struct {
+	UserID       uuid.UUID `gorm:"type:uuid;primaryKey" json:"user_id"`
+	HideLastSeen bool      `gorm:"not null;default:false" json:"hide_last_seen"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Icebreaker is a curated first message suggested to new matches. Retired
+// icebreakers are deactivated rather than deleted so their usage stays
+// measurable.
+type Icebreaker struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	Locale    string    `gorm:"type:text;not null;index" json:"locale"`
+	Text      string    `gorm:"type:text;not null" json:"text"`
+	Active    bool      `gorm:"not null;default:true" json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IcebreakerUsage records that an icebreaker was sent in a match, and when
+// the other participant first replied.
+type IcebreakerUsage struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	IcebreakerID uuid.UUID  `gorm:"type:uuid;not null;index" json:"icebreaker_id"`
+	MatchID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"match_id"`
+	MessageID    uuid.UUID  `gorm:"type:uuid;not null" json:"message_id"`
+	SenderID     uuid.UUID  `gorm:"type:uuid;not null" json:"sender_id"`
+	SentAt       time.Time  `json:"sent_at"`
+	RepliedAt    *time.Time `json:"replied_at,omitempty"`
+}
+
+// IcebreakerStats is how often an icebreaker was sent and replied to.
+type IcebreakerStats struct {
+	Icebreaker
+	Sent      int64   `json:"sent"`
+	Replied   int64   `json:"replied"`
+	ReplyRate float64 `json:"reply_rate"`
+}
+
+// MatchExtension records a participant pushing back a match's expiry. The
+// number of rows per user and match is what the extension quota counts.
+type MatchExtension struct {
+	ID               uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	MatchID          uuid.UUID `gorm:"type:uuid;not null;index" json:"match_id"`
+	UserID           uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	PreviousExpireAt time.Time `json:"previous_expire_at"`
+	ExpireAt         time.Time `json:"expire_at"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// Rematch request statuses.
+const (
+	RematchStatusPending  = "pending"
+	RematchStatusAccepted = "accepted"
+	RematchStatusDeclined = "declined"
+)
+
+// RematchRequest asks the other participant of an expired match to revive
+// it. Accepting creates a new match pointing back to the expired one.
+type RematchRequest struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	MatchID     uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_rematch_requests_match_requester" json:"match_id"`
+	RequesterID uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_rematch_requests_match_requester" json:"requester_id"`
+	TargetID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"target_id"`
+	Status      string     `gorm:"type:text;not null" json:"status"`
+	NewMatchID  *uuid.UUID `gorm:"type:uuid" json:"new_match_id,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+}
+
+// All returns every model of the service. The schema itself is created by
+// the SQL migrations; tests use this list to check they agree.
+func All() []interface{} {
+	return []interface{}{
+		&Like{},
+		&Dislike{},
+		&Match{},
+		&Message{},
+		&Block{},
+		&Attachment{},
+		&Reaction{},
+		&Report{},
+		&ModerationDecision{},
+		&UserErasure{},
+		&UserSettings{},
+		&ScheduledMessage{},
+		&Icebreaker{},
+		&IcebreakerUsage{},
+		&MatchExtension{},
+		&RematchRequest{},
+	}
+}
@@ -0,0 +1,166 @@
+package moderation
+
+import (
+	"context"
+	"crypto/sha256"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// maskMatches replaces every match of re in s with asterisks.
+func maskMatches(re *regexp.Regexp, s string) string {
+	return re.ReplaceAllStringFunc(s, func(m string) string {
+		return strings.Repeat("*", utf8.RuneCountInString(m))
+	})
+}
+
+// patternDecision applies action to the matches of re in content.
+func patternDecision(name string, action Action, re *regexp.Regexp, content, reason string) Decision {
+	if !re.MatchString(content) {
+		return Decision{Filter: name, Action: Allow}
+	}
+	d := Decision{Filter: name, Action: action, Reason: reason}
+	if action == Mask {
+		d.Content = maskMatches(re, content)
+	}
+	return d
+}
+
+// WordList flags content containing any of a list of words, matched
+// case-insensitively on word boundaries.
+type WordList struct {
+	name   string
+	action Action
+	re     *regexp.Regexp
+}
+
+// NewWordList returns a WordList filter applying action to words.
+func NewWordList(name string, words []string, action Action) *WordList {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = regexp.QuoteMeta(w)
+	}
+	re := regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	return &WordList{name: name, action: action, re: re}
+}
+
+func (f *WordList) Name() string { return f.name }
+
+func (f *WordList) Check(_ context.Context, in Input) Decision {
+	return patternDecision(f.name, f.action, f.re, in.Content, "contains a listed word")
+}
+
+var contactInfoPattern = regexp.MustCompile(strings.Join([]string{
+	// E-mail addresses.
+	`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`,
+	// Phone numbers: nine or more digits, optionally separated.
+	`\+?\d(?:[\s\-.()]*\d){8,}`,
+	// Payment handles and links.
+	`(?i)\b(?:paypal\.me|revolut\.me|venmo|cash\s?app|lydia)\b(?:[/:@\s]*[\w.\-]+)?`,
+	// Cash tags such as $alice.
+	`\$[A-Za-z][A-Za-z0-9_]{1,19}\b`,
+}, "|"))
+
+// ContactInfo detects attempts to move the conversation off the platform:
+// phone numbers, e-mail addresses and payment handles.
+type ContactInfo struct {
+	Action Action
+}
+
+func (f *ContactInfo) Name() string { return "contact_info" }
+
+func (f *ContactInfo) Check(_ context.Context, in Input) Decision {
+	return patternDecision(f.Name(), f.Action, contactInfoPattern, in.Content, "contains contact or payment details")
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+|\b[a-z0-9\-]+\.(?:com|net|org|io|me|fr|co|ly|link|xyz|app|site)\b(?:/\S*)?`)
+
+// Links detects URLs and bare domain names.
+type Links struct {
+	Action Action
+}
+
+func (f *Links) Name() string { return "links" }
+
+func (f *Links) Check(_ context.Context, in Input) Decision {
+	return patternDecision(f.Name(), f.Action, linkPattern, in.Content, "contains a link")
+}
+
+// RepeatedMessages rejects a message once its sender already sent the same
+// text Threshold times within Window, whoever the recipients were. State is
+// kept in memory.
+type RepeatedMessages struct {
+	Threshold int
+	Window    time.Duration
+
+	mu        sync.Mutex
+	seen      map[uuid.UUID]map[[sha256.Size]byte][]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewRepeatedMessages returns a RepeatedMessages filter.
+func NewRepeatedMessages(threshold int, window time.Duration) *RepeatedMessages {
+	return &RepeatedMessages{
+		Threshold: threshold,
+		Window:    window,
+		seen:      make(map[uuid.UUID]map[[sha256.Size]byte][]time.Time),
+		now:       time.Now,
+	}
+}
+
+func (f *RepeatedMessages) Name() string { return "repeated_messages" }
+
+func (f *RepeatedMessages) Check(_ context.Context, in Input) Decision {
+	key := sha256.Sum256([]byte(strings.ToLower(strings.Join(strings.Fields(in.Content), " "))))
+	now := f.now()
+	cutoff := now.Add(-f.Window)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if now.Sub(f.lastSweep) > f.Window {
+		// Forget senders that have been quiet for a whole window.
+		for sender, bySender := range f.seen {
+			if sender != in.SenderID && pruneBefore(bySender, cutoff) {
+				delete(f.seen, sender)
+			}
+		}
+		f.lastSweep = now
+	}
+	bySender := f.seen[in.SenderID]
+	if bySender == nil {
+		bySender = make(map[[sha256.Size]byte][]time.Time)
+		f.seen[in.SenderID] = bySender
+	}
+	pruneBefore(bySender, cutoff)
+	previous := len(bySender[key])
+	bySender[key] = append(bySender[key], now)
+	if previous >= f.Threshold {
+		return Decision{Filter: f.Name(), Action: Reject, Reason: "same message sent too many times"}
+	}
+	return Decision{Filter: f.Name(), Action: Allow}
+}
+
+// pruneBefore drops timestamps older than cutoff and reports whether nothing
+// is left.
+func pruneBefore(bySender map[[sha256.Size]byte][]time.Time, cutoff time.Time) bool {
+	for k, times := range bySender {
+		kept := times[:0]
+		for _, t := range times {
+			if t.After(cutoff) {
+				kept = append(kept, t)
+			}
+		}
+		if len(kept) == 0 {
+			delete(bySender, k)
+		} else {
+			bySender[k] = kept
+		}
+	}
+	return len(bySender) == 0
+}
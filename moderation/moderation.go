@@ -0,0 +1,154 @@
+// Package moderation screens user-written text before it is stored.
+//
+// A Chain runs a list of Filters in order. Each filter returns a Decision
+// whose Action is one of Allow, Mask, Hold or Reject; the most severe action
+// wins. Masking filters rewrite the content seen by the following filters,
+// and a rejection stops the chain.
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"way-d-interactions/config"
+
+	"github.com/google/uuid"
+)
+
+// Action is the outcome of a moderation check, ordered by severity.
+type Action int
+
+const (
+	// Allow lets the content through unchanged.
+	Allow Action = iota
+	// Mask lets the content through with the offending parts replaced.
+	Mask
+	// Hold stores the content but hides it from the recipient until a
+	// moderator reviews it.
+	Hold
+	// Reject refuses the content.
+	Reject
+)
+
+var actionNames = map[Action]string{Allow: "allow", Mask: "mask", Hold: "hold", Reject: "reject"}
+
+func (a Action) String() string {
+	if name, ok := actionNames[a]; ok {
+		return name
+	}
+	return fmt.Sprintf("action(%d)", int(a))
+}
+
+// ParseAction parses an action name such as "hold".
+func ParseAction(s string) (Action, error) {
+	for a, name := range actionNames {
+		if strings.EqualFold(s, name) {
+			return a, nil
+		}
+	}
+	return Allow, fmt.Errorf("unknown moderation action %q", s)
+}
+
+// Input is the content being moderated and its context.
+type Input struct {
+	SenderID   uuid.UUID
+	ReceiverID uuid.UUID
+	Content    string
+}
+
+// Decision is a single filter's verdict. Content holds the rewritten text
+// when Action is Mask.
+type Decision struct {
+	Filter  string
+	Action  Action
+	Reason  string
+	Content string
+}
+
+// Filter inspects an Input and returns a Decision. Filters that find
+// nothing return a Decision with Action Allow.
+type Filter interface {
+	Name() string
+	Check(ctx context.Context, in Input) Decision
+}
+
+// Result is the combined outcome of a Chain.
+type Result struct {
+	Action  Action
+	Content string
+	// Decisions lists every non-Allow decision, in filter order.
+	Decisions []Decision
+}
+
+// Chain runs filters in order.
+type Chain struct {
+	Filters []Filter
+}
+
+// Run applies the chain to in.
+func (c *Chain) Run(ctx context.Context, in Input) Result {
+	result := Result{Action: Allow, Content: in.Content}
+	for _, f := range c.Filters {
+		d := f.Check(ctx, in)
+		if d.Action == Allow {
+			continue
+		}
+		if d.Filter == "" {
+			d.Filter = f.Name()
+		}
+		result.Decisions = append(result.Decisions, d)
+		if d.Action > result.Action {
+			result.Action = d.Action
+		}
+		if d.Action == Mask {
+			in.Content = d.Content
+			result.Content = d.Content
+		}
+		if d.Action == Reject {
+			break
+		}
+	}
+	return result
+}
+
+var (
+	defaultChain *Chain
+	defaultOnce  sync.Once
+)
+
+// Default returns the chain configured by the MODERATION_* settings. It is
+// built once and shared.
+func Default() *Chain {
+	defaultOnce.Do(func() {
+		defaultChain = NewChain(config.Get().Moderation)
+	})
+	return defaultChain
+}
+
+// NewChain builds the built-in filter chain:
+//   - blocked words reject the content;
+//   - masked words are masked;
+//   - phone numbers, e-mail addresses and payment handles get the contact
+//     action, links the URL action;
+//   - a message is rejected once its sender already sent the same text
+//     SpamThreshold times within SpamWindow, unless the threshold is 0.
+//
+// The actions of cfg must be valid, as config.Validate ensures.
+func NewChain(cfg config.ModerationConfig) *Chain {
+	chain := &Chain{}
+	if len(cfg.BlockedWords) > 0 {
+		chain.Filters = append(chain.Filters, NewWordList("blocked_words", cfg.BlockedWords, Reject))
+	}
+	if len(cfg.MaskedWords) > 0 {
+		chain.Filters = append(chain.Filters, NewWordList("masked_words", cfg.MaskedWords, Mask))
+	}
+	contact, _ := ParseAction(cfg.ContactAction)
+	links, _ := ParseAction(cfg.URLAction)
+	chain.Filters = append(chain.Filters, &ContactInfo{Action: contact}, &Links{Action: links})
+	if cfg.SpamThreshold > 0 {
+		chain.Filters = append(chain.Filters, NewRepeatedMessages(cfg.SpamThreshold, cfg.SpamWindow))
+	}
+	return chain
+}
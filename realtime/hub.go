@@ -0,0 +1,106 @@
+// Package realtime delivers ephemeral events to connected users.
+package realtime
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event is pushed to a user's open event streams.
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+	At   time.Time   `json:"at"`
+}
+
+// subscriberBuffer is the number of events queued per connection before new
+// events are dropped for it.
+const subscriberBuffer = 32
+
+// Hub fans events out to every open connection of a user. Delivery is best
+// effort: events for users without a connection, or with a full buffer, are
+// dropped.
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[uuid.UUID]map[chan Event]struct{}
+	closed      bool
+}
+
+// NewHub returns an empty hub.
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[uuid.UUID]map[chan Event]struct{})}
+}
+
+// Subscribe opens a connection for userID. The returned function closes it.
+// Once the hub is closed the returned channel is already closed.
+func (h *Hub) Subscribe(userID uuid.UUID) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[chan Event]struct{})
+	}
+	h.subscribers[userID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subscribers[userID][ch]; !ok {
+			return
+		}
+		delete(h.subscribers[userID], ch)
+		if len(h.subscribers[userID]) == 0 {
+			delete(h.subscribers, userID)
+		}
+		close(ch)
+	}
+}
+
+// Close closes every connection, ending their event streams, and refuses
+// new ones. It is called on shutdown so open streams do not hold up the
+// drain of in-flight requests.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for _, conns := range h.subscribers {
+		for ch := range conns {
+			close(ch)
+		}
+	}
+	h.subscribers = make(map[uuid.UUID]map[chan Event]struct{})
+}
+
+// Publish sends an event of type eventType to every connection of userID.
+func (h *Hub) Publish(userID uuid.UUID, eventType string, data interface{}) {
+	event := Event{Type: eventType, Data: data, At: time.Now().UTC()}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch := range h.subscribers[userID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Connected reports whether userID has at least one open connection.
+func (h *Hub) Connected(userID uuid.UUID) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers[userID]) > 0
+}
+
+var defaultHub = NewHub()
+
+// Default returns the process-wide hub.
+func Default() *Hub {
+	return defaultHub
+}
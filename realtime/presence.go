@@ -0,0 +1,135 @@
+package realtime
+
+import (
+	"sync"
+	"time"
+
+	"way-d-interactions/config"
+
+	"github.com/google/uuid"
+)
+
+// Presence tracks which users are online, from their open event streams, and
+// when they were last seen. State is kept in memory only: a restart forgets
+// every last seen time.
+type Presence struct {
+	// OnlineTTL is how long a connection counts as online without a Touch,
+	// so a stream that died without closing does not stay online forever.
+	OnlineTTL time.Duration
+	// Retention is how long a last seen time is remembered once the user
+	// has no connection left.
+	Retention time.Duration
+
+	mu        sync.Mutex
+	users     map[uuid.UUID]*presenceEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type presenceEntry struct {
+	conns    int
+	lastSeen time.Time
+}
+
+// Status is a user's presence as reported to other users.
+type Status struct {
+	Online   bool
+	LastSeen time.Time
+}
+
+// NewPresence returns an empty tracker.
+func NewPresence(onlineTTL, retention time.Duration) *Presence {
+	return &Presence{
+		OnlineTTL: onlineTTL,
+		Retention: retention,
+		users:     make(map[uuid.UUID]*presenceEntry),
+		now:       time.Now,
+	}
+}
+
+// Connect records a new connection for userID and reports whether the user
+// was offline before.
+func (p *Presence) Connect(userID uuid.UUID) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	p.sweep(now)
+	e := p.users[userID]
+	if e == nil {
+		e = &presenceEntry{}
+		p.users[userID] = e
+	}
+	wasOnline := e.online(now, p.OnlineTTL)
+	e.conns++
+	e.lastSeen = now
+	return !wasOnline
+}
+
+// Touch refreshes the last seen time of a connected user.
+func (p *Presence) Touch(userID uuid.UUID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e := p.users[userID]; e != nil {
+		e.lastSeen = p.now()
+	}
+}
+
+// Disconnect closes one connection of userID and reports whether it was the
+// last one.
+func (p *Presence) Disconnect(userID uuid.UUID) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := p.users[userID]
+	if e == nil || e.conns == 0 {
+		return false
+	}
+	e.conns--
+	e.lastSeen = p.now()
+	return e.conns == 0
+}
+
+// Status returns the presence of userID. ok is false when the user has not
+// been seen within Retention.
+func (p *Presence) Status(userID uuid.UUID) (status Status, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	e := p.users[userID]
+	if e == nil || (e.conns == 0 && now.Sub(e.lastSeen) > p.Retention) {
+		return Status{}, false
+	}
+	return Status{Online: e.online(now, p.OnlineTTL), LastSeen: e.lastSeen}, true
+}
+
+func (e *presenceEntry) online(now time.Time, ttl time.Duration) bool {
+	return e.conns > 0 && now.Sub(e.lastSeen) <= ttl
+}
+
+// sweep forgets users not seen within Retention, including connections
+// that stopped refreshing long ago. It runs at most once per OnlineTTL.
+func (p *Presence) sweep(now time.Time) {
+	if now.Sub(p.lastSweep) < p.OnlineTTL {
+		return
+	}
+	for id, e := range p.users {
+		if now.Sub(e.lastSeen) > p.Retention {
+			delete(p.users, id)
+		}
+	}
+	p.lastSweep = now
+}
+
+var (
+	defaultPresence *Presence
+	presenceOnce    sync.Once
+)
+
+// DefaultPresence returns the process-wide tracker, configured by
+// PRESENCE_ONLINE_TTL and PRESENCE_RETENTION.
+func DefaultPresence() *Presence {
+	presenceOnce.Do(func() {
+		cfg := config.Get().Presence
+		defaultPresence = NewPresence(cfg.OnlineTTL, cfg.Retention)
+	})
+	return defaultPresence
+}
@@ -1,29 +1,89 @@
 package routes
 
 import (
-	"log"
 	"time"
 	"way-d-interactions/config"
 	"way-d-interactions/controllers"
+	"way-d-interactions/logging"
+	"way-d-interactions/metrics"
 	"way-d-interactions/middleware"
 	"way-d-interactions/models"
+	"way-d-interactions/store"
+	"way-d-interactions/tracing"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
+// RegisterRoutes registers every route, with handlers backed by the
+// configured database.
 func RegisterRoutes(r *gin.Engine) {
+	RegisterRoutesWith(r, controllers.NewHandler(store.NewGorm(config.GetDB())))
+}
+
+// RegisterProbes registers the unauthenticated liveness and readiness
+// probes.
+func RegisterProbes(r *gin.Engine, p *controllers.Probes) {
+	r.GET("/healthz", p.Healthz)
+	r.GET("/readyz", p.Readyz)
+}
+
+// RegisterRoutesWith registers every route, serving them from h. The debug
+// routes still read the configured database.
+func RegisterRoutesWith(r *gin.Engine, h *controllers.Handler) {
 	api := r.Group("/api")
 	api.Use(middleware.AuthRequired())
 	{
-		api.POST("/like", controllers.PostLike)
-		api.POST("/dislike", controllers.PostDislike)
-		api.GET("/matches", controllers.GetMatches)
-		api.POST("/message", controllers.PostMessage)
-		api.GET("/messages/:match_id", controllers.GetMessages)
-		api.POST("/block", controllers.PostBlock)
-		api.GET("/blocks", controllers.GetBlocks)
-		api.GET("/exclusions", controllers.GetExclusions)
+		api.POST("/like", h.PostLike)
+		api.GET("/likes/received", h.GetReceivedLikes)
+		api.POST("/dislike", h.PostDislike)
+		api.GET("/matches", h.GetMatches)
+		api.POST("/message", h.PostMessage)
+		api.GET("/messages/search", h.SearchMessages)
+		api.GET("/messages/scheduled", h.GetScheduledMessages)
+		api.DELETE("/messages/scheduled/:id", h.DeleteScheduledMessage)
+		api.GET("/messages/:match_id", h.GetMessages)
+		api.DELETE("/messages/:id", h.DeleteMessage)
+		api.POST("/messages/:id/report", h.PostReport)
+		api.POST("/messages/:id/reactions", h.PostReaction)
+		api.DELETE("/messages/:id/reactions", h.DeleteReaction)
+		api.GET("/events", h.GetEvents)
+		api.POST("/block", h.PostBlock)
+		api.GET("/blocks", h.GetBlocks)
+		api.GET("/exclusions", h.GetExclusions)
+		api.GET("/me/export", h.GetExport)
+		api.GET("/me/settings", h.GetSettings)
+		api.PUT("/me/settings", h.PutSettings)
+		api.POST("/matches/:id/attachments", h.PostAttachment)
+		api.POST("/matches/:id/typing", h.PostTyping)
+		api.GET("/matches/:id/presence", h.GetPresence)
+		api.GET("/matches/:id/icebreakers", h.GetMatchIcebreakers)
+		api.POST("/matches/:id/extend", h.PostMatchExtension)
+		api.POST("/matches/:id/rematch", h.PostRematchRequest)
+		api.GET("/rematches", h.GetRematchRequests)
+		api.POST("/rematches/:id/accept", h.AcceptRematchRequest)
+		api.POST("/rematches/:id/decline", h.DeclineRematchRequest)
+		api.GET("/attachments/:id/url", h.GetAttachmentURL)
+	}
+
+	// Signed download links are used directly by image and audio elements,
+	// so they authenticate with their signature instead of a JWT.
+	r.GET("/attachments/:id", h.DownloadAttachment)
+
+	internal := r.Group("/internal")
+	internal.Use(middleware.InternalOnly())
+	{
+		internal.DELETE("/users/:id", h.DeleteUserData)
+		internal.POST("/reports/:id/close", h.CloseReport)
+		internal.POST("/retention/purge", h.PostRetentionPurge)
+		internal.GET("/moderation/queue", h.GetModerationQueue)
+		internal.POST("/moderation/messages/:id/review", h.PostModerationReview)
+		internal.GET("/icebreakers", h.ListIcebreakers)
+		internal.GET("/icebreakers/stats", h.GetIcebreakerStats)
+		internal.GET("/analytics/matches", h.GetMatchAnalytics)
+		internal.POST("/icebreakers", h.CreateIcebreaker)
+		internal.PUT("/icebreakers/:id", h.UpdateIcebreaker)
+		internal.DELETE("/icebreakers/:id", h.DeleteIcebreaker)
 	}
 
 	r.GET("/debug/likes", func(c *gin.Context) {
@@ -46,33 +106,45 @@ func RegisterRoutes(r *gin.Engine) {
 	})
 	r.POST("/debug/clear", func(c *gin.Context) {
 		db := config.GetDB()
+		db.Exec("DELETE FROM moderation_decisions")
+		db.Exec("DELETE FROM reports")
+		db.Exec("DELETE FROM reactions")
+		db.Exec("DELETE FROM attachments")
 		db.Exec("DELETE FROM messages")
 		db.Exec("DELETE FROM matches")
 		db.Exec("DELETE FROM likes")
 		db.Exec("DELETE FROM dislikes")
 		db.Exec("DELETE FROM blocks")
+		db.Exec("DELETE FROM user_settings")
+		db.Exec("DELETE FROM scheduled_messages")
+		db.Exec("DELETE FROM icebreaker_usages")
+		db.Exec("DELETE FROM match_extensions")
+		db.Exec("DELETE FROM rematch_requests")
 		c.JSON(200, gin.H{"status": "cleared"})
 	})
 }
 
 func SetupRouter() *gin.Engine {
-	r := gin.Default()
+	// The request log line of logging.Middleware replaces gin's logger.
+	r := gin.New()
 
 	r.SetTrustedProxies([]string{"127.0.0.1"})
 
+	r.Use(tracing.Middleware())
+	r.Use(logging.Middleware())
+	r.Use(gin.Recovery())
+
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:8083"},
+		AllowOrigins:     config.Get().CORSAllowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "traceparent", "tracestate", logging.RequestIDHeader},
+		ExposeHeaders:    []string{"Content-Length", tracing.TraceIDHeader, logging.RequestIDHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
-	r.Use(func(c *gin.Context) {
-		log.Printf("[INFO] %s %s from %s", c.Request.Method, c.Request.URL.Path, c.ClientIP())
-		c.Next()
-	})
+	r.Use(metrics.Middleware())
+	r.GET("/metrics", metrics.Handler())
 
 	return r
 }
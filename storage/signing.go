@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// SignedURL describes a time-limited download link for one user.
+type SignedURL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func signature(secret []byte, objectID, userID string, expires int64) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%d", objectID, userID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign returns a link to basePath+objectID that only userID can use until
+// expiresAt.
+func Sign(secret []byte, basePath, objectID, userID string, expiresAt time.Time) SignedURL {
+	expires := expiresAt.Unix()
+	q := url.Values{}
+	q.Set("user", userID)
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("sig", signature(secret, objectID, userID, expires))
+	return SignedURL{URL: basePath + objectID + "?" + q.Encode(), ExpiresAt: time.Unix(expires, 0).UTC()}
+}
+
+// Verify checks the user, expires and sig query parameters of a signed link
+// for objectID and returns the user it was issued to.
+func Verify(secret []byte, objectID string, query url.Values, now time.Time) (string, bool) {
+	userID := query.Get("user")
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || userID == "" || now.Unix() > expires {
+		return "", false
+	}
+	want := signature(secret, objectID, userID, expires)
+	if !hmac.Equal([]byte(want), []byte(query.Get("sig"))) {
+		return "", false
+	}
+	return userID, true
+}
@@ -0,0 +1,369 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"way-d-interactions/models"
+	"way-d-interactions/workers"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NewGorm returns stores backed by db.
+func NewGorm(db *gorm.DB) Stores {
+	return Stores{
+		Likes:       gormLikes{db},
+		Matches:     gormMatches{db},
+		Messages:    gormMessages{db},
+		Blocks:      gormBlocks{db},
+		Attachments: gormAttachments{db},
+		Reactions:   gormReactions{db},
+		Icebreakers: gormIcebreakers{db},
+		Moderation:  gormModeration{db},
+		Scheduled:   gormScheduled{db},
+		Reports:     gormReports{db},
+		Rematches:   gormRematches{db},
+		Settings:    gormSettings{db},
+		Erasure:     gormErasure{db},
+		Exports:     gormExports{db},
+		atomic: func(ctx context.Context, _ Stores, fn func(Stores) error) error {
+			return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error { return fn(NewGorm(tx)) })
+		},
+	}
+}
+
+// first loads the first row of query into dest, mapping a missing row to
+// ErrNotFound.
+func first(query *gorm.DB, dest interface{}) error {
+	err := query.First(dest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// created maps a unique violation of an insert to ErrConflict.
+func created(err error) error {
+	if IsUniqueViolation(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+type gormLikes struct{ db *gorm.DB }
+
+func (s gormLikes) FindLike(ctx context.Context, userID, targetID uuid.UUID) (models.Like, error) {
+	var like models.Like
+	err := first(s.db.WithContext(ctx).Where("user_id = ? AND target_id = ?", userID, targetID), &like)
+	return like, err
+}
+
+func (s gormLikes) FindDislike(ctx context.Context, userID, targetID uuid.UUID) (models.Dislike, error) {
+	var dislike models.Dislike
+	err := first(s.db.WithContext(ctx).Where("user_id = ? AND target_id = ?", userID, targetID), &dislike)
+	return dislike, err
+}
+
+func (s gormLikes) CreateLike(ctx context.Context, like *models.Like) error {
+	return created(s.db.WithContext(ctx).Create(like).Error)
+}
+
+func (s gormLikes) CreateDislike(ctx context.Context, dislike *models.Dislike) error {
+	return created(s.db.WithContext(ctx).Create(dislike).Error)
+}
+
+func (s gormLikes) MarkMatched(ctx context.Context, likeID uuid.UUID) error {
+	return s.db.WithContext(ctx).Model(&models.Like{}).Where("id = ?", likeID).Update("match", true).Error
+}
+
+func (s gormLikes) DeletePair(ctx context.Context, a, b uuid.UUID) error {
+	db := s.db.WithContext(ctx)
+	if err := db.Where("(user_id = ? AND target_id = ?) OR (user_id = ? AND target_id = ?)", a, b, b, a).Delete(&models.Like{}).Error; err != nil {
+		return err
+	}
+	return db.Where("(user_id = ? AND target_id = ?) OR (user_id = ? AND target_id = ?)", a, b, b, a).Delete(&models.Dislike{}).Error
+}
+
+func (s gormLikes) ListReceived(ctx context.Context, targetID uuid.UUID, limit int) ([]models.Like, error) {
+	var likes []models.Like
+	err := s.db.WithContext(ctx).
+		Where("target_id = ? AND match = false", targetID).
+		Where("NOT EXISTS (SELECT 1 FROM blocks WHERE (blocks.user_id = likes.user_id AND blocks.blocked_id = likes.target_id) OR (blocks.user_id = likes.target_id AND blocks.blocked_id = likes.user_id))").
+		Where("NOT EXISTS (SELECT 1 FROM dislikes WHERE dislikes.user_id = likes.target_id AND dislikes.target_id = likes.user_id)").
+		Order("created_at desc").Limit(limit).Find(&likes).Error
+	return likes, err
+}
+
+func (s gormLikes) Excluded(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	var rows []string
+	err := s.db.WithContext(ctx).Raw(`
+		SELECT target_id FROM likes WHERE user_id = @id
+		UNION
+		SELECT target_id FROM dislikes WHERE user_id = @id
+		UNION
+		SELECT CASE WHEN user1_id = @id THEN user2_id ELSE user1_id END FROM matches WHERE user1_id = @id OR user2_id = @id
+		UNION
+		SELECT blocked_id FROM blocks WHERE user_id = @id
+		UNION
+		SELECT user_id FROM blocks WHERE blocked_id = @id
+	`, map[string]interface{}{"id": userID}).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	excluded := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		id, err := uuid.Parse(row)
+		if err != nil {
+			return nil, err
+		}
+		excluded = append(excluded, id)
+	}
+	return excluded, nil
+}
+
+type gormMatches struct{ db *gorm.DB }
+
+func (s gormMatches) Create(ctx context.Context, match *models.Match) error {
+	return s.db.WithContext(ctx).Create(match).Error
+}
+
+func (s gormMatches) ListForUser(ctx context.Context, userID uuid.UUID) ([]models.Match, error) {
+	var matches []models.Match
+	err := s.db.WithContext(ctx).Where("user1_id = ? OR user2_id = ?", userID, userID).Find(&matches).Error
+	return matches, err
+}
+
+func (s gormMatches) ListBetween(ctx context.Context, a, b uuid.UUID) ([]models.Match, error) {
+	var matches []models.Match
+	err := s.db.WithContext(ctx).Where("(user1_id = ? AND user2_id = ?) OR (user1_id = ? AND user2_id = ?)", a, b, b, a).Find(&matches).Error
+	return matches, err
+}
+
+func (s gormMatches) FindForParticipant(ctx context.Context, matchID, userID uuid.UUID) (models.Match, error) {
+	var match models.Match
+	err := first(s.db.WithContext(ctx).Where("id = ? AND (user1_id = ? OR user2_id = ?)", matchID, userID, userID), &match)
+	return match, err
+}
+
+func (s gormMatches) MarkFirstMessage(ctx context.Context, matchID uuid.UUID, at time.Time) error {
+	return s.db.WithContext(ctx).Model(&models.Match{}).Where("id = ? AND first_message_at IS NULL", matchID).Update("first_message_at", at).Error
+}
+
+func (s gormMatches) DeletePair(ctx context.Context, a, b uuid.UUID) error {
+	db := s.db.WithContext(ctx)
+	pair := db.Model(&models.Match{}).Select("id").Where("(user1_id = ? AND user2_id = ?) OR (user1_id = ? AND user2_id = ?)", a, b, b, a)
+	if err := db.Where("match_id IN (?)", pair).Delete(&models.MatchExtension{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("match_id IN (?)", pair).Delete(&models.RematchRequest{}).Error; err != nil {
+		return err
+	}
+	return db.Where("(user1_id = ? AND user2_id = ?) OR (user1_id = ? AND user2_id = ?)", a, b, b, a).Delete(&models.Match{}).Error
+}
+
+func (s gormMatches) Find(ctx context.Context, id uuid.UUID) (models.Match, error) {
+	var match models.Match
+	err := first(s.db.WithContext(ctx).Where("id = ?", id), &match)
+	return match, err
+}
+
+func (s gormMatches) Lock(ctx context.Context, id uuid.UUID) (models.Match, error) {
+	var match models.Match
+	err := first(s.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id), &match)
+	return match, err
+}
+
+func (s gormMatches) HasLive(ctx context.Context, a, b uuid.UUID) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.Match{}).
+		Where("((user1_id = ? AND user2_id = ?) OR (user1_id = ? AND user2_id = ?)) AND (expire_at IS NULL OR expire_at > ?)", a, b, b, a, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (s gormMatches) ListOpen(ctx context.Context, userID uuid.UUID) ([]models.Match, error) {
+	var matches []models.Match
+	err := s.db.WithContext(ctx).
+		Where("(user1_id = ? OR user2_id = ?) AND (expire_at IS NULL OR expire_at > ?)", userID, userID, time.Now()).
+		Where("NOT EXISTS (SELECT 1 FROM blocks WHERE (blocks.user_id = matches.user1_id AND blocks.blocked_id = matches.user2_id) OR (blocks.user_id = matches.user2_id AND blocks.blocked_id = matches.user1_id))").
+		Find(&matches).Error
+	return matches, err
+}
+
+func (s gormMatches) CountExtensions(ctx context.Context, matchID, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.MatchExtension{}).Where("match_id = ? AND user_id = ?", matchID, userID).Count(&count).Error
+	return count, err
+}
+
+func (s gormMatches) Extend(ctx context.Context, extension *models.MatchExtension) error {
+	db := s.db.WithContext(ctx)
+	if err := db.Create(extension).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.Match{}).Where("id = ?", extension.MatchID).Update("expire_at", extension.ExpireAt).Error
+}
+
+func (s gormMatches) Stats(ctx context.Context, since, until time.Time) ([]models.MatchSourceStats, error) {
+	var stats []models.MatchSourceStats
+	err := s.db.WithContext(ctx).Model(&models.Match{}).
+		Select(`source,
+			COUNT(*) AS matches,
+			COUNT(first_message_at) AS with_first_message,
+			AVG(EXTRACT(EPOCH FROM responder_liked_at - initiator_liked_at)) AS avg_time_to_match_seconds,
+			AVG(EXTRACT(EPOCH FROM first_message_at - created_at)) AS avg_time_to_first_message_seconds`).
+		Where("created_at >= ? AND created_at < ?", since, until).
+		Group("source").Order("source").
+		Scan(&stats).Error
+	return stats, err
+}
+
+type gormMessages struct{ db *gorm.DB }
+
+func (s gormMessages) Create(ctx context.Context, msg *models.Message) error {
+	return s.db.WithContext(ctx).Create(msg).Error
+}
+
+func (s gormMessages) ListConversation(ctx context.Context, match models.Match, viewerID uuid.UUID) ([]models.Message, error) {
+	var messages []models.Message
+	err := s.db.WithContext(ctx).
+		Where("match_id = ? AND deleted = false AND (held = false OR sender_id = ?)", match.ID, viewerID).
+		Order("created_at asc").Find(&messages).Error
+	return messages, err
+}
+
+func (s gormMessages) FindSent(ctx context.Context, id, senderID uuid.UUID) (models.Message, error) {
+	var msg models.Message
+	err := first(s.db.WithContext(ctx).Where("id = ? AND sender_id = ? AND deleted = false", id, senderID), &msg)
+	return msg, err
+}
+
+func (s gormMessages) SoftDelete(ctx context.Context, msg *models.Message, at time.Time) error {
+	msg.Deleted = true
+	msg.DeletedAt = &at
+	return s.db.WithContext(ctx).Model(msg).Updates(map[string]interface{}{"deleted": true, "deleted_at": at}).Error
+}
+
+func (s gormMessages) DeleteForMatches(ctx context.Context, matchIDs []uuid.UUID) error {
+	if len(matchIDs) == 0 {
+		return nil
+	}
+	db := s.db.WithContext(ctx)
+	conversations := db.Model(&models.Message{}).Select("id").Where("match_id IN ?", matchIDs)
+	if err := db.Where("message_id IN (?)", conversations).Delete(&models.Reaction{}).Error; err != nil {
+		return err
+	}
+	return db.Where("match_id IN ?", matchIDs).Delete(&models.Message{}).Error
+}
+
+func (s gormMessages) FindVisible(ctx context.Context, id, matchID, viewerID uuid.UUID) (models.Message, error) {
+	var msg models.Message
+	err := first(s.db.WithContext(ctx).Where("id = ? AND match_id = ? AND deleted = false AND (held = false OR sender_id = ?)", id, matchID, viewerID), &msg)
+	return msg, err
+}
+
+func (s gormMessages) FindForParticipant(ctx context.Context, id, userID uuid.UUID) (models.Message, error) {
+	var msg models.Message
+	err := first(s.db.WithContext(ctx).Where("id = ? AND deleted = false AND (sender_id = ? OR receiver_id = ?)", id, userID, userID), &msg)
+	return msg, err
+}
+
+func (s gormMessages) FindReceived(ctx context.Context, id, receiverID uuid.UUID) (models.Message, error) {
+	var msg models.Message
+	err := first(s.db.WithContext(ctx).Where("id = ? AND receiver_id = ?", id, receiverID), &msg)
+	return msg, err
+}
+
+func (s gormMessages) ListByIDs(ctx context.Context, ids []uuid.UUID) ([]models.Message, error) {
+	var messages []models.Message
+	if len(ids) == 0 {
+		return messages, nil
+	}
+	err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&messages).Error
+	return messages, err
+}
+
+func (s gormMessages) FindHeld(ctx context.Context, id uuid.UUID) (models.Message, error) {
+	var msg models.Message
+	err := first(s.db.WithContext(ctx).Where("id = ? AND held = true AND deleted = false", id), &msg)
+	return msg, err
+}
+
+func (s gormMessages) ListHeld(ctx context.Context, limit int) ([]models.Message, error) {
+	var messages []models.Message
+	err := s.db.WithContext(ctx).Where("held = true AND deleted = false").Order("created_at asc").Limit(limit).Find(&messages).Error
+	return messages, err
+}
+
+func (s gormMessages) Release(ctx context.Context, msg *models.Message) error {
+	msg.Held = false
+	return s.db.WithContext(ctx).Model(msg).Update("held", false).Error
+}
+
+// searchSQL matches messages of the user's live, unblocked matches against
+// the full-text index on messages.content.
+const searchSQL = `
+SELECT m.id AS message_id, mt.id AS match_id, m.sender_id, m.created_at,
+	ts_headline('simple', m.content, q, @options) AS snippet
+FROM messages m
+JOIN matches mt ON mt.id = m.match_id
+CROSS JOIN websearch_to_tsquery('simple', @query) q
+WHERE (m.sender_id = @user OR m.receiver_id = @user)
+	AND (mt.expire_at IS NULL OR mt.expire_at > @now)
+	AND m.deleted = false
+	AND (m.held = false OR m.sender_id = @user)
+	AND to_tsvector('simple', m.content) @@ q
+	AND NOT EXISTS (
+		SELECT 1 FROM blocks b
+		WHERE (b.user_id = m.sender_id AND b.blocked_id = m.receiver_id)
+			OR (b.user_id = m.receiver_id AND b.blocked_id = m.sender_id))
+ORDER BY ts_rank(to_tsvector('simple', m.content), q) DESC, m.created_at DESC
+LIMIT @limit OFFSET @offset`
+
+func (s gormMessages) Search(ctx context.Context, userID uuid.UUID, query string, limit, offset int) ([]models.SearchResult, error) {
+	var results []models.SearchResult
+	err := s.db.WithContext(ctx).Raw(searchSQL, map[string]interface{}{
+		"options": "StartSel=" + HighlightStart + ", StopSel=" + HighlightStop + ", MaxFragments=2, MaxWords=20, MinWords=5",
+		"query":   query,
+		"user":    userID,
+		"limit":   limit,
+		"offset":  offset,
+		"now":     time.Now(),
+	}).Scan(&results).Error
+	return results, err
+}
+
+func (s gormMessages) Purge(ctx context.Context, policy workers.RetentionPolicy) (workers.PurgeReport, error) {
+	purger := &workers.Purger{DB: s.db, Policy: policy}
+	return purger.Purge(ctx)
+}
+
+type gormBlocks struct{ db *gorm.DB }
+
+func (s gormBlocks) Find(ctx context.Context, userID, blockedID uuid.UUID) (models.Block, error) {
+	var block models.Block
+	err := first(s.db.WithContext(ctx).Where("user_id = ? AND blocked_id = ?", userID, blockedID), &block)
+	return block, err
+}
+
+func (s gormBlocks) Blocked(ctx context.Context, a, b uuid.UUID) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.Block{}).
+		Where("(user_id = ? AND blocked_id = ?) OR (user_id = ? AND blocked_id = ?)", a, b, b, a).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (s gormBlocks) Create(ctx context.Context, block *models.Block) error {
+	return created(s.db.WithContext(ctx).Create(block).Error)
+}
+
+func (s gormBlocks) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.Block, error) {
+	var blocks []models.Block
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).Find(&blocks).Error
+	return blocks, err
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"way-d-interactions/erasure"
+	"way-d-interactions/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type gormRematches struct{ db *gorm.DB }
+
+func (s gormRematches) Find(ctx context.Context, matchID, requesterID uuid.UUID) (models.RematchRequest, error) {
+	var request models.RematchRequest
+	err := first(s.db.WithContext(ctx).Where("match_id = ? AND requester_id = ?", matchID, requesterID), &request)
+	return request, err
+}
+
+func (s gormRematches) Create(ctx context.Context, request *models.RematchRequest) error {
+	return created(s.db.WithContext(ctx).Create(request).Error)
+}
+
+func (s gormRematches) ListPending(ctx context.Context, targetID uuid.UUID) ([]models.RematchRequest, error) {
+	var requests []models.RematchRequest
+	err := s.db.WithContext(ctx).Where("target_id = ? AND status = ?", targetID, models.RematchStatusPending).Order("created_at desc").Find(&requests).Error
+	return requests, err
+}
+
+func (s gormRematches) FindPending(ctx context.Context, id, targetID uuid.UUID) (models.RematchRequest, error) {
+	var request models.RematchRequest
+	err := first(s.db.WithContext(ctx).Where("id = ? AND target_id = ? AND status = ?", id, targetID, models.RematchStatusPending), &request)
+	return request, err
+}
+
+func (s gormRematches) Accept(ctx context.Context, request models.RematchRequest, newMatchID uuid.UUID, at time.Time) error {
+	db := s.db.WithContext(ctx)
+	accepted := map[string]interface{}{"status": models.RematchStatusAccepted, "new_match_id": newMatchID, "responded_at": at}
+	res := db.Model(&models.RematchRequest{}).
+		Where("id = ? AND status = ?", request.ID, models.RematchStatusPending).
+		Updates(accepted)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return db.Model(&models.RematchRequest{}).
+		Where("match_id = ? AND requester_id = ? AND status = ?", request.MatchID, request.TargetID, models.RematchStatusPending).
+		Updates(accepted).Error
+}
+
+func (s gormRematches) Decline(ctx context.Context, request *models.RematchRequest, at time.Time) error {
+	request.Status = models.RematchStatusDeclined
+	request.RespondedAt = &at
+	return s.db.WithContext(ctx).Save(request).Error
+}
+
+type gormSettings struct{ db *gorm.DB }
+
+func (s gormSettings) Find(ctx context.Context, userID uuid.UUID) (models.UserSettings, error) {
+	settings := models.UserSettings{UserID: userID}
+	err := first(s.db.WithContext(ctx).Where("user_id = ?", userID), &settings)
+	if errors.Is(err, ErrNotFound) {
+		return models.UserSettings{UserID: userID}, nil
+	}
+	return settings, err
+}
+
+func (s gormSettings) Save(ctx context.Context, settings *models.UserSettings) error {
+	return s.db.WithContext(ctx).Save(settings).Error
+}
+
+type gormErasure struct{ db *gorm.DB }
+
+func (s gormErasure) Erase(ctx context.Context, userID uuid.UUID) (erasure.Result, error) {
+	return erasure.EraseUser(ctx, s.db, userID)
+}
+
+type gormExports struct{ db *gorm.DB }
+
+// exportQuery returns the query selecting the rows of set that belong to
+// userID, and a constructor for the model they scan into.
+func exportQuery(db *gorm.DB, set ExportSet, userID uuid.UUID) (*gorm.DB, func() interface{}, error) {
+	switch set {
+	case ExportLikesSent:
+		return db.Model(&models.Like{}).Where("user_id = ?", userID).Order("created_at asc"), func() interface{} { return &models.Like{} }, nil
+	case ExportLikesReceived:
+		return db.Model(&models.Like{}).Where("target_id = ?", userID).Order("created_at asc"), func() interface{} { return &models.Like{} }, nil
+	case ExportDislikesSent:
+		return db.Model(&models.Dislike{}).Where("user_id = ?", userID).Order("created_at asc"), func() interface{} { return &models.Dislike{} }, nil
+	case ExportDislikesReceived:
+		return db.Model(&models.Dislike{}).Where("target_id = ?", userID).Order("created_at asc"), func() interface{} { return &models.Dislike{} }, nil
+	case ExportMatches:
+		return db.Model(&models.Match{}).Where("user1_id = ? OR user2_id = ?", userID, userID).Order("created_at asc"), func() interface{} { return &models.Match{} }, nil
+	case ExportMatchExtensions:
+		return db.Model(&models.MatchExtension{}).Where("user_id = ?", userID).Order("created_at asc"), func() interface{} { return &models.MatchExtension{} }, nil
+	case ExportRematchRequests:
+		return db.Model(&models.RematchRequest{}).Where("requester_id = ? OR target_id = ?", userID, userID).Order("created_at asc"), func() interface{} { return &models.RematchRequest{} }, nil
+	case ExportMessages:
+		return db.Model(&models.Message{}).Where("(sender_id = ? OR receiver_id = ?) AND (held = false OR sender_id = ?)", userID, userID, userID).Order("match_id, created_at asc"), func() interface{} { return &models.Message{} }, nil
+	case ExportScheduledMessages:
+		return db.Model(&models.ScheduledMessage{}).Where("sender_id = ?", userID).Order("send_at asc"), func() interface{} { return &models.ScheduledMessage{} }, nil
+	case ExportIcebreakersSent:
+		return db.Model(&models.IcebreakerUsage{}).Where("sender_id = ?", userID).Order("sent_at asc"), func() interface{} { return &models.IcebreakerUsage{} }, nil
+	case ExportReactionsSent:
+		return db.Model(&models.Reaction{}).Where("user_id = ?", userID).Order("created_at asc"), func() interface{} { return &models.Reaction{} }, nil
+	case ExportAttachmentsSent:
+		return db.Model(&models.Attachment{}).Where("uploader_id = ?", userID).Order("created_at asc"), func() interface{} { return &models.Attachment{} }, nil
+	case ExportReportsMade:
+		return db.Model(&models.Report{}).Where("reporter_id = ?", userID).Order("created_at asc"), func() interface{} { return &models.Report{} }, nil
+	case ExportSettings:
+		return db.Model(&models.UserSettings{}).Where("user_id = ?", userID), func() interface{} { return &models.UserSettings{} }, nil
+	case ExportBlocksMade:
+		return db.Model(&models.Block{}).Where("user_id = ?", userID).Order("created_at asc"), func() interface{} { return &models.Block{} }, nil
+	case ExportBlocksReceived:
+		return db.Model(&models.Block{}).Where("blocked_id = ?", userID).Order("created_at asc"), func() interface{} { return &models.Block{} }, nil
+	}
+	return nil, nil, fmt.Errorf("store: unknown export set %q", set)
+}
+
+// Each streams the rows instead of loading them, so large histories export
+// in constant memory.
+func (s gormExports) Each(ctx context.Context, set ExportSet, userID uuid.UUID, emit func(row interface{}) error) error {
+	db := s.db.WithContext(ctx)
+	query, newRow, err := exportQuery(db, set, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		row := newRow()
+		if err := db.ScanRows(rows, row); err != nil {
+			return err
+		}
+		if err := emit(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,242 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"way-d-interactions/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type gormAttachments struct{ db *gorm.DB }
+
+func (s gormAttachments) Create(ctx context.Context, attachment *models.Attachment) error {
+	return s.db.WithContext(ctx).Create(attachment).Error
+}
+
+func (s gormAttachments) Find(ctx context.Context, id uuid.UUID) (models.Attachment, error) {
+	var attachment models.Attachment
+	err := first(s.db.WithContext(ctx).Where("id = ?", id), &attachment)
+	return attachment, err
+}
+
+func (s gormAttachments) FindUnsent(ctx context.Context, id, uploaderID, matchID uuid.UUID) (models.Attachment, error) {
+	var attachment models.Attachment
+	err := first(s.db.WithContext(ctx).Where("id = ? AND uploader_id = ? AND match_id = ? AND message_id IS NULL", id, uploaderID, matchID), &attachment)
+	return attachment, err
+}
+
+func (s gormAttachments) Claim(ctx context.Context, id, messageID uuid.UUID) error {
+	res := s.db.WithContext(ctx).Model(&models.Attachment{}).Where("id = ? AND message_id IS NULL", id).Update("message_id", messageID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (s gormAttachments) ListForMessages(ctx context.Context, messageIDs []uuid.UUID) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	if len(messageIDs) == 0 {
+		return attachments, nil
+	}
+	err := s.db.WithContext(ctx).Where("message_id IN ?", messageIDs).Order("created_at asc").Find(&attachments).Error
+	return attachments, err
+}
+
+type gormReactions struct{ db *gorm.DB }
+
+func (s gormReactions) Find(ctx context.Context, messageID, userID uuid.UUID) (models.Reaction, error) {
+	var reaction models.Reaction
+	err := first(s.db.WithContext(ctx).Where("message_id = ? AND user_id = ?", messageID, userID), &reaction)
+	return reaction, err
+}
+
+func (s gormReactions) Create(ctx context.Context, reaction *models.Reaction) error {
+	return created(s.db.WithContext(ctx).Create(reaction).Error)
+}
+
+func (s gormReactions) SetEmoji(ctx context.Context, reaction *models.Reaction, emoji string) error {
+	reaction.Emoji = emoji
+	return s.db.WithContext(ctx).Model(reaction).Update("emoji", emoji).Error
+}
+
+func (s gormReactions) Delete(ctx context.Context, messageID, userID uuid.UUID) error {
+	res := s.db.WithContext(ctx).Where("message_id = ? AND user_id = ?", messageID, userID).Delete(&models.Reaction{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s gormReactions) Counts(ctx context.Context, messageIDs []uuid.UUID, viewerID uuid.UUID) ([]models.ReactionCount, error) {
+	var counts []models.ReactionCount
+	if len(messageIDs) == 0 {
+		return counts, nil
+	}
+	err := s.db.WithContext(ctx).Model(&models.Reaction{}).
+		Select("message_id, emoji, COUNT(*) AS count, SUM(CASE WHEN user_id = ? THEN 1 ELSE 0 END) > 0 AS reacted_by_me", viewerID).
+		Where("message_id IN ?", messageIDs).
+		Group("message_id, emoji").
+		Order("emoji").
+		Scan(&counts).Error
+	return counts, err
+}
+
+type gormIcebreakers struct{ db *gorm.DB }
+
+func (s gormIcebreakers) List(ctx context.Context, locale string) ([]models.Icebreaker, error) {
+	q := s.db.WithContext(ctx).Order("locale asc, created_at asc")
+	if locale != "" {
+		q = q.Where("locale = ?", locale)
+	}
+	var icebreakers []models.Icebreaker
+	err := q.Find(&icebreakers).Error
+	return icebreakers, err
+}
+
+func (s gormIcebreakers) Locales(ctx context.Context) ([]string, error) {
+	var locales []string
+	err := s.db.WithContext(ctx).Model(&models.Icebreaker{}).Where("active = true").Distinct().Pluck("locale", &locales).Error
+	return locales, err
+}
+
+func (s gormIcebreakers) ListUnused(ctx context.Context, locale string, matchID uuid.UUID) ([]models.Icebreaker, error) {
+	db := s.db.WithContext(ctx)
+	var icebreakers []models.Icebreaker
+	err := db.Where("locale = ? AND active = true", locale).
+		Where("id NOT IN (?)", db.Model(&models.IcebreakerUsage{}).Select("icebreaker_id").Where("match_id = ?", matchID)).
+		Order("created_at asc, id asc").Find(&icebreakers).Error
+	return icebreakers, err
+}
+
+func (s gormIcebreakers) Find(ctx context.Context, id uuid.UUID) (models.Icebreaker, error) {
+	var icebreaker models.Icebreaker
+	err := first(s.db.WithContext(ctx).Where("id = ?", id), &icebreaker)
+	return icebreaker, err
+}
+
+func (s gormIcebreakers) FindActive(ctx context.Context, id uuid.UUID) (models.Icebreaker, error) {
+	var icebreaker models.Icebreaker
+	err := first(s.db.WithContext(ctx).Where("id = ? AND active = true", id), &icebreaker)
+	return icebreaker, err
+}
+
+func (s gormIcebreakers) Create(ctx context.Context, icebreaker *models.Icebreaker) error {
+	return s.db.WithContext(ctx).Create(icebreaker).Error
+}
+
+func (s gormIcebreakers) Update(ctx context.Context, icebreaker *models.Icebreaker) error {
+	return s.db.WithContext(ctx).Save(icebreaker).Error
+}
+
+func (s gormIcebreakers) Retire(ctx context.Context, id uuid.UUID, at time.Time) error {
+	res := s.db.WithContext(ctx).Model(&models.Icebreaker{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"active": false, "updated_at": at})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s gormIcebreakers) Stats(ctx context.Context, locale string) ([]models.IcebreakerStats, error) {
+	q := s.db.WithContext(ctx).Model(&models.Icebreaker{}).
+		Select("icebreakers.*, COUNT(icebreaker_usages.id) AS sent, COUNT(icebreaker_usages.replied_at) AS replied").
+		Joins("LEFT JOIN icebreaker_usages ON icebreaker_usages.icebreaker_id = icebreakers.id").
+		Group("icebreakers.id").
+		Order("sent desc, icebreakers.locale asc")
+	if locale != "" {
+		q = q.Where("icebreakers.locale = ?", locale)
+	}
+	var stats []models.IcebreakerStats
+	err := q.Scan(&stats).Error
+	return stats, err
+}
+
+func (s gormIcebreakers) RecordUsage(ctx context.Context, usage *models.IcebreakerUsage) error {
+	return s.db.WithContext(ctx).Create(usage).Error
+}
+
+func (s gormIcebreakers) MarkReplied(ctx context.Context, matchID, senderID uuid.UUID, at time.Time) error {
+	return s.db.WithContext(ctx).Model(&models.IcebreakerUsage{}).
+		Where("match_id = ? AND sender_id = ? AND replied_at IS NULL", matchID, senderID).
+		Update("replied_at", at).Error
+}
+
+type gormModeration struct{ db *gorm.DB }
+
+func (s gormModeration) Record(ctx context.Context, decision *models.ModerationDecision) error {
+	return s.db.WithContext(ctx).Create(decision).Error
+}
+
+func (s gormModeration) ListForMessages(ctx context.Context, messageIDs []uuid.UUID) ([]models.ModerationDecision, error) {
+	var decisions []models.ModerationDecision
+	if len(messageIDs) == 0 {
+		return decisions, nil
+	}
+	err := s.db.WithContext(ctx).Where("message_id IN ?", messageIDs).Order("created_at asc").Find(&decisions).Error
+	return decisions, err
+}
+
+type gormScheduled struct{ db *gorm.DB }
+
+func (s gormScheduled) Create(ctx context.Context, scheduled *models.ScheduledMessage) error {
+	return s.db.WithContext(ctx).Create(scheduled).Error
+}
+
+func (s gormScheduled) CountPending(ctx context.Context, senderID uuid.UUID) (int64, error) {
+	var pending int64
+	err := s.db.WithContext(ctx).Model(&models.ScheduledMessage{}).
+		Where("sender_id = ? AND status = ?", senderID, models.ScheduledStatusPending).Count(&pending).Error
+	return pending, err
+}
+
+func (s gormScheduled) List(ctx context.Context, senderID uuid.UUID, status string) ([]models.ScheduledMessage, error) {
+	var scheduled []models.ScheduledMessage
+	err := s.db.WithContext(ctx).Where("sender_id = ? AND status = ?", senderID, status).Order("send_at asc").Find(&scheduled).Error
+	return scheduled, err
+}
+
+func (s gormScheduled) Delete(ctx context.Context, id, senderID uuid.UUID) error {
+	res := s.db.WithContext(ctx).Where("id = ? AND sender_id = ?", id, senderID).Delete(&models.ScheduledMessage{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type gormReports struct{ db *gorm.DB }
+
+func (s gormReports) FindOpen(ctx context.Context, messageID, reporterID uuid.UUID) (models.Report, error) {
+	var report models.Report
+	err := first(s.db.WithContext(ctx).Where("message_id = ? AND reporter_id = ? AND status = ?", messageID, reporterID, models.ReportStatusOpen), &report)
+	return report, err
+}
+
+func (s gormReports) Create(ctx context.Context, report *models.Report) error {
+	return s.db.WithContext(ctx).Create(report).Error
+}
+
+func (s gormReports) Close(ctx context.Context, id uuid.UUID, at time.Time) (models.Report, error) {
+	db := s.db.WithContext(ctx)
+	var report models.Report
+	if err := first(db.Where("id = ? AND status = ?", id, models.ReportStatusOpen), &report); err != nil {
+		return report, err
+	}
+	report.Status = models.ReportStatusClosed
+	report.ClosedAt = &at
+	return report, db.Save(&report).Error
+}
@@ -0,0 +1,752 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"way-d-interactions/models"
+	"way-d-interactions/workers"
+
+	"github.com/google/uuid"
+)
+
+// memory holds every entity of the in-memory stores under one lock, so that
+// cross-entity operations see a consistent state.
+type memory struct {
+	// tx serialises units of work.
+	tx sync.Mutex
+	mu sync.Mutex
+	memoryState
+}
+
+// memoryState is the data of the in-memory stores.
+type memoryState struct {
+	likes            []models.Like
+	dislikes         []models.Dislike
+	matches          []models.Match
+	messages         []models.Message
+	blocks           []models.Block
+	attachments      []models.Attachment
+	reactions        []models.Reaction
+	icebreakers      []models.Icebreaker
+	icebreakerUsages []models.IcebreakerUsage
+	decisions        []models.ModerationDecision
+	scheduled        []models.ScheduledMessage
+	reports          []models.Report
+	extensions       []models.MatchExtension
+	rematches        []models.RematchRequest
+	settings         []models.UserSettings
+}
+
+// NewMemory returns empty stores that keep their data in memory. They are
+// safe for concurrent use and meant for tests.
+func NewMemory() Stores {
+	m := &memory{}
+	return Stores{
+		Likes:       memLikes{m},
+		Matches:     memMatches{m},
+		Messages:    memMessages{m},
+		Blocks:      memBlocks{m},
+		Attachments: memAttachments{m},
+		Reactions:   memReactions{m},
+		Icebreakers: memIcebreakers{m},
+		Moderation:  memModeration{m},
+		Scheduled:   memScheduled{m},
+		Reports:     memReports{m},
+		Rematches:   memRematches{m},
+		Settings:    memSettings{m},
+		Erasure:     memErasure{m},
+		Exports:     memExports{m},
+		atomic:      m.atomic,
+	}
+}
+
+// atomic runs fn on stores, restoring the data it started from if fn fails.
+// Units of work run one at a time, but writes made meanwhile outside of one
+// are rolled back with it; fine for tests.
+func (m *memory) atomic(_ context.Context, stores Stores, fn func(Stores) error) error {
+	m.tx.Lock()
+	defer m.tx.Unlock()
+	m.mu.Lock()
+	saved := m.snapshot()
+	m.mu.Unlock()
+	err := fn(stores)
+	if err != nil {
+		m.mu.Lock()
+		m.restore(saved)
+		m.mu.Unlock()
+	}
+	return err
+}
+
+// snapshot copies the data, which the caller must have locked.
+func (m *memory) snapshot() memoryState {
+	return memoryState{
+		likes:            append([]models.Like(nil), m.likes...),
+		dislikes:         append([]models.Dislike(nil), m.dislikes...),
+		matches:          append([]models.Match(nil), m.matches...),
+		messages:         append([]models.Message(nil), m.messages...),
+		blocks:           append([]models.Block(nil), m.blocks...),
+		attachments:      append([]models.Attachment(nil), m.attachments...),
+		reactions:        append([]models.Reaction(nil), m.reactions...),
+		icebreakers:      append([]models.Icebreaker(nil), m.icebreakers...),
+		icebreakerUsages: append([]models.IcebreakerUsage(nil), m.icebreakerUsages...),
+		decisions:        append([]models.ModerationDecision(nil), m.decisions...),
+		scheduled:        append([]models.ScheduledMessage(nil), m.scheduled...),
+		reports:          append([]models.Report(nil), m.reports...),
+		extensions:       append([]models.MatchExtension(nil), m.extensions...),
+		rematches:        append([]models.RematchRequest(nil), m.rematches...),
+		settings:         append([]models.UserSettings(nil), m.settings...),
+	}
+}
+
+// restore puts back saved data, which the caller must have locked.
+func (m *memory) restore(saved memoryState) {
+	m.memoryState = saved
+}
+
+// between reports whether x and y are a and b in either order.
+func between(x, y, a, b uuid.UUID) bool {
+	return (x == a && y == b) || (x == b && y == a)
+}
+
+// live reports whether match has not expired at now.
+func live(match models.Match, now time.Time) bool {
+	return match.ExpireAt == nil || match.ExpireAt.After(now)
+}
+
+// liveMatch reports whether matchID exists and has not expired at now. The
+// caller must hold the lock.
+func (m *memory) liveMatch(matchID uuid.UUID, now time.Time) bool {
+	for _, match := range m.matches {
+		if match.ID == matchID {
+			return live(match, now)
+		}
+	}
+	return false
+}
+
+// blocked reports whether either of a and b blocked the other. The caller
+// must hold the lock.
+func (m *memory) blocked(a, b uuid.UUID) bool {
+	for _, block := range m.blocks {
+		if between(block.UserID, block.BlockedID, a, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// without returns items minus those drop matches, reusing its storage.
+func without[T any](items []T, drop func(T) bool) []T {
+	kept := items[:0]
+	for _, item := range items {
+		if !drop(item) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+type memLikes struct{ m *memory }
+
+func (s memLikes) FindLike(_ context.Context, userID, targetID uuid.UUID) (models.Like, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, l := range s.m.likes {
+		if l.UserID == userID && l.TargetID == targetID {
+			return l, nil
+		}
+	}
+	return models.Like{}, ErrNotFound
+}
+
+func (s memLikes) FindDislike(_ context.Context, userID, targetID uuid.UUID) (models.Dislike, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, d := range s.m.dislikes {
+		if d.UserID == userID && d.TargetID == targetID {
+			return d, nil
+		}
+	}
+	return models.Dislike{}, ErrNotFound
+}
+
+func (s memLikes) CreateLike(_ context.Context, like *models.Like) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, l := range s.m.likes {
+		if l.UserID == like.UserID && l.TargetID == like.TargetID {
+			return ErrConflict
+		}
+	}
+	s.m.likes = append(s.m.likes, *like)
+	return nil
+}
+
+func (s memLikes) CreateDislike(_ context.Context, dislike *models.Dislike) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, d := range s.m.dislikes {
+		if d.UserID == dislike.UserID && d.TargetID == dislike.TargetID {
+			return ErrConflict
+		}
+	}
+	s.m.dislikes = append(s.m.dislikes, *dislike)
+	return nil
+}
+
+func (s memLikes) MarkMatched(_ context.Context, likeID uuid.UUID) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for i := range s.m.likes {
+		if s.m.likes[i].ID == likeID {
+			s.m.likes[i].Match = true
+		}
+	}
+	return nil
+}
+
+func (s memLikes) DeletePair(_ context.Context, a, b uuid.UUID) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.likes = without(s.m.likes, func(l models.Like) bool { return between(l.UserID, l.TargetID, a, b) })
+	s.m.dislikes = without(s.m.dislikes, func(d models.Dislike) bool { return between(d.UserID, d.TargetID, a, b) })
+	return nil
+}
+
+func (s memLikes) ListReceived(_ context.Context, targetID uuid.UUID, limit int) ([]models.Like, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	disliked := make(map[uuid.UUID]bool)
+	for _, d := range s.m.dislikes {
+		if d.UserID == targetID {
+			disliked[d.TargetID] = true
+		}
+	}
+	var likes []models.Like
+	for _, l := range s.m.likes {
+		if l.TargetID == targetID && !l.Match && !disliked[l.UserID] && !s.m.blocked(l.UserID, targetID) {
+			likes = append(likes, l)
+		}
+	}
+	sort.SliceStable(likes, func(i, j int) bool { return likes[i].CreatedAt.After(likes[j].CreatedAt) })
+	if len(likes) > limit {
+		likes = likes[:limit]
+	}
+	return likes, nil
+}
+
+func (s memLikes) Excluded(_ context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	seen := make(map[uuid.UUID]bool)
+	var excluded []uuid.UUID
+	add := func(id uuid.UUID) {
+		if !seen[id] {
+			seen[id] = true
+			excluded = append(excluded, id)
+		}
+	}
+	for _, l := range s.m.likes {
+		if l.UserID == userID {
+			add(l.TargetID)
+		}
+	}
+	for _, d := range s.m.dislikes {
+		if d.UserID == userID {
+			add(d.TargetID)
+		}
+	}
+	for _, match := range s.m.matches {
+		if match.User1ID == userID {
+			add(match.User2ID)
+		} else if match.User2ID == userID {
+			add(match.User1ID)
+		}
+	}
+	for _, b := range s.m.blocks {
+		if b.UserID == userID {
+			add(b.BlockedID)
+		} else if b.BlockedID == userID {
+			add(b.UserID)
+		}
+	}
+	return excluded, nil
+}
+
+type memMatches struct{ m *memory }
+
+func (s memMatches) Create(_ context.Context, match *models.Match) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.matches = append(s.m.matches, *match)
+	return nil
+}
+
+func (s memMatches) ListForUser(_ context.Context, userID uuid.UUID) ([]models.Match, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	var matches []models.Match
+	for _, match := range s.m.matches {
+		if match.User1ID == userID || match.User2ID == userID {
+			matches = append(matches, match)
+		}
+	}
+	return matches, nil
+}
+
+func (s memMatches) ListBetween(_ context.Context, a, b uuid.UUID) ([]models.Match, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	var matches []models.Match
+	for _, match := range s.m.matches {
+		if between(match.User1ID, match.User2ID, a, b) {
+			matches = append(matches, match)
+		}
+	}
+	return matches, nil
+}
+
+func (s memMatches) FindForParticipant(_ context.Context, matchID, userID uuid.UUID) (models.Match, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, match := range s.m.matches {
+		if match.ID == matchID && (match.User1ID == userID || match.User2ID == userID) {
+			return match, nil
+		}
+	}
+	return models.Match{}, ErrNotFound
+}
+
+func (s memMatches) MarkFirstMessage(_ context.Context, matchID uuid.UUID, at time.Time) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for i := range s.m.matches {
+		if s.m.matches[i].ID == matchID && s.m.matches[i].FirstMessageAt == nil {
+			s.m.matches[i].FirstMessageAt = &at
+		}
+	}
+	return nil
+}
+
+func (s memMatches) DeletePair(_ context.Context, a, b uuid.UUID) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	pair := make(map[uuid.UUID]bool)
+	s.m.matches = without(s.m.matches, func(match models.Match) bool {
+		pair[match.ID] = between(match.User1ID, match.User2ID, a, b)
+		return pair[match.ID]
+	})
+	s.m.extensions = without(s.m.extensions, func(e models.MatchExtension) bool { return pair[e.MatchID] })
+	s.m.rematches = without(s.m.rematches, func(r models.RematchRequest) bool { return pair[r.MatchID] })
+	return nil
+}
+
+func (s memMatches) Find(_ context.Context, id uuid.UUID) (models.Match, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, match := range s.m.matches {
+		if match.ID == id {
+			return match, nil
+		}
+	}
+	return models.Match{}, ErrNotFound
+}
+
+// Lock needs no lock of its own: units of work already run one at a time.
+func (s memMatches) Lock(ctx context.Context, id uuid.UUID) (models.Match, error) {
+	return s.Find(ctx, id)
+}
+
+func (s memMatches) HasLive(_ context.Context, a, b uuid.UUID) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	now := time.Now()
+	for _, match := range s.m.matches {
+		if between(match.User1ID, match.User2ID, a, b) && live(match, now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s memMatches) ListOpen(_ context.Context, userID uuid.UUID) ([]models.Match, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	now := time.Now()
+	var matches []models.Match
+	for _, match := range s.m.matches {
+		if (match.User1ID == userID || match.User2ID == userID) && live(match, now) && !s.m.blocked(match.User1ID, match.User2ID) {
+			matches = append(matches, match)
+		}
+	}
+	return matches, nil
+}
+
+func (s memMatches) CountExtensions(_ context.Context, matchID, userID uuid.UUID) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	var count int64
+	for _, e := range s.m.extensions {
+		if e.MatchID == matchID && e.UserID == userID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s memMatches) Extend(_ context.Context, extension *models.MatchExtension) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.extensions = append(s.m.extensions, *extension)
+	for i := range s.m.matches {
+		if s.m.matches[i].ID == extension.MatchID {
+			expireAt := extension.ExpireAt
+			s.m.matches[i].ExpireAt = &expireAt
+		}
+	}
+	return nil
+}
+
+func (s memMatches) Stats(_ context.Context, since, until time.Time) ([]models.MatchSourceStats, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	type sums struct {
+		toMatch, toFirstMessage   float64
+		withLikes, withFirstReply int64
+	}
+	index := make(map[string]int)
+	var stats []models.MatchSourceStats
+	var totals []sums
+	for _, match := range s.m.matches {
+		if match.CreatedAt.Before(since) || !match.CreatedAt.Before(until) {
+			continue
+		}
+		i, ok := index[match.Source]
+		if !ok {
+			i = len(stats)
+			index[match.Source] = i
+			stats = append(stats, models.MatchSourceStats{Source: match.Source})
+			totals = append(totals, sums{})
+		}
+		stats[i].Matches++
+		if match.InitiatorLikedAt != nil && match.ResponderLikedAt != nil {
+			totals[i].toMatch += match.ResponderLikedAt.Sub(*match.InitiatorLikedAt).Seconds()
+			totals[i].withLikes++
+		}
+		if match.FirstMessageAt != nil {
+			stats[i].WithFirstMessage++
+			totals[i].toFirstMessage += match.FirstMessageAt.Sub(match.CreatedAt).Seconds()
+		}
+	}
+	for i := range stats {
+		if totals[i].withLikes > 0 {
+			avg := totals[i].toMatch / float64(totals[i].withLikes)
+			stats[i].AvgTimeToMatchSeconds = &avg
+		}
+		if stats[i].WithFirstMessage > 0 {
+			avg := totals[i].toFirstMessage / float64(stats[i].WithFirstMessage)
+			stats[i].AvgTimeToFirstMessageSeconds = &avg
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Source < stats[j].Source })
+	return stats, nil
+}
+
+type memMessages struct{ m *memory }
+
+func (s memMessages) Create(_ context.Context, msg *models.Message) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.messages = append(s.m.messages, *msg)
+	return nil
+}
+
+func (s memMessages) ListConversation(_ context.Context, match models.Match, viewerID uuid.UUID) ([]models.Message, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	var messages []models.Message
+	for _, msg := range s.m.messages {
+		if msg.MatchID == match.ID && !msg.Deleted && (!msg.Held || msg.SenderID == viewerID) {
+			messages = append(messages, msg)
+		}
+	}
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].CreatedAt.Before(messages[j].CreatedAt) })
+	return messages, nil
+}
+
+func (s memMessages) FindSent(_ context.Context, id, senderID uuid.UUID) (models.Message, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, msg := range s.m.messages {
+		if msg.ID == id && msg.SenderID == senderID && !msg.Deleted {
+			return msg, nil
+		}
+	}
+	return models.Message{}, ErrNotFound
+}
+
+func (s memMessages) SoftDelete(_ context.Context, msg *models.Message, at time.Time) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	msg.Deleted = true
+	msg.DeletedAt = &at
+	for i := range s.m.messages {
+		if s.m.messages[i].ID == msg.ID {
+			s.m.messages[i].Deleted = true
+			s.m.messages[i].DeletedAt = &at
+		}
+	}
+	return nil
+}
+
+func (s memMessages) DeleteForMatches(_ context.Context, matchIDs []uuid.UUID) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	drop := setOf(matchIDs)
+	dropped := make(map[uuid.UUID]bool)
+	s.m.messages = without(s.m.messages, func(msg models.Message) bool {
+		dropped[msg.ID] = drop[msg.MatchID]
+		return drop[msg.MatchID]
+	})
+	s.m.reactions = without(s.m.reactions, func(r models.Reaction) bool { return dropped[r.MessageID] })
+	return nil
+}
+
+func (s memMessages) FindVisible(_ context.Context, id, matchID, viewerID uuid.UUID) (models.Message, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, msg := range s.m.messages {
+		if msg.ID == id && msg.MatchID == matchID && !msg.Deleted && (!msg.Held || msg.SenderID == viewerID) {
+			return msg, nil
+		}
+	}
+	return models.Message{}, ErrNotFound
+}
+
+func (s memMessages) FindForParticipant(_ context.Context, id, userID uuid.UUID) (models.Message, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, msg := range s.m.messages {
+		if msg.ID == id && !msg.Deleted && (msg.SenderID == userID || msg.ReceiverID == userID) {
+			return msg, nil
+		}
+	}
+	return models.Message{}, ErrNotFound
+}
+
+func (s memMessages) FindReceived(_ context.Context, id, receiverID uuid.UUID) (models.Message, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, msg := range s.m.messages {
+		if msg.ID == id && msg.ReceiverID == receiverID {
+			return msg, nil
+		}
+	}
+	return models.Message{}, ErrNotFound
+}
+
+func (s memMessages) ListByIDs(_ context.Context, ids []uuid.UUID) ([]models.Message, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	wanted := setOf(ids)
+	var messages []models.Message
+	for _, msg := range s.m.messages {
+		if wanted[msg.ID] {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nil
+}
+
+func (s memMessages) FindHeld(_ context.Context, id uuid.UUID) (models.Message, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, msg := range s.m.messages {
+		if msg.ID == id && msg.Held && !msg.Deleted {
+			return msg, nil
+		}
+	}
+	return models.Message{}, ErrNotFound
+}
+
+func (s memMessages) ListHeld(_ context.Context, limit int) ([]models.Message, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	var messages []models.Message
+	for _, msg := range s.m.messages {
+		if msg.Held && !msg.Deleted {
+			messages = append(messages, msg)
+		}
+	}
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].CreatedAt.Before(messages[j].CreatedAt) })
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+// Search approximates the full-text search of PostgreSQL: every term of
+// query must occur as a word of the content, case-insensitively, except
+// terms prefixed with "-" which must not. Results are newest first.
+func (s memMessages) Search(_ context.Context, userID uuid.UUID, query string, limit, offset int) ([]models.SearchResult, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	var include, exclude []string
+	for _, term := range strings.Fields(strings.ToLower(strings.ReplaceAll(query, `"`, " "))) {
+		switch {
+		case term == "or":
+		case strings.HasPrefix(term, "-") && len(term) > 1:
+			exclude = append(exclude, term[1:])
+		default:
+			include = append(include, term)
+		}
+	}
+	now := time.Now()
+	var results []models.SearchResult
+	for _, msg := range s.m.messages {
+		if (msg.SenderID != userID && msg.ReceiverID != userID) || msg.Deleted || (msg.Held && msg.SenderID != userID) {
+			continue
+		}
+		if s.m.blocked(msg.SenderID, msg.ReceiverID) || !s.m.liveMatch(msg.MatchID, now) {
+			continue
+		}
+		words := strings.FieldsFunc(msg.Content, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsNumber(r) })
+		has := make(map[string]bool, len(words))
+		for _, w := range words {
+			has[strings.ToLower(w)] = true
+		}
+		found := len(include) > 0
+		for _, term := range include {
+			found = found && has[term]
+		}
+		for _, term := range exclude {
+			found = found && !has[term]
+		}
+		if !found {
+			continue
+		}
+		snippet := msg.Content
+		for _, w := range words {
+			for _, term := range include {
+				if strings.ToLower(w) == term {
+					snippet = strings.ReplaceAll(snippet, w, HighlightStart+w+HighlightStop)
+				}
+			}
+		}
+		results = append(results, models.SearchResult{MessageID: msg.ID, MatchID: msg.MatchID, SenderID: msg.SenderID, CreatedAt: msg.CreatedAt, Snippet: snippet})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].CreatedAt.After(results[j].CreatedAt) })
+	if offset >= len(results) {
+		return nil, nil
+	}
+	results = results[offset:]
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// Purge applies policy in one pass; batches only matter to the database.
+func (s memMessages) Purge(ctx context.Context, policy workers.RetentionPolicy) (workers.PurgeReport, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	started := time.Now()
+	report := workers.PurgeReport{DryRun: policy.DryRun}
+	reported := make(map[uuid.UUID]bool)
+	for _, r := range s.m.reports {
+		if r.Status == models.ReportStatusOpen {
+			reported[r.MessageID] = true
+		}
+	}
+	purged := make(map[uuid.UUID]bool)
+	for _, msg := range s.m.messages {
+		if reported[msg.ID] {
+			continue
+		}
+		if policy.DeletedMessagesAfter > 0 && msg.Deleted {
+			deletedAt := msg.CreatedAt
+			if msg.DeletedAt != nil {
+				deletedAt = *msg.DeletedAt
+			}
+			if deletedAt.Before(started.Add(-policy.DeletedMessagesAfter)) {
+				report.DeletedMessages++
+				purged[msg.ID] = true
+				continue
+			}
+		}
+		if policy.UnmatchedConversationsAfter > 0 {
+			cutoff := started.Add(-policy.UnmatchedConversationsAfter)
+			if msg.CreatedAt.Before(cutoff) && !s.m.liveMatch(msg.MatchID, cutoff) {
+				report.UnmatchedConversations++
+				purged[msg.ID] = true
+			}
+		}
+	}
+	if !policy.DryRun {
+		s.m.reactions = without(s.m.reactions, func(r models.Reaction) bool { return purged[r.MessageID] })
+		s.m.messages = without(s.m.messages, func(msg models.Message) bool { return purged[msg.ID] })
+	}
+	report.Duration = time.Since(started)
+	return report, ctx.Err()
+}
+
+func (s memMessages) Release(_ context.Context, msg *models.Message) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	msg.Held = false
+	for i := range s.m.messages {
+		if s.m.messages[i].ID == msg.ID {
+			s.m.messages[i].Held = false
+		}
+	}
+	return nil
+}
+
+type memBlocks struct{ m *memory }
+
+func (s memBlocks) Find(_ context.Context, userID, blockedID uuid.UUID) (models.Block, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, b := range s.m.blocks {
+		if b.UserID == userID && b.BlockedID == blockedID {
+			return b, nil
+		}
+	}
+	return models.Block{}, ErrNotFound
+}
+
+func (s memBlocks) Blocked(_ context.Context, a, b uuid.UUID) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.blocked(a, b), nil
+}
+
+func (s memBlocks) Create(_ context.Context, block *models.Block) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, b := range s.m.blocks {
+		if b.UserID == block.UserID && b.BlockedID == block.BlockedID {
+			return ErrConflict
+		}
+	}
+	s.m.blocks = append(s.m.blocks, *block)
+	return nil
+}
+
+func (s memBlocks) ListByUser(_ context.Context, userID uuid.UUID) ([]models.Block, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	var blocks []models.Block
+	for _, b := range s.m.blocks {
+		if b.UserID == userID {
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks, nil
+}
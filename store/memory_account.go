@@ -0,0 +1,321 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"way-d-interactions/erasure"
+	"way-d-interactions/models"
+
+	"github.com/google/uuid"
+)
+
+type memRematches struct{ m *memory }
+
+func (s memRematches) Find(_ context.Context, matchID, requesterID uuid.UUID) (models.RematchRequest, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, r := range s.m.rematches {
+		if r.MatchID == matchID && r.RequesterID == requesterID {
+			return r, nil
+		}
+	}
+	return models.RematchRequest{}, ErrNotFound
+}
+
+func (s memRematches) Create(_ context.Context, request *models.RematchRequest) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, r := range s.m.rematches {
+		if r.MatchID == request.MatchID && r.RequesterID == request.RequesterID {
+			return ErrConflict
+		}
+	}
+	if request.CreatedAt.IsZero() {
+		request.CreatedAt = time.Now()
+	}
+	s.m.rematches = append(s.m.rematches, *request)
+	return nil
+}
+
+func (s memRematches) ListPending(_ context.Context, targetID uuid.UUID) ([]models.RematchRequest, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	var requests []models.RematchRequest
+	for _, r := range s.m.rematches {
+		if r.TargetID == targetID && r.Status == models.RematchStatusPending {
+			requests = append(requests, r)
+		}
+	}
+	sort.SliceStable(requests, func(i, j int) bool { return requests[i].CreatedAt.After(requests[j].CreatedAt) })
+	return requests, nil
+}
+
+func (s memRematches) FindPending(_ context.Context, id, targetID uuid.UUID) (models.RematchRequest, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, r := range s.m.rematches {
+		if r.ID == id && r.TargetID == targetID && r.Status == models.RematchStatusPending {
+			return r, nil
+		}
+	}
+	return models.RematchRequest{}, ErrNotFound
+}
+
+func (s memRematches) Accept(_ context.Context, request models.RematchRequest, newMatchID uuid.UUID, at time.Time) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	accept := func(r *models.RematchRequest) {
+		r.Status = models.RematchStatusAccepted
+		r.NewMatchID = &newMatchID
+		r.RespondedAt = &at
+	}
+	found := false
+	for i := range s.m.rematches {
+		r := &s.m.rematches[i]
+		if r.ID == request.ID && r.Status == models.RematchStatusPending {
+			accept(r)
+			found = true
+		}
+	}
+	if !found {
+		return ErrNotFound
+	}
+	for i := range s.m.rematches {
+		r := &s.m.rematches[i]
+		if r.MatchID == request.MatchID && r.RequesterID == request.TargetID && r.Status == models.RematchStatusPending {
+			accept(r)
+		}
+	}
+	return nil
+}
+
+func (s memRematches) Decline(_ context.Context, request *models.RematchRequest, at time.Time) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	request.Status = models.RematchStatusDeclined
+	request.RespondedAt = &at
+	for i := range s.m.rematches {
+		if s.m.rematches[i].ID == request.ID {
+			s.m.rematches[i] = *request
+		}
+	}
+	return nil
+}
+
+type memSettings struct{ m *memory }
+
+func (s memSettings) Find(_ context.Context, userID uuid.UUID) (models.UserSettings, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, settings := range s.m.settings {
+		if settings.UserID == userID {
+			return settings, nil
+		}
+	}
+	return models.UserSettings{UserID: userID}, nil
+}
+
+func (s memSettings) Save(_ context.Context, settings *models.UserSettings) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	settings.UpdatedAt = time.Now()
+	for i := range s.m.settings {
+		if s.m.settings[i].UserID == settings.UserID {
+			s.m.settings[i] = *settings
+			return nil
+		}
+	}
+	s.m.settings = append(s.m.settings, *settings)
+	return nil
+}
+
+type memErasure struct{ m *memory }
+
+// dropCounted removes the items matching drop and returns how many there
+// were.
+func dropCounted[T any](items *[]T, drop func(T) bool) int64 {
+	before := len(*items)
+	*items = without(*items, drop)
+	return int64(before - len(*items))
+}
+
+// Erase applies the erasure policy in one go; there is nothing to resume in
+// memory.
+func (s memErasure) Erase(_ context.Context, userID uuid.UUID) (erasure.Result, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	m := s.m
+	result := erasure.Result{UserID: userID, Status: erasure.StatusCompleted}
+
+	matches := make(map[uuid.UUID]bool)
+	for _, match := range m.matches {
+		if match.User1ID == userID || match.User2ID == userID {
+			matches[match.ID] = true
+		}
+	}
+	messages := make(map[uuid.UUID]bool)
+	for _, msg := range m.messages {
+		if msg.SenderID == userID || msg.ReceiverID == userID || matches[msg.MatchID] {
+			messages[msg.ID] = true
+		}
+	}
+
+	result.Likes = dropCounted(&m.likes, func(l models.Like) bool { return l.UserID == userID || l.TargetID == userID })
+	result.Dislikes = dropCounted(&m.dislikes, func(d models.Dislike) bool { return d.UserID == userID || d.TargetID == userID })
+	result.ScheduledMessages = dropCounted(&m.scheduled, func(sm models.ScheduledMessage) bool { return sm.SenderID == userID || matches[sm.MatchID] })
+	result.IcebreakerUsages = dropCounted(&m.icebreakerUsages, func(u models.IcebreakerUsage) bool { return u.SenderID == userID || matches[u.MatchID] })
+	result.MatchExtensions = dropCounted(&m.extensions, func(e models.MatchExtension) bool { return e.UserID == userID || matches[e.MatchID] })
+	result.RematchRequests = dropCounted(&m.rematches, func(r models.RematchRequest) bool { return r.RequesterID == userID || r.TargetID == userID })
+	result.ModerationDecisions = dropCounted(&m.decisions, func(d models.ModerationDecision) bool { return d.SenderID == userID || d.ReceiverID == userID })
+	result.Reports = dropCounted(&m.reports, func(r models.Report) bool { return r.ReporterID == userID })
+	for i := range m.attachments {
+		if m.attachments[i].UploaderID == userID {
+			m.attachments[i].UploaderID = uuid.Nil
+			result.Attachments++
+		}
+	}
+	result.Reactions = dropCounted(&m.reactions, func(r models.Reaction) bool { return r.UserID == userID || messages[r.MessageID] })
+	result.Messages = dropCounted(&m.messages, func(msg models.Message) bool { return messages[msg.ID] })
+	result.Matches = dropCounted(&m.matches, func(match models.Match) bool { return matches[match.ID] })
+	result.Blocks = dropCounted(&m.blocks, func(b models.Block) bool { return b.UserID == userID })
+	result.Settings = dropCounted(&m.settings, func(settings models.UserSettings) bool { return settings.UserID == userID })
+	return result, nil
+}
+
+type memExports struct{ m *memory }
+
+// exportRows returns copies of the rows of set that belong to userID, in the
+// order the database exports them.
+func (m *memory) exportRows(set ExportSet, userID uuid.UUID) ([]interface{}, error) {
+	var rows []interface{}
+	switch set {
+	case ExportLikesSent, ExportLikesReceived:
+		for _, l := range m.likes {
+			if (set == ExportLikesSent && l.UserID == userID) || (set == ExportLikesReceived && l.TargetID == userID) {
+				l := l
+				rows = append(rows, &l)
+			}
+		}
+	case ExportDislikesSent, ExportDislikesReceived:
+		for _, d := range m.dislikes {
+			if (set == ExportDislikesSent && d.UserID == userID) || (set == ExportDislikesReceived && d.TargetID == userID) {
+				d := d
+				rows = append(rows, &d)
+			}
+		}
+	case ExportMatches:
+		for _, match := range m.matches {
+			if match.User1ID == userID || match.User2ID == userID {
+				match := match
+				rows = append(rows, &match)
+			}
+		}
+	case ExportMatchExtensions:
+		for _, e := range m.extensions {
+			if e.UserID == userID {
+				e := e
+				rows = append(rows, &e)
+			}
+		}
+	case ExportRematchRequests:
+		for _, r := range m.rematches {
+			if r.RequesterID == userID || r.TargetID == userID {
+				r := r
+				rows = append(rows, &r)
+			}
+		}
+	case ExportMessages:
+		var messages []models.Message
+		for _, msg := range m.messages {
+			if (msg.SenderID == userID || msg.ReceiverID == userID) && (!msg.Held || msg.SenderID == userID) {
+				messages = append(messages, msg)
+			}
+		}
+		sort.SliceStable(messages, func(i, j int) bool {
+			if messages[i].MatchID != messages[j].MatchID {
+				return messages[i].MatchID.String() < messages[j].MatchID.String()
+			}
+			return messages[i].CreatedAt.Before(messages[j].CreatedAt)
+		})
+		for i := range messages {
+			rows = append(rows, &messages[i])
+		}
+		return rows, nil
+	case ExportScheduledMessages:
+		for _, sm := range m.scheduled {
+			if sm.SenderID == userID {
+				sm := sm
+				rows = append(rows, &sm)
+			}
+		}
+	case ExportIcebreakersSent:
+		for _, u := range m.icebreakerUsages {
+			if u.SenderID == userID {
+				u := u
+				rows = append(rows, &u)
+			}
+		}
+	case ExportReactionsSent:
+		for _, r := range m.reactions {
+			if r.UserID == userID {
+				r := r
+				rows = append(rows, &r)
+			}
+		}
+	case ExportAttachmentsSent:
+		for _, a := range m.attachments {
+			if a.UploaderID == userID {
+				a := a
+				rows = append(rows, &a)
+			}
+		}
+	case ExportReportsMade:
+		for _, r := range m.reports {
+			if r.ReporterID == userID {
+				r := r
+				rows = append(rows, &r)
+			}
+		}
+	case ExportSettings:
+		for _, settings := range m.settings {
+			if settings.UserID == userID {
+				settings := settings
+				rows = append(rows, &settings)
+			}
+		}
+	case ExportBlocksMade, ExportBlocksReceived:
+		for _, b := range m.blocks {
+			if (set == ExportBlocksMade && b.UserID == userID) || (set == ExportBlocksReceived && b.BlockedID == userID) {
+				b := b
+				rows = append(rows, &b)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("store: unknown export set %q", set)
+	}
+	// Rows are appended in insertion order, which is creation order.
+	return rows, nil
+}
+
+// Each copies the rows under the lock and emits them after releasing it, so
+// emit may use the other stores.
+func (s memExports) Each(ctx context.Context, set ExportSet, userID uuid.UUID, emit func(row interface{}) error) error {
+	s.m.mu.Lock()
+	rows, err := s.m.exportRows(set, userID)
+	s.m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := emit(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,410 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"way-d-interactions/models"
+
+	"github.com/google/uuid"
+)
+
+// setOf returns the set of ids in list.
+func setOf(list []uuid.UUID) map[uuid.UUID]bool {
+	set := make(map[uuid.UUID]bool, len(list))
+	for _, id := range list {
+		set[id] = true
+	}
+	return set
+}
+
+type memAttachments struct{ m *memory }
+
+func (s memAttachments) Create(_ context.Context, attachment *models.Attachment) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.attachments = append(s.m.attachments, *attachment)
+	return nil
+}
+
+func (s memAttachments) Find(_ context.Context, id uuid.UUID) (models.Attachment, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, a := range s.m.attachments {
+		if a.ID == id {
+			return a, nil
+		}
+	}
+	return models.Attachment{}, ErrNotFound
+}
+
+func (s memAttachments) FindUnsent(_ context.Context, id, uploaderID, matchID uuid.UUID) (models.Attachment, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, a := range s.m.attachments {
+		if a.ID == id && a.UploaderID == uploaderID && a.MatchID == matchID && a.MessageID == nil {
+			return a, nil
+		}
+	}
+	return models.Attachment{}, ErrNotFound
+}
+
+func (s memAttachments) Claim(_ context.Context, id, messageID uuid.UUID) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for i := range s.m.attachments {
+		if s.m.attachments[i].ID == id && s.m.attachments[i].MessageID == nil {
+			s.m.attachments[i].MessageID = &messageID
+			return nil
+		}
+	}
+	return ErrConflict
+}
+
+func (s memAttachments) ListForMessages(_ context.Context, messageIDs []uuid.UUID) ([]models.Attachment, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	wanted := setOf(messageIDs)
+	var attachments []models.Attachment
+	for _, a := range s.m.attachments {
+		if a.MessageID != nil && wanted[*a.MessageID] {
+			attachments = append(attachments, a)
+		}
+	}
+	sort.SliceStable(attachments, func(i, j int) bool { return attachments[i].CreatedAt.Before(attachments[j].CreatedAt) })
+	return attachments, nil
+}
+
+type memReactions struct{ m *memory }
+
+func (s memReactions) Find(_ context.Context, messageID, userID uuid.UUID) (models.Reaction, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, r := range s.m.reactions {
+		if r.MessageID == messageID && r.UserID == userID {
+			return r, nil
+		}
+	}
+	return models.Reaction{}, ErrNotFound
+}
+
+func (s memReactions) Create(_ context.Context, reaction *models.Reaction) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, r := range s.m.reactions {
+		if r.MessageID == reaction.MessageID && r.UserID == reaction.UserID {
+			return ErrConflict
+		}
+	}
+	s.m.reactions = append(s.m.reactions, *reaction)
+	return nil
+}
+
+func (s memReactions) SetEmoji(_ context.Context, reaction *models.Reaction, emoji string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	reaction.Emoji = emoji
+	for i := range s.m.reactions {
+		if s.m.reactions[i].ID == reaction.ID {
+			s.m.reactions[i].Emoji = emoji
+		}
+	}
+	return nil
+}
+
+func (s memReactions) Delete(_ context.Context, messageID, userID uuid.UUID) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	n := len(s.m.reactions)
+	s.m.reactions = without(s.m.reactions, func(r models.Reaction) bool { return r.MessageID == messageID && r.UserID == userID })
+	if len(s.m.reactions) == n {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s memReactions) Counts(_ context.Context, messageIDs []uuid.UUID, viewerID uuid.UUID) ([]models.ReactionCount, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	wanted := setOf(messageIDs)
+	type key struct {
+		messageID uuid.UUID
+		emoji     string
+	}
+	index := make(map[key]int)
+	var counts []models.ReactionCount
+	for _, r := range s.m.reactions {
+		if !wanted[r.MessageID] {
+			continue
+		}
+		k := key{r.MessageID, r.Emoji}
+		i, ok := index[k]
+		if !ok {
+			i = len(counts)
+			index[k] = i
+			counts = append(counts, models.ReactionCount{MessageID: r.MessageID, Emoji: r.Emoji})
+		}
+		counts[i].Count++
+		counts[i].ReactedByMe = counts[i].ReactedByMe || r.UserID == viewerID
+	}
+	sort.SliceStable(counts, func(i, j int) bool { return counts[i].Emoji < counts[j].Emoji })
+	return counts, nil
+}
+
+type memIcebreakers struct{ m *memory }
+
+func (s memIcebreakers) List(_ context.Context, locale string) ([]models.Icebreaker, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	var icebreakers []models.Icebreaker
+	for _, ib := range s.m.icebreakers {
+		if locale == "" || ib.Locale == locale {
+			icebreakers = append(icebreakers, ib)
+		}
+	}
+	sort.SliceStable(icebreakers, func(i, j int) bool {
+		if icebreakers[i].Locale != icebreakers[j].Locale {
+			return icebreakers[i].Locale < icebreakers[j].Locale
+		}
+		return icebreakers[i].CreatedAt.Before(icebreakers[j].CreatedAt)
+	})
+	return icebreakers, nil
+}
+
+func (s memIcebreakers) Locales(_ context.Context) ([]string, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	seen := make(map[string]bool)
+	var locales []string
+	for _, ib := range s.m.icebreakers {
+		if ib.Active && !seen[ib.Locale] {
+			seen[ib.Locale] = true
+			locales = append(locales, ib.Locale)
+		}
+	}
+	return locales, nil
+}
+
+func (s memIcebreakers) ListUnused(_ context.Context, locale string, matchID uuid.UUID) ([]models.Icebreaker, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	used := make(map[uuid.UUID]bool)
+	for _, u := range s.m.icebreakerUsages {
+		if u.MatchID == matchID {
+			used[u.IcebreakerID] = true
+		}
+	}
+	var icebreakers []models.Icebreaker
+	for _, ib := range s.m.icebreakers {
+		if ib.Locale == locale && ib.Active && !used[ib.ID] {
+			icebreakers = append(icebreakers, ib)
+		}
+	}
+	sort.SliceStable(icebreakers, func(i, j int) bool {
+		if !icebreakers[i].CreatedAt.Equal(icebreakers[j].CreatedAt) {
+			return icebreakers[i].CreatedAt.Before(icebreakers[j].CreatedAt)
+		}
+		return icebreakers[i].ID.String() < icebreakers[j].ID.String()
+	})
+	return icebreakers, nil
+}
+
+func (s memIcebreakers) Find(_ context.Context, id uuid.UUID) (models.Icebreaker, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, ib := range s.m.icebreakers {
+		if ib.ID == id {
+			return ib, nil
+		}
+	}
+	return models.Icebreaker{}, ErrNotFound
+}
+
+func (s memIcebreakers) FindActive(ctx context.Context, id uuid.UUID) (models.Icebreaker, error) {
+	ib, err := s.Find(ctx, id)
+	if err == nil && !ib.Active {
+		return models.Icebreaker{}, ErrNotFound
+	}
+	return ib, err
+}
+
+func (s memIcebreakers) Create(_ context.Context, icebreaker *models.Icebreaker) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.icebreakers = append(s.m.icebreakers, *icebreaker)
+	return nil
+}
+
+func (s memIcebreakers) Update(_ context.Context, icebreaker *models.Icebreaker) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for i := range s.m.icebreakers {
+		if s.m.icebreakers[i].ID == icebreaker.ID {
+			s.m.icebreakers[i] = *icebreaker
+			return nil
+		}
+	}
+	s.m.icebreakers = append(s.m.icebreakers, *icebreaker)
+	return nil
+}
+
+func (s memIcebreakers) Retire(_ context.Context, id uuid.UUID, at time.Time) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for i := range s.m.icebreakers {
+		if s.m.icebreakers[i].ID == id {
+			s.m.icebreakers[i].Active = false
+			s.m.icebreakers[i].UpdatedAt = at
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s memIcebreakers) Stats(_ context.Context, locale string) ([]models.IcebreakerStats, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	var stats []models.IcebreakerStats
+	for _, ib := range s.m.icebreakers {
+		if locale != "" && ib.Locale != locale {
+			continue
+		}
+		st := models.IcebreakerStats{Icebreaker: ib}
+		for _, u := range s.m.icebreakerUsages {
+			if u.IcebreakerID == ib.ID {
+				st.Sent++
+				if u.RepliedAt != nil {
+					st.Replied++
+				}
+			}
+		}
+		stats = append(stats, st)
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		if stats[i].Sent != stats[j].Sent {
+			return stats[i].Sent > stats[j].Sent
+		}
+		return stats[i].Locale < stats[j].Locale
+	})
+	return stats, nil
+}
+
+func (s memIcebreakers) RecordUsage(_ context.Context, usage *models.IcebreakerUsage) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.icebreakerUsages = append(s.m.icebreakerUsages, *usage)
+	return nil
+}
+
+func (s memIcebreakers) MarkReplied(_ context.Context, matchID, senderID uuid.UUID, at time.Time) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for i, u := range s.m.icebreakerUsages {
+		if u.MatchID == matchID && u.SenderID == senderID && u.RepliedAt == nil {
+			s.m.icebreakerUsages[i].RepliedAt = &at
+		}
+	}
+	return nil
+}
+
+type memModeration struct{ m *memory }
+
+func (s memModeration) Record(_ context.Context, decision *models.ModerationDecision) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.decisions = append(s.m.decisions, *decision)
+	return nil
+}
+
+func (s memModeration) ListForMessages(_ context.Context, messageIDs []uuid.UUID) ([]models.ModerationDecision, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	wanted := setOf(messageIDs)
+	var decisions []models.ModerationDecision
+	for _, d := range s.m.decisions {
+		if d.MessageID != nil && wanted[*d.MessageID] {
+			decisions = append(decisions, d)
+		}
+	}
+	sort.SliceStable(decisions, func(i, j int) bool { return decisions[i].CreatedAt.Before(decisions[j].CreatedAt) })
+	return decisions, nil
+}
+
+type memScheduled struct{ m *memory }
+
+func (s memScheduled) Create(_ context.Context, scheduled *models.ScheduledMessage) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.scheduled = append(s.m.scheduled, *scheduled)
+	return nil
+}
+
+func (s memScheduled) CountPending(_ context.Context, senderID uuid.UUID) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	var pending int64
+	for _, sm := range s.m.scheduled {
+		if sm.SenderID == senderID && sm.Status == models.ScheduledStatusPending {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func (s memScheduled) List(_ context.Context, senderID uuid.UUID, status string) ([]models.ScheduledMessage, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	var scheduled []models.ScheduledMessage
+	for _, sm := range s.m.scheduled {
+		if sm.SenderID == senderID && sm.Status == status {
+			scheduled = append(scheduled, sm)
+		}
+	}
+	sort.SliceStable(scheduled, func(i, j int) bool { return scheduled[i].SendAt.Before(scheduled[j].SendAt) })
+	return scheduled, nil
+}
+
+func (s memScheduled) Delete(_ context.Context, id, senderID uuid.UUID) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	n := len(s.m.scheduled)
+	s.m.scheduled = without(s.m.scheduled, func(sm models.ScheduledMessage) bool { return sm.ID == id && sm.SenderID == senderID })
+	if len(s.m.scheduled) == n {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type memReports struct{ m *memory }
+
+func (s memReports) FindOpen(_ context.Context, messageID, reporterID uuid.UUID) (models.Report, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, r := range s.m.reports {
+		if r.MessageID == messageID && r.ReporterID == reporterID && r.Status == models.ReportStatusOpen {
+			return r, nil
+		}
+	}
+	return models.Report{}, ErrNotFound
+}
+
+func (s memReports) Create(_ context.Context, report *models.Report) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.reports = append(s.m.reports, *report)
+	return nil
+}
+
+func (s memReports) Close(_ context.Context, id uuid.UUID, at time.Time) (models.Report, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for i := range s.m.reports {
+		if s.m.reports[i].ID == id && s.m.reports[i].Status == models.ReportStatusOpen {
+			s.m.reports[i].Status = models.ReportStatusClosed
+			s.m.reports[i].ClosedAt = &at
+			return s.m.reports[i], nil
+		}
+	}
+	return models.Report{}, ErrNotFound
+}
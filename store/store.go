@@ -0,0 +1,325 @@
+// Package store hides the persistence of the service's data behind
+// interfaces, so handlers can run against PostgreSQL through GORM or against
+// an in-memory implementation in tests.
+//
+// Lookups that find nothing return ErrNotFound, and creating a like, dislike
+// or block that already exists returns ErrConflict.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"way-d-interactions/erasure"
+	"way-d-interactions/models"
+	"way-d-interactions/workers"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Search snippets wrap matching terms in these markers. Control characters
+// cannot occur in message content, so they are safe to swap for markup after
+// escaping.
+const (
+	HighlightStart = "\x02"
+	HighlightStop  = "\x03"
+)
+
+var (
+	// ErrNotFound is returned when a lookup matches no row.
+	ErrNotFound = errors.New("store: not found")
+	// ErrConflict is returned when a row would duplicate an existing one.
+	ErrConflict = errors.New("store: conflict")
+)
+
+// IsUniqueViolation reports whether err is ErrConflict or a PostgreSQL
+// unique constraint violation (SQLSTATE 23505).
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.Is(err, ErrConflict) || (errors.As(err, &pgErr) && pgErr.Code == "23505")
+}
+
+// LikeStore persists likes and dislikes.
+type LikeStore interface {
+	// FindLike returns the like userID sent to targetID.
+	FindLike(ctx context.Context, userID, targetID uuid.UUID) (models.Like, error)
+	// FindDislike returns the dislike userID sent to targetID.
+	FindDislike(ctx context.Context, userID, targetID uuid.UUID) (models.Dislike, error)
+	CreateLike(ctx context.Context, like *models.Like) error
+	CreateDislike(ctx context.Context, dislike *models.Dislike) error
+	// MarkMatched flags the like as having led to a match.
+	MarkMatched(ctx context.Context, likeID uuid.UUID) error
+	// DeletePair deletes the likes and dislikes between a and b, both ways.
+	DeletePair(ctx context.Context, a, b uuid.UUID) error
+	// ListReceived returns up to limit likes sent to targetID that did not
+	// lead to a match, newest first. Likes between users who blocked each
+	// other, or from users targetID disliked, are left out.
+	ListReceived(ctx context.Context, targetID uuid.UUID, limit int) ([]models.Like, error)
+	// Excluded returns the users userID liked, disliked, matched, blocked or
+	// was blocked by.
+	Excluded(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+}
+
+// MatchStore persists matches.
+type MatchStore interface {
+	Create(ctx context.Context, match *models.Match) error
+	// ListForUser returns every match userID takes part in.
+	ListForUser(ctx context.Context, userID uuid.UUID) ([]models.Match, error)
+	// ListBetween returns every match between a and b, earlier ones revived
+	// by a rematch included.
+	ListBetween(ctx context.Context, a, b uuid.UUID) ([]models.Match, error)
+	// FindForParticipant returns matchID if userID takes part in it.
+	FindForParticipant(ctx context.Context, matchID, userID uuid.UUID) (models.Match, error)
+	// MarkFirstMessage records at as the time of the first message of
+	// matchID, unless one was already recorded.
+	MarkFirstMessage(ctx context.Context, matchID uuid.UUID, at time.Time) error
+	// DeletePair deletes the matches between a and b along with their
+	// extensions and rematch requests.
+	DeletePair(ctx context.Context, a, b uuid.UUID) error
+	Find(ctx context.Context, id uuid.UUID) (models.Match, error)
+	// Lock returns the match id and, within a unit of work, keeps other
+	// units from changing it until this one ends.
+	Lock(ctx context.Context, id uuid.UUID) (models.Match, error)
+	// HasLive reports whether a and b have a match that has not expired.
+	HasLive(ctx context.Context, a, b uuid.UUID) (bool, error)
+	// ListOpen returns userID's matches that have not expired and whose
+	// participants did not block each other.
+	ListOpen(ctx context.Context, userID uuid.UUID) ([]models.Match, error)
+	// CountExtensions returns how many times userID extended matchID.
+	CountExtensions(ctx context.Context, matchID, userID uuid.UUID) (int64, error)
+	// Extend records extension and moves the expiry of its match to
+	// extension.ExpireAt.
+	Extend(ctx context.Context, extension *models.MatchExtension) error
+	// Stats aggregates the matches created in [since, until) per source.
+	Stats(ctx context.Context, since, until time.Time) ([]models.MatchSourceStats, error)
+}
+
+// MessageStore persists messages.
+type MessageStore interface {
+	Create(ctx context.Context, msg *models.Message) error
+	// ListConversation returns the messages of match as seen by viewerID,
+	// oldest first: deleted messages are left out, and held messages unless
+	// viewerID sent them.
+	ListConversation(ctx context.Context, match models.Match, viewerID uuid.UUID) ([]models.Message, error)
+	// FindSent returns the message id sent by senderID unless it was deleted.
+	FindSent(ctx context.Context, id, senderID uuid.UUID) (models.Message, error)
+	// SoftDelete marks msg as deleted at at.
+	SoftDelete(ctx context.Context, msg *models.Message, at time.Time) error
+	// DeleteForMatches deletes the messages of matchIDs and their reactions.
+	DeleteForMatches(ctx context.Context, matchIDs []uuid.UUID) error
+	// FindVisible returns the message id of matchID as seen by viewerID: it
+	// must not be deleted, nor held unless viewerID sent it.
+	FindVisible(ctx context.Context, id, matchID, viewerID uuid.UUID) (models.Message, error)
+	// FindForParticipant returns the message id sent or received by userID
+	// unless it was deleted.
+	FindForParticipant(ctx context.Context, id, userID uuid.UUID) (models.Message, error)
+	// FindReceived returns the message id received by receiverID, deleted
+	// or not.
+	FindReceived(ctx context.Context, id, receiverID uuid.UUID) (models.Message, error)
+	// ListByIDs returns the messages ids, deleted and held ones included.
+	ListByIDs(ctx context.Context, ids []uuid.UUID) ([]models.Message, error)
+	// FindHeld returns the message id if it is held for review and was not
+	// deleted.
+	FindHeld(ctx context.Context, id uuid.UUID) (models.Message, error)
+	// ListHeld returns up to limit messages held for review, oldest first.
+	ListHeld(ctx context.Context, limit int) ([]models.Message, error)
+	// Release delivers the held message msg.
+	Release(ctx context.Context, msg *models.Message) error
+	// Search returns the messages of userID's live, unblocked matches that
+	// match query, best first, skipping offset results and returning at most
+	// limit. Deleted messages are left out, and held ones unless userID sent
+	// them. Snippets wrap the matching terms in HighlightStart and
+	// HighlightStop.
+	Search(ctx context.Context, userID uuid.UUID, query string, limit, offset int) ([]models.SearchResult, error)
+	// Purge applies policy to the messages, deleting them with their
+	// reactions, or only counting them in a dry run.
+	Purge(ctx context.Context, policy workers.RetentionPolicy) (workers.PurgeReport, error)
+}
+
+// BlockStore persists blocks.
+type BlockStore interface {
+	// Find returns the block userID made against blockedID.
+	Find(ctx context.Context, userID, blockedID uuid.UUID) (models.Block, error)
+	// Blocked reports whether either user blocked the other.
+	Blocked(ctx context.Context, a, b uuid.UUID) (bool, error)
+	Create(ctx context.Context, block *models.Block) error
+	// ListByUser returns the blocks made by userID.
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]models.Block, error)
+}
+
+// AttachmentStore persists attachment metadata; the files themselves live
+// in storage.
+type AttachmentStore interface {
+	Create(ctx context.Context, attachment *models.Attachment) error
+	Find(ctx context.Context, id uuid.UUID) (models.Attachment, error)
+	// FindUnsent returns the attachment id uploaded by uploaderID to matchID
+	// if no message carries it yet.
+	FindUnsent(ctx context.Context, id, uploaderID, matchID uuid.UUID) (models.Attachment, error)
+	// Claim attaches id to messageID. It returns ErrConflict if a message
+	// already carries the attachment.
+	Claim(ctx context.Context, id, messageID uuid.UUID) error
+	// ListForMessages returns the attachments of messageIDs, oldest first.
+	ListForMessages(ctx context.Context, messageIDs []uuid.UUID) ([]models.Attachment, error)
+}
+
+// ReactionStore persists reactions to messages.
+type ReactionStore interface {
+	// Find returns userID's reaction to messageID.
+	Find(ctx context.Context, messageID, userID uuid.UUID) (models.Reaction, error)
+	Create(ctx context.Context, reaction *models.Reaction) error
+	// SetEmoji replaces the emoji of reaction.
+	SetEmoji(ctx context.Context, reaction *models.Reaction, emoji string) error
+	// Delete deletes userID's reaction to messageID, or returns ErrNotFound.
+	Delete(ctx context.Context, messageID, userID uuid.UUID) error
+	// Counts aggregates the reactions to messageIDs per message and emoji,
+	// ordered by emoji, flagging those viewerID made.
+	Counts(ctx context.Context, messageIDs []uuid.UUID, viewerID uuid.UUID) ([]models.ReactionCount, error)
+}
+
+// IcebreakerStore persists the icebreaker catalogue and its usage.
+type IcebreakerStore interface {
+	// List returns the catalogue, inactive entries included, by locale then
+	// age. A non-empty locale only returns that locale.
+	List(ctx context.Context, locale string) ([]models.Icebreaker, error)
+	// Locales returns the locales with at least one active icebreaker.
+	Locales(ctx context.Context) ([]string, error)
+	// ListUnused returns the active icebreakers of locale not yet sent in
+	// matchID, oldest first.
+	ListUnused(ctx context.Context, locale string, matchID uuid.UUID) ([]models.Icebreaker, error)
+	Find(ctx context.Context, id uuid.UUID) (models.Icebreaker, error)
+	// FindActive returns the icebreaker id unless it was retired.
+	FindActive(ctx context.Context, id uuid.UUID) (models.Icebreaker, error)
+	Create(ctx context.Context, icebreaker *models.Icebreaker) error
+	Update(ctx context.Context, icebreaker *models.Icebreaker) error
+	// Retire deactivates id at at, or returns ErrNotFound.
+	Retire(ctx context.Context, id uuid.UUID, at time.Time) error
+	// Stats counts how often each icebreaker was sent and replied to, most
+	// sent first. A non-empty locale only returns that locale.
+	Stats(ctx context.Context, locale string) ([]models.IcebreakerStats, error)
+	RecordUsage(ctx context.Context, usage *models.IcebreakerUsage) error
+	// MarkReplied records at as the reply to the icebreakers senderID sent in
+	// matchID that had no reply yet.
+	MarkReplied(ctx context.Context, matchID, senderID uuid.UUID, at time.Time) error
+}
+
+// ModerationStore persists the moderation log.
+type ModerationStore interface {
+	Record(ctx context.Context, decision *models.ModerationDecision) error
+	// ListForMessages returns the decisions about messageIDs, oldest first.
+	ListForMessages(ctx context.Context, messageIDs []uuid.UUID) ([]models.ModerationDecision, error)
+}
+
+// ScheduledStore persists messages scheduled for later delivery.
+type ScheduledStore interface {
+	Create(ctx context.Context, scheduled *models.ScheduledMessage) error
+	// CountPending returns how many messages senderID has pending.
+	CountPending(ctx context.Context, senderID uuid.UUID) (int64, error)
+	// List returns senderID's scheduled messages with status, soonest first.
+	List(ctx context.Context, senderID uuid.UUID, status string) ([]models.ScheduledMessage, error)
+	// Delete deletes senderID's scheduled message id, or returns ErrNotFound.
+	Delete(ctx context.Context, id, senderID uuid.UUID) error
+}
+
+// ReportStore persists message reports.
+type ReportStore interface {
+	// FindOpen returns reporterID's open report on messageID.
+	FindOpen(ctx context.Context, messageID, reporterID uuid.UUID) (models.Report, error)
+	Create(ctx context.Context, report *models.Report) error
+	// Close closes the open report id at at and returns it, or returns
+	// ErrNotFound.
+	Close(ctx context.Context, id uuid.UUID, at time.Time) (models.Report, error)
+}
+
+// RematchStore persists rematch requests.
+type RematchStore interface {
+	// Find returns the request requesterID made for matchID.
+	Find(ctx context.Context, matchID, requesterID uuid.UUID) (models.RematchRequest, error)
+	Create(ctx context.Context, request *models.RematchRequest) error
+	// ListPending returns the pending requests sent to targetID, newest
+	// first.
+	ListPending(ctx context.Context, targetID uuid.UUID) ([]models.RematchRequest, error)
+	// FindPending returns the request id sent to targetID if still pending.
+	FindPending(ctx context.Context, id, targetID uuid.UUID) (models.RematchRequest, error)
+	// Accept marks request, and a crossed request for the same match, as
+	// accepted at at with newMatchID. It returns ErrNotFound if request is
+	// no longer pending.
+	Accept(ctx context.Context, request models.RematchRequest, newMatchID uuid.UUID, at time.Time) error
+	// Decline marks request as declined at at.
+	Decline(ctx context.Context, request *models.RematchRequest, at time.Time) error
+}
+
+// SettingsStore persists user settings.
+type SettingsStore interface {
+	// Find returns userID's settings; users without any use the defaults.
+	Find(ctx context.Context, userID uuid.UUID) (models.UserSettings, error)
+	Save(ctx context.Context, settings *models.UserSettings) error
+}
+
+// ErasureStore erases deleted accounts.
+type ErasureStore interface {
+	// Erase applies the erasure policy of package erasure to userID.
+	Erase(ctx context.Context, userID uuid.UUID) (erasure.Result, error)
+}
+
+// ExportSet is one set of rows of a user's data export.
+type ExportSet string
+
+// Export sets, named after the file they are written to.
+const (
+	ExportLikesSent         ExportSet = "likes_sent"
+	ExportLikesReceived     ExportSet = "likes_received"
+	ExportDislikesSent      ExportSet = "dislikes_sent"
+	ExportDislikesReceived  ExportSet = "dislikes_received"
+	ExportMatches           ExportSet = "matches"
+	ExportMatchExtensions   ExportSet = "match_extensions"
+	ExportRematchRequests   ExportSet = "rematch_requests"
+	ExportMessages          ExportSet = "messages"
+	ExportScheduledMessages ExportSet = "scheduled_messages"
+	ExportIcebreakersSent   ExportSet = "icebreakers_sent"
+	ExportReactionsSent     ExportSet = "reactions_sent"
+	ExportAttachmentsSent   ExportSet = "attachments_sent"
+	ExportReportsMade       ExportSet = "reports_made"
+	ExportSettings          ExportSet = "settings"
+	ExportBlocksMade        ExportSet = "blocks_made"
+	ExportBlocksReceived    ExportSet = "blocks_received"
+)
+
+// ExportStore reads the data of a user's export.
+type ExportStore interface {
+	// Each passes the rows of set that belong to userID to emit, one at a
+	// time and oldest first, as pointers to their model. It stops at the
+	// first error emit returns.
+	Each(ctx context.Context, set ExportSet, userID uuid.UUID, emit func(row interface{}) error) error
+}
+
+// Stores groups the stores a handler depends on.
+type Stores struct {
+	Likes       LikeStore
+	Matches     MatchStore
+	Messages    MessageStore
+	Blocks      BlockStore
+	Attachments AttachmentStore
+	Reactions   ReactionStore
+	Icebreakers IcebreakerStore
+	Moderation  ModerationStore
+	Scheduled   ScheduledStore
+	Reports     ReportStore
+	Rematches   RematchStore
+	Settings    SettingsStore
+	Erasure     ErasureStore
+	Exports     ExportStore
+
+	// atomic runs fn on s, or on stores bound to a transaction.
+	atomic func(ctx context.Context, s Stores, fn func(Stores) error) error
+}
+
+// Atomic runs fn as one unit of work: if fn returns an error, none of the
+// writes it made through the stores it was given are kept.
+func (s Stores) Atomic(ctx context.Context, fn func(Stores) error) error {
+	if s.atomic == nil {
+		return fn(s)
+	}
+	return s.atomic(ctx, s, fn)
+}
@@ -0,0 +1,166 @@
+// Tests for message attachments and signed download URLs.
+
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"way-d-interactions/config"
+	"way-d-interactions/models"
+	"way-d-interactions/storage"
+)
+
+func uploadAttachment(r http.Handler, userID, matchID, filename string, content []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", filename)
+	fw.Write(content)
+	mw.Close()
+	req, _ := http.NewRequest("POST", "/api/matches/"+matchID+"/attachments", &body)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(userID))
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAttachmentUploadAndSignedDownload(t *testing.T) {
+	r := setupMemoryRouter()
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+	outsider := "33333333-3333-3333-3333-333333333333"
+	matchID := createMatch(t, r, user1, user2)
+
+	var img bytes.Buffer
+	png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 4, 3)))
+	w := uploadAttachment(r, user1, matchID, "photo.png", img.Bytes())
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Upload failed: %d %s", w.Code, w.Body.String())
+	}
+	var attachment map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &attachment)
+	if attachment["mime_type"] != "image/png" || attachment["width"] != float64(4) || attachment["height"] != float64(3) {
+		t.Errorf("Unexpected attachment metadata: %s", w.Body.String())
+	}
+	attachmentID := attachment["id"].(string)
+
+	// Files are sniffed rather than trusted by extension.
+	if w := uploadAttachment(r, user1, matchID, "photo.png", []byte("#!/bin/sh\necho hi\n")); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Script upload should be rejected: %d %s", w.Code, w.Body.String())
+	}
+
+	payload, _ := json.Marshal(map[string]string{"match_id": matchID, "attachment_id": attachmentID})
+	req, _ := http.NewRequest("POST", "/api/message", bytes.NewBuffer(payload))
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user1))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), attachmentID) {
+		t.Fatalf("Message with attachment failed: %d %s", w.Code, w.Body.String())
+	}
+
+	getURL := func(userID string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/attachments/"+attachmentID+"/url", nil)
+		req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(userID))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	if w := getURL(outsider); w.Code != http.StatusNotFound {
+		t.Errorf("Non-participants must not get a download URL: %d", w.Code)
+	}
+	w = getURL(user2)
+	var signed storage.SignedURL
+	_ = json.Unmarshal(w.Body.Bytes(), &signed)
+	req, _ = http.NewRequest("GET", signed.URL, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), img.Bytes()) {
+		t.Errorf("Signed download failed: %d", w.Code)
+	}
+	req, _ = http.NewRequest("GET", strings.Replace(signed.URL, "user="+user2, "user="+outsider, 1), nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Tampered signed URL should be rejected: %d", w.Code)
+	}
+}
+
+func TestSignedURLExpiry(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Now()
+	signed := storage.Sign(secret, "/attachments/", "abc", "user", now.Add(time.Minute))
+	u, _ := url.Parse(signed.URL)
+	if user, ok := storage.Verify(secret, "abc", u.Query(), now); !ok || user != "user" {
+		t.Errorf("Fresh link should verify")
+	}
+	if _, ok := storage.Verify(secret, "abc", u.Query(), now.Add(2*time.Minute)); ok {
+		t.Errorf("Expired link should not verify")
+	}
+	if _, ok := storage.Verify(secret, "other", u.Query(), now); ok {
+		t.Errorf("Link must not verify for another object")
+	}
+}
+
+func TestAttachmentVisibility(t *testing.T) {
+	setupTestDB(t)
+	r := setupRouter()
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+	matchID := createMatch(t, r, user1, user2)
+	var img bytes.Buffer
+	png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	upload := func() string {
+		var attachment map[string]interface{}
+		_ = json.Unmarshal(uploadAttachment(r, user1, matchID, "photo.png", img.Bytes()).Body.Bytes(), &attachment)
+		id, _ := attachment["id"].(string)
+		return id
+	}
+	getURL := func(userID, attachmentID string) int {
+		req, _ := http.NewRequest("GET", "/api/attachments/"+attachmentID+"/url", nil)
+		req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(userID))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	attachmentID := upload()
+	w := doJSON(r, "POST", "/api/message", user1, map[string]string{"match_id": matchID, "attachment_id": attachmentID})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Message with attachment failed: %d %s", w.Code, w.Body.String())
+	}
+	var msg models.Message
+	_ = json.Unmarshal(w.Body.Bytes(), &msg)
+	db := config.GetDB()
+
+	db.Model(&models.Message{}).Where("id = ?", msg.ID).Update("held", true)
+	if code := getURL(user2, attachmentID); code != http.StatusNotFound {
+		t.Errorf("The attachment of a held message should be hidden from the receiver: %d", code)
+	}
+	if code := getURL(user1, attachmentID); code != http.StatusOK {
+		t.Errorf("The sender should still see the attachment of a held message: %d", code)
+	}
+	db.Model(&models.Message{}).Where("id = ?", msg.ID).Updates(map[string]interface{}{"held": false, "deleted": true})
+	if code := getURL(user1, attachmentID); code != http.StatusNotFound {
+		t.Errorf("The attachment of a deleted message should be hidden: %d", code)
+	}
+
+	unsent := upload()
+	db.Model(&models.Match{}).Where("id = ?", matchID).Update("expire_at", time.Now().Add(-time.Hour))
+	if code := getURL(user1, unsent); code != http.StatusNotFound {
+		t.Errorf("Attachments of an expired match should be hidden: %d", code)
+	}
+	if w := uploadAttachment(r, user1, matchID, "photo.png", img.Bytes()); w.Code != http.StatusForbidden {
+		t.Errorf("Uploading to an expired match should be refused: %d %s", w.Code, w.Body.String())
+	}
+}
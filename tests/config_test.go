@@ -0,0 +1,105 @@
+// Tests for loading and validating the configuration.
+
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"way-d-interactions/config"
+)
+
+// unsetenv clears key for the rest of the test and restores it afterwards.
+func unsetenv(t *testing.T, key string) {
+	t.Setenv(key, "")
+	os.Unsetenv(key)
+}
+
+func TestConfigLoad(t *testing.T) {
+	t.Setenv("DB_USER", "wayd_user")
+	t.Setenv("DB_NAME", "wayd_interactions")
+	t.Setenv("DB_PASSWORD", "hunter2")
+	t.Setenv("DB_SSLMODE", "require")
+	t.Setenv("DB_MAX_OPEN_CONNS", "40")
+	unsetenv(t, "CORS_ALLOWED_ORIGINS")
+	unsetenv(t, "SHUTDOWN_TIMEOUT")
+	file := filepath.Join(t.TempDir(), "interactions.env")
+	os.WriteFile(file, []byte("CORS_ALLOWED_ORIGINS=https://app.way-d.com, https://admin.way-d.com\nSHUTDOWN_TIMEOUT=1m\nDB_MAX_OPEN_CONNS=5\n"), 0o600)
+	t.Setenv("CONFIG_FILE", file)
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(cfg.CORSAllowedOrigins, " "); got != "https://app.way-d.com https://admin.way-d.com" {
+		t.Errorf("Origins should come from the file, got %q", got)
+	}
+	if cfg.ShutdownTimeout != time.Minute || cfg.Database.MaxOpenConns != 40 {
+		t.Errorf("The file should fill in unset variables only, got %s and %d", cfg.ShutdownTimeout, cfg.Database.MaxOpenConns)
+	}
+	if dsn := cfg.Database.DSN(); !strings.Contains(dsn, "sslmode=require") {
+		t.Errorf("The DSN should use the configured sslmode: %s", dsn)
+	}
+
+	cfg.JWTSecret = "secret"
+	cfg.Attachments.SigningSecret = "signing-key"
+	cfg.ExportPseudonymSecret = "pseudonym-key"
+	dump := fmt.Sprintf("%+v", cfg.Redacted())
+	if strings.Contains(dump, "hunter2") || strings.Contains(dump, "secret") || strings.Contains(dump, "-key") {
+		t.Errorf("The redacted configuration leaks a secret: %s", dump)
+	}
+	if cfg.Database.Password != "hunter2" {
+		t.Error("Redacted should not modify the configuration")
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	t.Setenv("DB_USER", "wayd_user")
+	t.Setenv("DB_NAME", "wayd_interactions")
+	t.Setenv("DB_SSLMODE", "sometimes")
+	t.Setenv("DB_MAX_IDLE_CONNS", "many")
+	t.Setenv("LOG_LEVEL", "verbose")
+	t.Setenv("LIKE_NOTE_MAX_RUNES", "lots")
+	t.Setenv("ATTACHMENT_URL_TTL", "15")
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.env"))
+	if _, err := config.Load(); err == nil {
+		t.Error("A missing CONFIG_FILE should fail")
+	}
+	unsetenv(t, "CONFIG_FILE")
+	_, err := config.Load()
+	if err == nil || !strings.Contains(err.Error(), "DB_SSLMODE") || !strings.Contains(err.Error(), "DB_MAX_IDLE_CONNS") || !strings.Contains(err.Error(), "LOG_LEVEL") {
+		t.Errorf("Every invalid setting should be reported, got %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "LIKE_NOTE_MAX_RUNES") || !strings.Contains(err.Error(), "ATTACHMENT_URL_TTL") {
+		t.Errorf("Malformed feature settings should be reported, not replaced by their default, got %v", err)
+	}
+
+	cfg := config.FromEnv()
+	cfg.JWTSecret = ""
+	cfg.CORSAllowedOrigins = []string{"*"}
+	err = cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "JWT_SECRET") || !strings.Contains(err.Error(), "CORS_ALLOWED_ORIGINS") {
+		t.Errorf("Serving needs a JWT secret and explicit origins, got %v", err)
+	}
+	cfg.JWTSecret = "secret"
+	cfg.CORSAllowedOrigins = []string{"http://localhost:8083"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("A complete configuration should validate: %v", err)
+	}
+
+	features := *cfg
+	features.Messages.LikeNoteMaxRunes = features.Messages.MaxRunes + 1
+	features.Moderation.URLAction = "ban"
+	features.Matches.ExtensionsPerUser = -1
+	features.Retention.PurgeInterval = 0
+	err = features.Validate()
+	for _, key := range []string{"LIKE_NOTE_MAX_RUNES", "MODERATION_URL_ACTION", "MATCH_EXTENSIONS_PER_USER", "RETENTION_PURGE_INTERVAL"} {
+		if err == nil || !strings.Contains(err.Error(), key) {
+			t.Errorf("Validate should report %s, got %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Tests for the account deletion erasure cascade.
+
+package tests
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"way-d-interactions/config"
+	"way-d-interactions/models"
+	"way-d-interactions/testutil"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestEraseUserKeepsBlocksAgainstThem(t *testing.T) {
+	setupTestDB(t)
+	r := setupRouter()
+	deleted := "00000000-0000-0000-0000-000000000001"
+	jwt1 := GenerateTestJWT(deleted)
+	jwt3 := GenerateTestJWT("33333333-3333-3333-3333-333333333333")
+	// The deleted user likes user 2 and is blocked by user 3.
+	body := `{"target_id": "11111111-1111-1111-1111-111111111111"}`
+	req, _ := http.NewRequest("POST", "/api/like", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+jwt1)
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	body = `{"blocked_id": "` + deleted + `"}`
+	req, _ = http.NewRequest("POST", "/api/block", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+jwt3)
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	// The deleted user was moderated, reported a message and uploaded a photo.
+	db := config.GetDB()
+	deletedID, otherID := uuid.MustParse(deleted), uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	db.Create(&models.ModerationDecision{ID: uuid.New(), SenderID: otherID, ReceiverID: deletedID, Filter: "keywords", Action: "hold", CreatedAt: time.Now()})
+	db.Create(&models.Report{ID: uuid.New(), MessageID: uuid.New(), ReporterID: deletedID, Status: models.ReportStatusOpen, CreatedAt: time.Now()})
+	db.Create(&models.Attachment{ID: uuid.New(), UploaderID: deletedID, MatchID: uuid.New(), Kind: "image", MimeType: "image/png", Checksum: "x", StorageKey: "k", CreatedAt: time.Now()})
+
+	// Without the internal token the endpoint is refused.
+	req, _ = http.NewRequest("DELETE", "/internal/users/"+deleted, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Erasure without internal token should be rejected: %d %s", w.Code, w.Body.String())
+	}
+	// Erasing twice must succeed both times.
+	for i := 0; i < 2; i++ {
+		req, _ = http.NewRequest("DELETE", "/internal/users/"+deleted, nil)
+		req.Header.Set("X-Internal-Token", testInternalToken)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Erasure %d failed: %d %s", i+1, w.Code, w.Body.String())
+		}
+	}
+	var likes, blocks int64
+	db.Model(&models.Like{}).Where("user_id = ?", deleted).Count(&likes)
+	db.Model(&models.Block{}).Where("blocked_id = ?", deleted).Count(&blocks)
+	if likes != 0 {
+		t.Errorf("Expected likes to be erased, %d left", likes)
+	}
+	if blocks != 1 {
+		t.Errorf("Expected the block against the deleted user to be kept, got %d", blocks)
+	}
+	for name, q := range map[string]*gorm.DB{
+		"moderation decisions": db.Model(&models.ModerationDecision{}).Where("sender_id = ? OR receiver_id = ?", deleted, deleted),
+		"reports":              db.Model(&models.Report{}).Where("reporter_id = ?", deleted),
+		"attachments":          db.Model(&models.Attachment{}).Where("uploader_id = ?", deleted),
+	} {
+		var n int64
+		q.Count(&n)
+		if n != 0 {
+			t.Errorf("Expected no %s to refer to the deleted user, %d left", name, n)
+		}
+	}
+	var anonymised int64
+	db.Model(&models.Attachment{}).Where("uploader_id = ?", uuid.Nil).Count(&anonymised)
+	if anonymised != 1 {
+		t.Errorf("Expected the attachment to be kept for the cleanup job, anonymised, got %d", anonymised)
+	}
+}
+
+func TestEraseUserRemovesMessagesWithoutMatch(t *testing.T) {
+	db := testutil.DB(t)
+	r := setupRouter()
+	deleted, other := testutil.NewUserID(), testutil.NewUserID()
+	// Migration 0004 leaves match_id NULL when the pair's match is gone.
+	messageID := uuid.New()
+	if err := db.Exec("INSERT INTO messages (id, match_id, sender_id, receiver_id, content, created_at) VALUES (?, NULL, ?, ?, ?, now())",
+		messageID, deleted, other, "Call me on 555-0100").Error; err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&models.Reaction{ID: uuid.New(), MessageID: messageID, UserID: uuid.MustParse(other), Emoji: "👍", CreatedAt: time.Now()})
+
+	req, _ := http.NewRequest("DELETE", "/internal/users/"+deleted, nil)
+	req.Header.Set("X-Internal-Token", testInternalToken)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Erasure failed: %d %s", w.Code, w.Body.String())
+	}
+	var messages, reactions int64
+	db.Model(&models.Message{}).Where("id = ?", messageID).Count(&messages)
+	db.Model(&models.Reaction{}).Where("message_id = ?", messageID).Count(&reactions)
+	if messages != 0 || reactions != 0 {
+		t.Errorf("Expected the message without a match and its reactions to be erased, %d and %d left", messages, reactions)
+	}
+}
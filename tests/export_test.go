@@ -0,0 +1,87 @@
+// Tests for the GDPR data export archive.
+
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"way-d-interactions/config"
+	"way-d-interactions/models"
+
+	"github.com/google/uuid"
+)
+
+func TestExportArchive(t *testing.T) {
+	setupTestDB(t)
+	r := setupRouter()
+	jwt1 := GenerateTestJWT("00000000-0000-0000-0000-000000000001")
+	jwt3 := GenerateTestJWT("33333333-3333-3333-3333-333333333333")
+	// User 3 likes user 1 without a match: user 3 must be pseudonymised.
+	body := `{"target_id": "00000000-0000-0000-0000-000000000001"}`
+	req, _ := http.NewRequest("POST", "/api/like", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+jwt3)
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	// User 1 likes user 2.
+	body = `{"target_id": "11111111-1111-1111-1111-111111111111"}`
+	req, _ = http.NewRequest("POST", "/api/like", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+jwt1)
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	// User 2 sent user 1 a message held for moderation, which user 1 never saw.
+	config.GetDB().Create(&models.Message{
+		ID:         uuid.New(),
+		MatchID:    uuid.New(),
+		SenderID:   uuid.MustParse("11111111-1111-1111-1111-111111111111"),
+		ReceiverID: uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+		Content:    "Held for review",
+		Held:       true,
+		CreatedAt:  time.Now(),
+	})
+
+	req, _ = http.NewRequest("GET", "/api/me/export", nil)
+	req.Header.Set("Authorization", "Bearer "+jwt1)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Export failed: %d %s", w.Code, w.Body.String())
+	}
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("Export is not a valid zip archive: %v", err)
+	}
+	contents := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		var buf bytes.Buffer
+		buf.ReadFrom(rc)
+		rc.Close()
+		contents[f.Name] = buf.String()
+	}
+	for _, name := range []string{"likes_sent.json", "likes_received.json", "matches.json", "messages.json", "blocks_made.json", "blocks_received.json", "summary.txt"} {
+		if _, ok := contents[name]; !ok {
+			t.Errorf("Export is missing %s", name)
+		}
+	}
+	var sent []map[string]interface{}
+	if err := json.Unmarshal([]byte(contents["likes_sent.json"]), &sent); err != nil || len(sent) != 1 {
+		t.Errorf("Expected one sent like, got %v (%v)", sent, err)
+	}
+	if strings.Contains(contents["likes_received.json"], "33333333-3333-3333-3333-333333333333") {
+		t.Errorf("Unmatched liker should be pseudonymised: %s", contents["likes_received.json"])
+	}
+	if !strings.Contains(contents["likes_received.json"], "anon-") {
+		t.Errorf("Expected a pseudonym in received likes: %s", contents["likes_received.json"])
+	}
+	if strings.Contains(contents["messages.json"], "Held for review") {
+		t.Errorf("Held messages received should not be exported: %s", contents["messages.json"])
+	}
+}
@@ -0,0 +1,55 @@
+// Tests for the test harness: per-test schemas and fixture factories.
+
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"way-d-interactions/models"
+	"way-d-interactions/testutil"
+)
+
+func TestSchemaIsolation(t *testing.T) {
+	for _, name := range []string{"first", "second"} {
+		t.Run(name, func(t *testing.T) {
+			db := testutil.DB(t)
+			var likes int64
+			db.Model(&models.Like{}).Count(&likes)
+			if likes != 0 {
+				t.Fatalf("Each test should start without likes, found %d", likes)
+			}
+			testutil.Like(t, db, testutil.NewUserID(), testutil.NewUserID())
+		})
+	}
+}
+
+func TestFactoriesThroughTheAPI(t *testing.T) {
+	db := testutil.DB(t)
+	r := setupRouter()
+	alice, bob := testutil.NewUserID(), testutil.NewUserID()
+	match := testutil.Match(t, db, alice, bob)
+	testutil.Message(t, db, match, bob, "Hello Alice")
+
+	req, _ := http.NewRequest("GET", "/api/messages/"+match.ID.String(), nil)
+	req.Header.Set("Authorization", "Bearer "+testutil.Token(alice))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var messages []models.Message
+	_ = json.Unmarshal(w.Body.Bytes(), &messages)
+	if w.Code != http.StatusOK || len(messages) != 1 || messages[0].Content != "Hello Alice" {
+		t.Fatalf("Expected the factory message, got %d %s", w.Code, w.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/api/matches", nil)
+	req.Header.Set("Authorization", "Bearer "+testutil.Token(bob))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var matches []models.Match
+	_ = json.Unmarshal(w.Body.Bytes(), &matches)
+	if len(matches) != 1 || matches[0].InitiatorID == nil || matches[0].InitiatorID.String() != alice || matches[0].FirstMessageAt == nil {
+		t.Errorf("Expected alice's match with its first message time, got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,145 @@
+// Tests for the liveness and readiness probes and for coordinated shutdown.
+
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"way-d-interactions/controllers"
+	"way-d-interactions/realtime"
+	"way-d-interactions/routes"
+	"way-d-interactions/testutil"
+	"way-d-interactions/workers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func probe(r *gin.Engine, path string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", path, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestProbes(t *testing.T) {
+	db := testutil.DB(t)
+	group := workers.NewGroup(context.Background())
+	group.Go("waiting", func(ctx context.Context) { <-ctx.Done() })
+	probes, err := controllers.NewProbes(db, group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	routes.RegisterProbes(r, probes)
+
+	if w := probe(r, "/healthz"); w.Code != http.StatusOK {
+		t.Errorf("Liveness should succeed: %d", w.Code)
+	}
+	if w := probe(r, "/readyz"); w.Code != http.StatusOK {
+		t.Errorf("A migrated database with running workers should be ready: %d %s", w.Code, w.Body.String())
+	}
+
+	failing, _ := controllers.NewProbes(db, workers.NewGroup(context.Background()))
+	failing.Workers.Go("crashing", func(context.Context) {})
+	r2 := gin.New()
+	routes.RegisterProbes(r2, failing)
+	deadline := time.Now().Add(time.Second)
+	for len(failing.Workers.Stopped()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if w := probe(r2, "/readyz"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("A stopped worker should make the instance unready: %d %s", w.Code, w.Body.String())
+	}
+
+	probes.Drain()
+	if w := probe(r, "/readyz"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("A draining instance should be unready: %d", w.Code)
+	}
+	if w := probe(r, "/healthz"); w.Code != http.StatusOK {
+		t.Errorf("A draining instance is still alive: %d", w.Code)
+	}
+	if err := group.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadinessHidesDatabaseErrors(t *testing.T) {
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=wayd password=hunter2 dbname=wayd connect_timeout=1"), &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	probes, err := controllers.NewProbes(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	routes.RegisterProbes(r, probes)
+
+	w := probe(r, "/readyz")
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "database unavailable") {
+		t.Errorf("An unreachable database should make the instance unready: %d %s", w.Code, w.Body.String())
+	}
+	for _, detail := range []string{"127.0.0.1", "hunter2", "wayd", "dial"} {
+		if strings.Contains(w.Body.String(), detail) {
+			t.Errorf("The probe should not reveal %q: %s", detail, w.Body.String())
+		}
+	}
+}
+
+func TestWorkerGroupShutdown(t *testing.T) {
+	group := workers.NewGroup(context.Background())
+	stopped := make(chan struct{})
+	group.Go("ticker", func(ctx context.Context) {
+		<-ctx.Done()
+		close(stopped)
+	})
+	group.Task(func(ctx context.Context) { <-ctx.Done() })
+	if names := group.Stopped(); len(names) != 0 {
+		t.Errorf("No worker should have stopped yet, got %v", names)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := group.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown should wait for the workers: %v", err)
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Error("Shutdown returned before the worker stopped")
+	}
+
+	stuck := workers.NewGroup(context.Background())
+	release := make(chan struct{})
+	defer close(release)
+	stuck.Task(func(context.Context) { <-release })
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := stuck.Shutdown(ctx); err == nil {
+		t.Error("Shutdown should give up once its context is done")
+	}
+}
+
+func TestHubCloseEndsStreams(t *testing.T) {
+	hub := realtime.NewHub()
+	user := uuid.New()
+	events, unsubscribe := hub.Subscribe(user)
+	hub.Close()
+	if _, ok := <-events; ok {
+		t.Error("Closing the hub should close open streams")
+	}
+	unsubscribe()
+	late, _ := hub.Subscribe(user)
+	if _, ok := <-late; ok {
+		t.Error("A closed hub should refuse new streams")
+	}
+}
@@ -0,0 +1,118 @@
+// Tests for icebreaker suggestions and conversion tracking.
+
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"way-d-interactions/config"
+	"way-d-interactions/controllers"
+	"way-d-interactions/models"
+	"way-d-interactions/store"
+)
+
+func TestIcebreakerSuggestionsAndConversion(t *testing.T) {
+	stores := store.NewMemory()
+	if err := controllers.SeedIcebreakers(context.Background(), stores.Icebreakers); err != nil {
+		t.Fatalf("Seeding failed: %v", err)
+	}
+	r := setupRouterWith(stores)
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+	matchID := createMatch(t, r, user1, user2)
+
+	suggest := func() []models.Icebreaker {
+		req, _ := http.NewRequest("GET", "/api/matches/"+matchID+"/icebreakers", nil)
+		req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user1))
+		req.Header.Set("Accept-Language", "fr-CA,fr;q=0.9,en;q=0.5")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var icebreakers []models.Icebreaker
+		_ = json.Unmarshal(w.Body.Bytes(), &icebreakers)
+		return icebreakers
+	}
+	suggestions := suggest()
+	if len(suggestions) != 3 {
+		t.Fatalf("Expected 3 suggestions, got %d", len(suggestions))
+	}
+	for _, ib := range suggestions {
+		if ib.Locale != "fr" {
+			t.Errorf("Expected French suggestions, got %s", ib.Locale)
+		}
+	}
+	if again := suggest(); again[0].ID != suggestions[0].ID {
+		t.Error("Suggestions should be stable for a match")
+	}
+
+	chosen := suggestions[0]
+	payload, _ := json.Marshal(map[string]string{"match_id": matchID, "content": chosen.Text, "icebreaker_id": chosen.ID.String()})
+	req, _ := http.NewRequest("POST", "/api/message", bytes.NewBuffer(payload))
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user1))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Sending the icebreaker failed: %d %s", w.Code, w.Body.String())
+	}
+	for _, ib := range suggest() {
+		if ib.ID == chosen.ID {
+			t.Error("An icebreaker already sent should not be suggested again")
+		}
+	}
+	sendMessage(r, user2, matchID, "Le Japon, sans hésiter !")
+
+	req, _ = http.NewRequest("GET", "/internal/icebreakers/stats?locale=fr", nil)
+	req.Header.Set("X-Internal-Token", testInternalToken)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var stats []map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &stats)
+	if len(stats) == 0 || stats[0]["id"] != chosen.ID.String() || stats[0]["sent"] != float64(1) || stats[0]["replied"] != float64(1) {
+		t.Errorf("Expected the chosen icebreaker to be sent and replied to once, got %v", stats)
+	}
+}
+
+func TestIcebreakerDatabaseErrors(t *testing.T) {
+	setupTestDB(t)
+	db := config.GetDB()
+	if err := controllers.SeedIcebreakers(context.Background(), store.NewGorm(db).Icebreakers); err != nil {
+		t.Fatalf("Seeding failed: %v", err)
+	}
+	r := setupRouter()
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+	matchID := createMatch(t, r, user1, user2)
+	var icebreaker models.Icebreaker
+	db.First(&icebreaker)
+
+	// Updates the stored catalogue rejects must not be reported as saved.
+	if err := db.Exec("ALTER TABLE icebreakers ADD CONSTRAINT short_text CHECK (length(text) < 20) NOT VALID").Error; err != nil {
+		t.Fatal(err)
+	}
+	payload, _ := json.Marshal(map[string]string{"locale": "en", "text": "What's your favourite film of all time?"})
+	req, _ := http.NewRequest("PUT", "/internal/icebreakers/"+icebreaker.ID.String(), bytes.NewBuffer(payload))
+	req.Header.Set("X-Internal-Token", testInternalToken)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("A failed update should be reported: %d %s", w.Code, w.Body.String())
+	}
+
+	// Without usage records suggestions cannot leave out sent icebreakers.
+	if err := db.Exec("DROP TABLE icebreaker_usages").Error; err != nil {
+		t.Fatal(err)
+	}
+	req, _ = http.NewRequest("GET", "/api/matches/"+matchID+"/icebreakers", nil)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user1))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("A failed lookup should be reported, not an empty list: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -8,36 +8,21 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"testing"
-	"time"
 
-	"way-d-interactions/config"
-	"way-d-interactions/models"
 	"way-d-interactions/routes"
+	"way-d-interactions/testutil"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-// GenerateTestJWT returns a valid JWT for the test user with the correct secret and claims.
+// GenerateTestJWT returns a valid JWT for the test user.
 func GenerateTestJWT(userID string) string {
-	secret := os.Getenv("JWT_SECRET")
-	type testClaims struct {
-		UserID string `json:"user_id"`
-		jwt.RegisteredClaims
-	}
-	claims := testClaims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(1 * time.Hour)),
-		},
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, _ := token.SignedString([]byte(secret))
-	return signed
+	return testutil.Token(userID)
 }
 
+const testInternalToken = testutil.InternalToken
+
 func setupRouter() *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.Default()
@@ -45,22 +30,60 @@ func setupRouter() *gin.Engine {
 	return r
 }
 
-func setupTestDB() {
-	os.Setenv("DB_HOST", "localhost")
-	os.Setenv("DB_PORT", "5432")
-	os.Setenv("DB_USER", "wayd_user")
-	os.Setenv("DB_PASSWORD", "test")
-	os.Setenv("DB_NAME", "wayd_interactions_test")
-	os.Setenv("JWT_SECRET", "e5b9922f19cf240b093a3e851f905bce71d8444b44c13d616c9c58bf2cbb8b78")
-	config.ConnectDB()
-	db := config.GetDB()
-	db.Migrator().DropTable(&models.Like{}, &models.Dislike{}, &models.Match{}, &models.Message{}, &models.Block{})
-	db.AutoMigrate(&models.Like{}, &models.Dislike{}, &models.Match{}, &models.Message{}, &models.Block{})
+// setupTestDB gives t empty tables in a schema of its own.
+func setupTestDB(t *testing.T) {
+	testutil.DB(t)
+}
+
+// createMatch makes user1 and user2 like each other and returns the match ID.
+func createMatch(t *testing.T, r *gin.Engine, user1, user2 string) string {
+	t.Helper()
+	for _, pair := range [][2]string{{user1, user2}, {user2, user1}} {
+		body := fmt.Sprintf(`{"target_id": "%s"}`, pair[1])
+		req, _ := http.NewRequest("POST", "/api/like", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(pair[0]))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	req, _ := http.NewRequest("GET", "/api/matches", nil)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user1))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var matches []map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &matches)
+	for _, m := range matches {
+		if m["user1_id"] == user2 || m["user2_id"] == user2 {
+			return m["id"].(string)
+		}
+	}
+	t.Fatalf("No match created between %s and %s", user1, user2)
+	return ""
+}
+
+// blockUser makes userID block blockedID.
+func blockUser(r *gin.Engine, userID, blockedID string) *httptest.ResponseRecorder {
+	body := fmt.Sprintf(`{"blocked_id": "%s"}`, blockedID)
+	req, _ := http.NewRequest("POST", "/api/block", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(userID))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// sendMessage posts content to a match as userID.
+func sendMessage(r *gin.Engine, userID, matchID, content string) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(map[string]string{"match_id": matchID, "content": content})
+	req, _ := http.NewRequest("POST", "/api/message", bytes.NewBuffer(payload))
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(userID))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
 }
 
 func TestLikeAndMatch(t *testing.T) {
-	setupTestDB()
-	r := setupRouter()
+	r := setupMemoryRouter()
 	jwt := GenerateTestJWT("00000000-0000-0000-0000-000000000001")
 	body := `{"target_id": "11111111-1111-1111-1111-111111111111"}`
 	req, _ := http.NewRequest("POST", "/api/like", bytes.NewBufferString(body))
@@ -74,8 +97,7 @@ func TestLikeAndMatch(t *testing.T) {
 }
 
 func TestBlockAndCleanup(t *testing.T) {
-	setupTestDB()
-	r := setupRouter()
+	r := setupMemoryRouter()
 	jwt := GenerateTestJWT("00000000-0000-0000-0000-000000000001")
 	body := `{"blocked_id": "11111111-1111-1111-1111-111111111111"}`
 	req, _ := http.NewRequest("POST", "/api/block", bytes.NewBufferString(body))
@@ -89,8 +111,7 @@ func TestBlockAndCleanup(t *testing.T) {
 }
 
 func TestMessageRestriction(t *testing.T) {
-	setupTestDB()
-	r := setupRouter()
+	r := setupMemoryRouter()
 	jwt := GenerateTestJWT("00000000-0000-0000-0000-000000000001")
 	body := `{"match_id": "11111111-1111-1111-1111-111111111111", "content": "hi"}`
 	req, _ := http.NewRequest("POST", "/api/message", bytes.NewBufferString(body))
@@ -104,8 +125,7 @@ func TestMessageRestriction(t *testing.T) {
 }
 
 func TestDislikePreventsMatch(t *testing.T) {
-	setupTestDB()
-	r := setupRouter()
+	r := setupMemoryRouter()
 	jwt := GenerateTestJWT("00000000-0000-0000-0000-000000000001")
 	// Dislike a user
 	body := `{"target_id": "11111111-1111-1111-1111-111111111111"}`
@@ -130,8 +150,7 @@ func TestDislikePreventsMatch(t *testing.T) {
 }
 
 func TestGetMatches(t *testing.T) {
-	setupTestDB()
-	r := setupRouter()
+	r := setupMemoryRouter()
 	jwt := GenerateTestJWT("00000000-0000-0000-0000-000000000001")
 	// Like user 2
 	body := `{"target_id": "11111111-1111-1111-1111-111111111111"}`
@@ -164,8 +183,7 @@ func TestGetMatches(t *testing.T) {
 }
 
 func TestGetBlocks(t *testing.T) {
-	setupTestDB()
-	r := setupRouter()
+	r := setupMemoryRouter()
 	jwt := GenerateTestJWT("00000000-0000-0000-0000-000000000001")
 	// Block a user
 	body := `{"blocked_id": "11111111-1111-1111-1111-111111111111"}`
@@ -190,8 +208,7 @@ func TestGetBlocks(t *testing.T) {
 }
 
 func TestMessagingAfterMatch(t *testing.T) {
-	setupTestDB()
-	r := setupRouter()
+	r := setupMemoryRouter()
 	jwt1 := GenerateTestJWT("00000000-0000-0000-0000-000000000001")
 	jwt2 := GenerateTestJWT("11111111-1111-1111-1111-111111111111")
 	// Like each other
@@ -234,8 +251,7 @@ func TestMessagingAfterMatch(t *testing.T) {
 }
 
 func TestUnauthorizedAccess(t *testing.T) {
-	setupTestDB()
-	r := setupRouter()
+	r := setupMemoryRouter()
 	body := `{"target_id": "11111111-1111-1111-1111-111111111111"}`
 	req, _ := http.NewRequest("POST", "/api/like", bytes.NewBufferString(body))
 	// No Authorization header
@@ -247,8 +263,7 @@ func TestUnauthorizedAccess(t *testing.T) {
 }
 
 func TestDoubleLikeAndBlockEdgeCases(t *testing.T) {
-	setupTestDB()
-	r := setupRouter()
+	r := setupMemoryRouter()
 	jwt := GenerateTestJWT("00000000-0000-0000-0000-000000000001")
 	// Like user 2
 	body := `{"target_id": "11111111-1111-1111-1111-111111111111"}`
@@ -0,0 +1,63 @@
+// Tests for likes with a note.
+
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func likeWithNote(r *gin.Engine, userID, targetID, note string) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(map[string]string{"target_id": targetID, "note": note})
+	req, _ := http.NewRequest("POST", "/api/like", bytes.NewBuffer(payload))
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(userID))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestLikeNoteBecomesFirstMessage(t *testing.T) {
+	r := setupMemoryRouter()
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+
+	if w := likeWithNote(r, user1, user2, "Nice\x07 smile"); w.Code != http.StatusBadRequest {
+		t.Errorf("A note with control characters should be rejected: %d", w.Code)
+	}
+	if w := likeWithNote(r, user1, user2, "Love your hiking photos!"); w.Code != http.StatusCreated {
+		t.Fatalf("Like with note failed: %d %s", w.Code, w.Body.String())
+	}
+
+	req, _ := http.NewRequest("GET", "/api/likes/received", nil)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user2))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var received []map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &received)
+	if len(received) != 1 || received[0]["note"] != "Love your hiking photos!" {
+		t.Fatalf("Expected the incoming like with its note, got %v", received)
+	}
+	if received[0]["from"] == user1 {
+		t.Error("The liker should be pseudonymised before a match")
+	}
+
+	if w := likeWithNote(r, user2, user1, "Thanks, where was your last trip?"); w.Code != http.StatusCreated {
+		t.Fatalf("Like back failed: %d %s", w.Code, w.Body.String())
+	}
+	matchID := createMatch(t, r, user1, user2)
+	req, _ = http.NewRequest("GET", "/api/messages/"+matchID, nil)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user2))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var messages []map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &messages)
+	if len(messages) != 2 || messages[0]["sender_id"] != user1 || messages[0]["content"] != "Love your hiking photos!" {
+		t.Errorf("Expected both notes as the first messages, earliest first, got %v", messages)
+	}
+}
@@ -0,0 +1,117 @@
+// Tests for structured logging and request IDs.
+
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"way-d-interactions/config"
+	"way-d-interactions/controllers"
+	"way-d-interactions/logging"
+	"way-d-interactions/routes"
+	"way-d-interactions/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// captureLogs makes the default logger write JSON lines to the returned
+// buffer for the rest of the test.
+func captureLogs(t *testing.T, level string) *bytes.Buffer {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&buf, config.LogConfig{Level: level, Format: "json"}))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// logLines decodes the JSON lines in buf whose msg is msg.
+func logLines(t *testing.T, buf *bytes.Buffer, msg string) []map[string]interface{} {
+	var lines []map[string]interface{}
+	for _, raw := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var line map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &line); err != nil {
+			t.Fatalf("Log lines should be JSON: %v (%s)", err, raw)
+		}
+		if line["msg"] == msg {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestRequestLogging(t *testing.T) {
+	logs := captureLogs(t, "info")
+	gin.SetMode(gin.TestMode)
+	r := routes.SetupRouter()
+	routes.RegisterRoutesWith(r, controllers.NewHandler(store.NewMemory()))
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+
+	w := doJSON(r, "POST", "/api/like", user1, map[string]string{"target_id": user2, "note": "my phone number is secret"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Like failed: %d %s", w.Code, w.Body.String())
+	}
+	generated := w.Header().Get(logging.RequestIDHeader)
+	if len(generated) != 36 {
+		t.Errorf("A request ID should be generated, got %q", generated)
+	}
+
+	req, _ := http.NewRequest("GET", "/api/blocks", nil)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user1))
+	req.Header.Set(logging.RequestIDHeader, "edge-1234")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get(logging.RequestIDHeader); got != "edge-1234" {
+		t.Errorf("The caller's request ID should be kept, got %q", got)
+	}
+	req.Header.Set(logging.RequestIDHeader, "forged\nlevel=ERROR")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get(logging.RequestIDHeader); got == "" || strings.Contains(got, "forged") {
+		t.Errorf("A malformed request ID should be replaced, got %q", got)
+	}
+
+	lines := logLines(t, logs, "Request served")
+	if len(lines) != 3 {
+		t.Fatalf("Each request should be logged once, got %d lines:\n%s", len(lines), logs)
+	}
+	like := lines[0]
+	if like["request_id"] != generated || like["user_id"] != user1 || like["route"] != "/api/like" || like["status"] != float64(201) {
+		t.Errorf("The request line should carry the request ID, user and route, got %v", like)
+	}
+	if lines[1]["request_id"] != "edge-1234" {
+		t.Errorf("The request line should carry the caller's request ID, got %v", lines[1])
+	}
+	if strings.Contains(logs.String(), "[DEBUG]") || strings.Contains(logs.String(), "secret") {
+		t.Errorf("Logs should hold neither debug prints nor notes:\n%s", logs)
+	}
+}
+
+func TestLogRedaction(t *testing.T) {
+	logs := captureLogs(t, "debug")
+	slog.Debug("Message held", "content", "call me at 555-0100", "note", "héllo", "match_id", "m1")
+
+	lines := logLines(t, logs, "Message held")
+	if len(lines) != 1 {
+		t.Fatalf("Debug lines should be logged at level debug:\n%s", logs)
+	}
+	if lines[0]["content"] != "[redacted, 19 characters]" || lines[0]["note"] != "[redacted, 5 characters]" {
+		t.Errorf("What users wrote should be redacted, got %v", lines[0])
+	}
+	if lines[0]["match_id"] != "m1" {
+		t.Errorf("Other attributes should be kept, got %v", lines[0])
+	}
+
+	logs.Reset()
+	slog.SetDefault(logging.New(logs, config.LogConfig{Level: "warn", Format: "json"}))
+	slog.Info("Not logged")
+	if logs.Len() != 0 {
+		t.Errorf("Lines below the level should be dropped:\n%s", logs)
+	}
+}
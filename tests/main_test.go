@@ -0,0 +1,12 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"way-d-interactions/testutil"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.Run(m))
+}
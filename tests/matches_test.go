@@ -0,0 +1,90 @@
+// Tests for match metadata.
+
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func getMatches(t *testing.T, r *gin.Engine, userID string) []map[string]interface{} {
+	t.Helper()
+	req, _ := http.NewRequest("GET", "/api/matches", nil)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(userID))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var matches []map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &matches); err != nil {
+		t.Fatalf("Could not decode matches: %s", w.Body.String())
+	}
+	return matches
+}
+
+func TestMatchMetadata(t *testing.T) {
+	r := setupMemoryRouter()
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+
+	for _, like := range []struct {
+		from, to string
+		super    bool
+	}{{user1, user2, true}, {user2, user1, false}} {
+		payload, _ := json.Marshal(map[string]interface{}{"target_id": like.to, "super": like.super})
+		req, _ := http.NewRequest("POST", "/api/like", bytes.NewBuffer(payload))
+		req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(like.from))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Like failed: %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	matches := getMatches(t, r, user2)
+	if len(matches) != 1 {
+		t.Fatalf("Expected one match, got %v", matches)
+	}
+	match := matches[0]
+	if match["initiator_id"] != user1 {
+		t.Errorf("The first liker should be the initiator, got %v", match["initiator_id"])
+	}
+	if match["source"] != "super_like" {
+		t.Errorf("A match completed after a super like should have source super_like, got %v", match["source"])
+	}
+	if match["initiator_liked_at"] == nil || match["responder_liked_at"] == nil {
+		t.Errorf("Both like times should be recorded: %v", match)
+	}
+	if _, ok := match["time_to_match_seconds"].(float64); !ok {
+		t.Errorf("Expected time_to_match_seconds, got %v", match["time_to_match_seconds"])
+	}
+	if match["first_message_at"] != nil {
+		t.Errorf("No message was sent yet, got first_message_at %v", match["first_message_at"])
+	}
+
+	if w := sendMessage(r, user2, match["id"].(string), "Hi!"); w.Code != http.StatusCreated {
+		t.Fatalf("Message failed: %d %s", w.Code, w.Body.String())
+	}
+	first := getMatches(t, r, user1)[0]["first_message_at"]
+	if first == nil {
+		t.Fatal("The first message should set first_message_at")
+	}
+	sendMessage(r, user1, match["id"].(string), "Hello back")
+	if again := getMatches(t, r, user1)[0]["first_message_at"]; again != first {
+		t.Errorf("Later messages should not move first_message_at: %v then %v", first, again)
+	}
+
+	req, _ := http.NewRequest("GET", "/internal/analytics/matches", nil)
+	req.Header.Set("X-Internal-Token", testInternalToken)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var stats []map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &stats)
+	if len(stats) != 1 || stats[0]["source"] != "super_like" || stats[0]["matches"] != 1.0 || stats[0]["with_first_message"] != 1.0 {
+		t.Errorf("Unexpected match analytics: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,56 @@
+// Tests for the Prometheus metrics endpoint.
+
+package tests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"way-d-interactions/controllers"
+	"way-d-interactions/metrics"
+	"way-d-interactions/routes"
+	"way-d-interactions/store"
+
+	"github.com/gin-gonic/gin"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := routes.SetupRouter()
+	routes.RegisterRoutesWith(r, controllers.NewHandler(store.NewMemory()))
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+	likes := promtest.ToFloat64(metrics.Likes.WithLabelValues("false"))
+	matches := promtest.ToFloat64(metrics.MatchesCreated.WithLabelValues("like"))
+
+	doJSON(r, "POST", "/api/like", user1, map[string]string{"target_id": user2})
+	doJSON(r, "POST", "/api/like", user2, map[string]string{"target_id": user1})
+	doJSON(r, "GET", "/api/no/such/route/"+user1, user1, nil)
+
+	if got := promtest.ToFloat64(metrics.Likes.WithLabelValues("false")) - likes; got != 2 {
+		t.Errorf("Two likes should be counted, got %v", got)
+	}
+	if got := promtest.ToFloat64(metrics.MatchesCreated.WithLabelValues("like")) - matches; got != 1 {
+		t.Errorf("One match should be counted, got %v", got)
+	}
+
+	w := doJSON(r, "GET", "/metrics", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Metrics failed: %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`wayd_interactions_http_requests_total{method="POST",route="/api/like",status="201"}`,
+		`wayd_interactions_http_request_duration_seconds_bucket{method="POST",route="/api/like",status="201"`,
+		`route="unmatched"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Metrics should contain %s", want)
+		}
+	}
+	if strings.Contains(body, user1) {
+		t.Error("Request paths must not become label values")
+	}
+}
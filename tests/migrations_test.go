@@ -0,0 +1,182 @@
+// Tests for the versioned SQL migrations and the constraints they add.
+
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"way-d-interactions/migrations"
+	"way-d-interactions/models"
+	"way-d-interactions/store"
+	"way-d-interactions/testutil"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestMigrationFiles(t *testing.T) {
+	all, err := migrations.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range all {
+		if m.Version != i+1 {
+			t.Errorf("Migration versions should be contiguous from 1, found %04d_%s at position %d", m.Version, m.Name, i)
+		}
+	}
+}
+
+func TestMigrationsMatchModels(t *testing.T) {
+	db := testutil.DB(t)
+	for _, model := range models.All() {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+		if !db.Migrator().HasTable(stmt.Schema.Table) {
+			t.Errorf("No migration creates table %s", stmt.Schema.Table)
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !db.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("No migration creates column %s.%s", stmt.Schema.Table, field.DBName)
+			}
+		}
+	}
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	db := testutil.DB(t)
+	m, err := migrations.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if ran, err := m.Up(ctx); err != nil || len(ran) != 0 {
+		t.Fatalf("A migrated schema should have nothing to apply, ran %v: %v", ran, err)
+	}
+	if _, err := m.To(ctx, 0); err != nil {
+		t.Fatalf("Reverting every migration failed: %v", err)
+	}
+	if db.Migrator().HasTable("likes") {
+		t.Error("Reverting the baseline should drop the tables")
+	}
+	if pending, _ := m.Pending(ctx); len(pending) != len(m.Migrations) {
+		t.Errorf("Every migration should be pending after reverting, got %d", len(pending))
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Re-applying the migrations failed: %v", err)
+	}
+	statuses, _ := m.Status(ctx)
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			t.Errorf("Migration %04d_%s should be applied", s.Version, s.Name)
+		}
+	}
+	if _, err := m.To(ctx, 999); err == nil {
+		t.Error("Migrating to an unknown version should fail")
+	}
+}
+
+func TestIntegrityConstraints(t *testing.T) {
+	db := testutil.DB(t)
+	stores := store.NewGorm(db)
+	ctx := context.Background()
+	user, target := uuid.New(), uuid.New()
+
+	if err := stores.Likes.CreateLike(ctx, &models.Like{ID: uuid.New(), UserID: user, TargetID: target}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Likes.CreateLike(ctx, &models.Like{ID: uuid.New(), UserID: user, TargetID: target}); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("The unique index should reject a duplicate like, got %v", err)
+	}
+	if err := stores.Blocks.Create(ctx, &models.Block{ID: uuid.New(), UserID: user, BlockedID: target}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Blocks.Create(ctx, &models.Block{ID: uuid.New(), UserID: user, BlockedID: target}); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("The unique index should reject a duplicate block, got %v", err)
+	}
+	if err := db.Create(&models.Like{ID: uuid.New(), UserID: user, TargetID: user}).Error; err == nil {
+		t.Error("The check constraint should reject a self-like")
+	}
+	if err := db.Create(&models.Block{ID: uuid.New(), UserID: user, BlockedID: user}).Error; err == nil {
+		t.Error("The check constraint should reject a self-block")
+	}
+}
+
+func TestMessageInsertedWithoutHeldIsVisible(t *testing.T) {
+	db := testutil.DB(t)
+	alice, bob := testutil.NewUserID(), testutil.NewUserID()
+	match := testutil.Match(t, db, alice, bob)
+	id := uuid.New()
+	if err := db.Exec("INSERT INTO messages (id, match_id, sender_id, receiver_id, content, created_at) VALUES (?, ?, ?, ?, ?, now())",
+		id, match.ID, alice, bob, "Hello Bob").Error; err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	messages := store.NewGorm(db).Messages
+	conversation, err := messages.ListConversation(ctx, match, uuid.MustParse(bob))
+	if err != nil || len(conversation) != 1 || conversation[0].Held {
+		t.Fatalf("A message stored without the held flag should be visible and not held, got %v: %v", conversation, err)
+	}
+	if _, err := messages.FindVisible(ctx, id, match.ID, uuid.MustParse(bob)); err != nil {
+		t.Errorf("The receiver should see the message: %v", err)
+	}
+}
+
+// firstReleaseSchema is the schema AutoMigrate created for the first release,
+// before versioned migrations.
+const firstReleaseSchema = `
+CREATE TABLE likes (id uuid PRIMARY KEY, user_id uuid NOT NULL, target_id uuid NOT NULL, created_at timestamptz, match boolean);
+CREATE TABLE dislikes (id uuid PRIMARY KEY, user_id uuid NOT NULL, target_id uuid NOT NULL, created_at timestamptz);
+CREATE TABLE matches (id uuid PRIMARY KEY, user1_id uuid NOT NULL, user2_id uuid NOT NULL, created_at timestamptz, expire_at timestamptz);
+CREATE TABLE messages (id uuid PRIMARY KEY, sender_id uuid NOT NULL, receiver_id uuid NOT NULL, content text, created_at timestamptz, seen boolean, deleted boolean);
+CREATE TABLE blocks (id uuid PRIMARY KEY, user_id uuid NOT NULL, blocked_id uuid NOT NULL, reason text, created_at timestamptz);
+`
+
+func TestMigrateFromFirstReleaseSchema(t *testing.T) {
+	db := testutil.DB(t)
+	m, err := migrations.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := m.To(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(firstReleaseSchema).Error; err != nil {
+		t.Fatal(err)
+	}
+	alice, bob := uuid.New(), uuid.New()
+	matchID, messageID := uuid.New(), uuid.New()
+	liked := time.Now().Add(-time.Hour)
+	for _, stmt := range []struct {
+		sql  string
+		args []interface{}
+	}{
+		{"INSERT INTO likes VALUES (?, ?, ?, ?, true)", []interface{}{uuid.New(), alice, bob, liked}},
+		{"INSERT INTO likes VALUES (?, ?, ?, ?, true)", []interface{}{uuid.New(), bob, alice, liked.Add(time.Minute)}},
+		{"INSERT INTO matches VALUES (?, ?, ?, ?, NULL)", []interface{}{matchID, bob, alice, liked.Add(time.Minute)}},
+		{"INSERT INTO messages VALUES (?, ?, ?, 'Hello Bob', ?, false, false)", []interface{}{messageID, alice, bob, liked.Add(2 * time.Minute)}},
+	} {
+		if err := db.Exec(stmt.sql, stmt.args...).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Migrating a first release database failed: %v", err)
+	}
+	stores := store.NewGorm(db)
+	match, err := stores.Matches.Find(ctx, matchID)
+	if err != nil || match.InitiatorID == nil || *match.InitiatorID != alice || match.Source != models.MatchSourceLike || match.FirstMessageAt == nil {
+		t.Errorf("The match metadata should be backfilled, got %+v: %v", match, err)
+	}
+	conversation, err := stores.Messages.ListConversation(ctx, match, bob)
+	if err != nil || len(conversation) != 1 || conversation[0].ID != messageID || conversation[0].Held || conversation[0].MatchID != matchID {
+		t.Errorf("The existing message should stay visible in its match, got %+v: %v", conversation, err)
+	}
+}
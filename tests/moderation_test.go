@@ -0,0 +1,89 @@
+// Tests for the message moderation filters.
+
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"way-d-interactions/moderation"
+
+	"github.com/google/uuid"
+)
+
+func TestModerationChain(t *testing.T) {
+	chain := &moderation.Chain{Filters: []moderation.Filter{
+		moderation.NewWordList("blocked_words", []string{"scam"}, moderation.Reject),
+		&moderation.ContactInfo{Action: moderation.Mask},
+		&moderation.Links{Action: moderation.Hold},
+	}}
+	sender := uuid.New()
+	cases := []struct {
+		content string
+		action  moderation.Action
+	}{
+		{"Hello, how are you?", moderation.Allow},
+		{"Call me on +33 6 12 34 56 78", moderation.Mask},
+		{"Write to me at alice@example.com", moderation.Mask},
+		{"Send it to paypal.me/alice", moderation.Mask},
+		{"Look at https://example.com/offer", moderation.Hold},
+		{"This is not a SCAM I promise", moderation.Reject},
+	}
+	for _, tc := range cases {
+		got := chain.Run(context.Background(), moderation.Input{SenderID: sender, Content: tc.content})
+		if got.Action != tc.action {
+			t.Errorf("%q: expected %s, got %s", tc.content, tc.action, got.Action)
+		}
+	}
+	masked := chain.Run(context.Background(), moderation.Input{SenderID: sender, Content: "Call me on 0612345678"})
+	if strings.Contains(masked.Content, "0612345678") || len(masked.Decisions) != 1 {
+		t.Errorf("Phone number should be masked: %+v", masked)
+	}
+}
+
+func TestRepeatedMessagesFilter(t *testing.T) {
+	filter := moderation.NewRepeatedMessages(2, time.Minute)
+	sender := uuid.New()
+	in := moderation.Input{SenderID: sender, ReceiverID: uuid.New(), Content: "Hey there"}
+	for i := 0; i < 2; i++ {
+		if d := filter.Check(context.Background(), in); d.Action != moderation.Allow {
+			t.Fatalf("Message %d should be allowed, got %s", i+1, d.Action)
+		}
+	}
+	in.ReceiverID = uuid.New()
+	in.Content = "  hey   THERE "
+	if d := filter.Check(context.Background(), in); d.Action != moderation.Reject {
+		t.Errorf("Third identical message should be rejected, got %s", d.Action)
+	}
+	other := moderation.Input{SenderID: uuid.New(), Content: "Hey there"}
+	if d := filter.Check(context.Background(), other); d.Action != moderation.Allow {
+		t.Errorf("Other senders should not be affected, got %s", d.Action)
+	}
+}
+
+func TestHeldMessageHiddenFromReceiver(t *testing.T) {
+	r := setupMemoryRouter()
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+	matchID := createMatch(t, r, user1, user2)
+	w := sendMessage(r, user1, matchID, "Check www.example.com for a surprise")
+	if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"held":true`) {
+		t.Fatalf("Message with a link should be held: %d %s", w.Code, w.Body.String())
+	}
+	for user, want := range map[string]int{user1: 1, user2: 0} {
+		req, _ := http.NewRequest("GET", "/api/messages/"+matchID, nil)
+		req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user))
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var messages []map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &messages)
+		if len(messages) != want {
+			t.Errorf("User %s should see %d messages, got %d", user, want, len(messages))
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Tests for typing indicators and presence.
+
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"way-d-interactions/realtime"
+
+	"github.com/google/uuid"
+)
+
+func TestPresenceTracker(t *testing.T) {
+	p := realtime.NewPresence(50*time.Millisecond, time.Hour)
+	user := uuid.New()
+	if _, ok := p.Status(user); ok {
+		t.Fatal("Unknown user should have no presence")
+	}
+	if !p.Connect(user) {
+		t.Error("First connection should bring the user online")
+	}
+	if p.Connect(user) {
+		t.Error("Second connection should not be reported as coming online")
+	}
+	if p.Disconnect(user) {
+		t.Error("User still has a connection open")
+	}
+	if status, _ := p.Status(user); !status.Online {
+		t.Error("User with an open connection should be online")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if status, _ := p.Status(user); status.Online {
+		t.Error("Connection that was not refreshed within the TTL should not count as online")
+	}
+	p.Touch(user)
+	if status, _ := p.Status(user); !status.Online {
+		t.Error("Touch should refresh the connection")
+	}
+	if !p.Disconnect(user) {
+		t.Error("Closing the last connection should take the user offline")
+	}
+	status, ok := p.Status(user)
+	if !ok || status.Online || status.LastSeen.IsZero() {
+		t.Errorf("Expected an offline user with a last seen time, got %+v", status)
+	}
+}
+
+func TestTypingAndHiddenLastSeen(t *testing.T) {
+	r := setupMemoryRouter()
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+	matchID := createMatch(t, r, user1, user2)
+
+	events, unsubscribe := realtime.Default().Subscribe(uuid.MustParse(user2))
+	defer unsubscribe()
+	req, _ := http.NewRequest("POST", "/api/matches/"+matchID+"/typing", nil)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user1))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Typing failed: %d %s", w.Code, w.Body.String())
+	}
+	select {
+	case event := <-events:
+		if event.Type != "typing" {
+			t.Errorf("Expected a typing event, got %s", event.Type)
+		}
+	case <-time.After(time.Second):
+		t.Error("No typing event delivered")
+	}
+
+	// user1 was online and is now offline with a last seen time.
+	presence := realtime.DefaultPresence()
+	presence.Connect(uuid.MustParse(user1))
+	presence.Disconnect(uuid.MustParse(user1))
+	getPresence := func() map[string]interface{} {
+		req, _ := http.NewRequest("GET", "/api/matches/"+matchID+"/presence", nil)
+		req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user2))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var status map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &status)
+		return status
+	}
+	if status := getPresence(); status["last_seen"] == nil {
+		t.Errorf("Expected a last seen time, got %v", status)
+	}
+	req, _ = http.NewRequest("PUT", "/api/me/settings", bytes.NewBufferString(`{"hide_last_seen": true}`))
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user1))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Updating settings failed: %d %s", w.Code, w.Body.String())
+	}
+	if status := getPresence(); status["last_seen"] != nil {
+		t.Errorf("Last seen should be hidden, got %v", status)
+	}
+
+	blockUser(r, user2, user1)
+	req, _ = http.NewRequest("POST", "/api/matches/"+matchID+"/typing", nil)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user1))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code == http.StatusNoContent {
+		t.Error("Typing should not reach a user who blocked the sender")
+	}
+}
@@ -0,0 +1,154 @@
+// Tests for message reactions and their realtime events.
+
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"way-d-interactions/config"
+	"way-d-interactions/models"
+	"way-d-interactions/realtime"
+	"way-d-interactions/store"
+
+	"github.com/google/uuid"
+)
+
+func postReaction(r http.Handler, userID, messageID, emoji string) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(map[string]string{"emoji": emoji})
+	req, _ := http.NewRequest("POST", "/api/messages/"+messageID+"/reactions", bytes.NewBuffer(payload))
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(userID))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMessageReactions(t *testing.T) {
+	r := setupMemoryRouter()
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+	outsider := "33333333-3333-3333-3333-333333333333"
+	matchID := createMatch(t, r, user1, user2)
+	w := sendMessage(r, user1, matchID, "Fancy a coffee?")
+	var msg map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &msg)
+	messageID := msg["id"].(string)
+
+	events, unsubscribe := realtime.Default().Subscribe(uuid.MustParse(user1))
+	defer unsubscribe()
+
+	if w := postReaction(r, user2, messageID, "🍕"); w.Code != http.StatusBadRequest {
+		t.Errorf("Emoji outside the allowed set should be rejected: %d", w.Code)
+	}
+	if w := postReaction(r, outsider, messageID, "👍"); w.Code != http.StatusNotFound {
+		t.Errorf("Non-participants must not react: %d", w.Code)
+	}
+	if w := postReaction(r, user2, messageID, "👍"); w.Code != http.StatusCreated {
+		t.Fatalf("Reaction failed: %d %s", w.Code, w.Body.String())
+	}
+	// Reacting again replaces the previous reaction.
+	if w := postReaction(r, user2, messageID, "❤️"); w.Code != http.StatusOK {
+		t.Errorf("Replacing reaction failed: %d %s", w.Code, w.Body.String())
+	}
+	select {
+	case event := <-events:
+		if event.Type != "reaction.added" {
+			t.Errorf("Unexpected event %s", event.Type)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Sender did not receive the reaction event")
+	}
+
+	req, _ := http.NewRequest("GET", "/api/messages/"+matchID, nil)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user2))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var messages []struct {
+		Reactions []struct {
+			Emoji       string `json:"emoji"`
+			Count       int    `json:"count"`
+			ReactedByMe bool   `json:"reacted_by_me"`
+		} `json:"reactions"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &messages)
+	if len(messages) != 1 || len(messages[0].Reactions) != 1 || messages[0].Reactions[0].Count != 1 || !messages[0].Reactions[0].ReactedByMe {
+		t.Errorf("Expected one reaction by the caller, got %s", w.Body.String())
+	}
+
+	req, _ = http.NewRequest("DELETE", "/api/messages/"+messageID+"/reactions", nil)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user2))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Removing reaction failed: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestReactionWriteFailure(t *testing.T) {
+	setupTestDB(t)
+	r := setupRouter()
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+	matchID := createMatch(t, r, user1, user2)
+	var msg map[string]interface{}
+	_ = json.Unmarshal(sendMessage(r, user1, matchID, "Fancy a coffee?").Body.Bytes(), &msg)
+
+	events, unsubscribe := realtime.Default().Subscribe(uuid.MustParse(user1))
+	defer unsubscribe()
+	if err := config.GetDB().Exec("ALTER TABLE reactions ADD CONSTRAINT no_reactions CHECK (false)").Error; err != nil {
+		t.Fatal(err)
+	}
+	if w := postReaction(r, user2, msg["id"].(string), "👍"); w.Code != http.StatusInternalServerError {
+		t.Errorf("A failed write should be reported: %d %s", w.Code, w.Body.String())
+	}
+	select {
+	case event := <-events:
+		t.Errorf("No event should be published for a failed write, got %s", event.Type)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// racedReactions is a reaction store whose first lookup misses, as when a
+// concurrent request creates the reaction between the lookup and the insert.
+type racedReactions struct {
+	store.ReactionStore
+	raced *bool
+}
+
+func (s racedReactions) Find(ctx context.Context, messageID, userID uuid.UUID) (models.Reaction, error) {
+	if !*s.raced {
+		*s.raced = true
+		return models.Reaction{}, store.ErrNotFound
+	}
+	return s.ReactionStore.Find(ctx, messageID, userID)
+}
+
+func TestConcurrentReactionReplacesTheFirst(t *testing.T) {
+	stores := store.NewMemory()
+	r := setupRouterWith(stores)
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+	matchID := createMatch(t, r, user1, user2)
+	var msg models.Message
+	_ = json.Unmarshal(sendMessage(r, user1, matchID, "Fancy a coffee?").Body.Bytes(), &msg)
+	if w := postReaction(r, user2, msg.ID.String(), "👍"); w.Code != http.StatusCreated {
+		t.Fatalf("Reaction failed: %d %s", w.Code, w.Body.String())
+	}
+	stores.Reactions = racedReactions{stores.Reactions, new(bool)}
+	r = setupRouterWith(stores)
+
+	w := postReaction(r, user2, msg.ID.String(), "🔥")
+	if w.Code != http.StatusOK {
+		t.Fatalf("A reaction created concurrently should be replaced: %d %s", w.Code, w.Body.String())
+	}
+	reaction, err := stores.Reactions.Find(context.Background(), msg.ID, uuid.MustParse(user2))
+	if err != nil || reaction.Emoji != "🔥" {
+		t.Errorf("Expected the reaction to be replaced, got %+v %v", reaction, err)
+	}
+}
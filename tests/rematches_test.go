@@ -0,0 +1,176 @@
+// Tests for match extensions and rematch requests.
+
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"way-d-interactions/config"
+	"way-d-interactions/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func postAs(r *gin.Engine, userID, path string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", path, nil)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(userID))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMatchExtensionQuota(t *testing.T) {
+	setupTestDB(t)
+	r := setupRouter()
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+	matchID := createMatch(t, r, user1, user2)
+	expireAt := time.Now().Add(time.Hour)
+	config.GetDB().Model(&models.Match{}).Where("id = ?", matchID).Update("expire_at", expireAt)
+
+	w := postAs(r, user1, "/api/matches/"+matchID+"/extend")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Extension failed: %d %s", w.Code, w.Body.String())
+	}
+	var match models.Match
+	_ = json.Unmarshal(w.Body.Bytes(), &match)
+	if match.ExpireAt == nil || !match.ExpireAt.After(expireAt.Add(23*time.Hour)) {
+		t.Errorf("Expected the expiry to move by a day, got %v", match.ExpireAt)
+	}
+	if w := postAs(r, user1, "/api/matches/"+matchID+"/extend"); w.Code != http.StatusConflict {
+		t.Errorf("Second extension by the same user should exceed the quota: %d", w.Code)
+	}
+	if w := postAs(r, user2, "/api/matches/"+matchID+"/extend"); w.Code != http.StatusOK {
+		t.Errorf("The other participant has their own quota: %d", w.Code)
+	}
+}
+
+func TestRematchFlow(t *testing.T) {
+	setupTestDB(t)
+	r := setupRouter()
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+	matchID := createMatch(t, r, user1, user2)
+
+	if w := postAs(r, user1, "/api/matches/"+matchID+"/rematch"); w.Code != http.StatusConflict {
+		t.Errorf("A live match cannot be rematched: %d", w.Code)
+	}
+	config.GetDB().Model(&models.Match{}).Where("id = ?", matchID).Update("expire_at", time.Now().Add(-time.Hour))
+	w := postAs(r, user1, "/api/matches/"+matchID+"/rematch")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Rematch request failed: %d %s", w.Code, w.Body.String())
+	}
+	var request models.RematchRequest
+	_ = json.Unmarshal(w.Body.Bytes(), &request)
+
+	if w := postAs(r, user1, "/api/rematches/"+request.ID.String()+"/accept"); w.Code != http.StatusNotFound {
+		t.Errorf("The requester cannot accept their own request: %d", w.Code)
+	}
+	w = postAs(r, user2, "/api/rematches/"+request.ID.String()+"/accept")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Accepting failed: %d %s", w.Code, w.Body.String())
+	}
+	var revived models.Match
+	_ = json.Unmarshal(w.Body.Bytes(), &revived)
+	if revived.PreviousMatchID == nil || revived.PreviousMatchID.String() != matchID {
+		t.Errorf("The new match should point to the expired one, got %v", revived.PreviousMatchID)
+	}
+	if w := sendMessage(r, user2, revived.ID.String(), "Glad we got a second chance"); w.Code != http.StatusCreated {
+		t.Errorf("Messaging the revived match failed: %d %s", w.Code, w.Body.String())
+	}
+	if w := postAs(r, user2, "/api/matches/"+matchID+"/rematch"); w.Code != http.StatusConflict {
+		t.Errorf("Rematching an already revived match should conflict: %d", w.Code)
+	}
+}
+
+func TestRematchStartsNewConversation(t *testing.T) {
+	setupTestDB(t)
+	r := setupRouter()
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+	matchID := createMatch(t, r, user1, user2)
+	w := sendMessage(r, user1, matchID, "Before")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Sending failed: %d %s", w.Code, w.Body.String())
+	}
+	var before models.Message
+	_ = json.Unmarshal(w.Body.Bytes(), &before)
+	config.GetDB().Model(&models.Match{}).Where("id = ?", matchID).Update("expire_at", time.Now().Add(-time.Hour))
+	var request models.RematchRequest
+	_ = json.Unmarshal(postAs(r, user1, "/api/matches/"+matchID+"/rematch").Body.Bytes(), &request)
+	var revived models.Match
+	_ = json.Unmarshal(postAs(r, user2, "/api/rematches/"+request.ID.String()+"/accept").Body.Bytes(), &revived)
+	if w := sendMessage(r, user2, revived.ID.String(), "After"); w.Code != http.StatusCreated {
+		t.Fatalf("Sending to the revived match failed: %d %s", w.Code, w.Body.String())
+	}
+	if w := postReaction(r, user2, before.ID.String(), "👍"); w.Code != http.StatusNotFound {
+		t.Errorf("Messages of the expired match should not take reactions: %d %s", w.Code, w.Body.String())
+	}
+
+	for id, want := range map[string]string{matchID: "Before", revived.ID.String(): "After"} {
+		req, _ := http.NewRequest("GET", "/api/messages/"+id, nil)
+		req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user1))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var messages []models.Message
+		_ = json.Unmarshal(w.Body.Bytes(), &messages)
+		if len(messages) != 1 || messages[0].Content != want || messages[0].MatchID.String() != id {
+			t.Errorf("Match %s should only hold %q, got %d %s", id, want, w.Code, w.Body.String())
+		}
+	}
+}
+
+// withConfig applies change to a copy of the configuration for the rest of
+// the test.
+func withConfig(t *testing.T, change func(*config.Config)) {
+	previous := config.Get()
+	cfg := *previous
+	change(&cfg)
+	config.Set(&cfg)
+	t.Cleanup(func() { config.Set(previous) })
+}
+
+func TestMatchLifetimeThroughTheAPI(t *testing.T) {
+	withConfig(t, func(cfg *config.Config) {
+		cfg.Matches.TTL = 200 * time.Millisecond
+		cfg.Matches.ExtensionDuration = 200 * time.Millisecond
+	})
+	r := setupMemoryRouter()
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+	matchID := createMatch(t, r, user1, user2)
+
+	w := postAs(r, user1, "/api/matches/"+matchID+"/extend")
+	if w.Code != http.StatusOK {
+		t.Fatalf("A match created with MATCH_TTL should be extendable: %d %s", w.Code, w.Body.String())
+	}
+	if w := postAs(r, user1, "/api/matches/"+matchID+"/rematch"); w.Code != http.StatusConflict {
+		t.Errorf("A live match cannot be rematched: %d", w.Code)
+	}
+	var extended models.Match
+	_ = json.Unmarshal(w.Body.Bytes(), &extended)
+	time.Sleep(time.Until(*extended.ExpireAt) + 50*time.Millisecond)
+
+	if w := sendMessage(r, user1, matchID, "Still there?"); w.Code == http.StatusCreated {
+		t.Error("An expired match should not take messages")
+	}
+	w = postAs(r, user1, "/api/matches/"+matchID+"/rematch")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Rematch request failed: %d %s", w.Code, w.Body.String())
+	}
+	var request models.RematchRequest
+	_ = json.Unmarshal(w.Body.Bytes(), &request)
+	w = postAs(r, user2, "/api/rematches/"+request.ID.String()+"/accept")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Accepting failed: %d %s", w.Code, w.Body.String())
+	}
+	var revived models.Match
+	_ = json.Unmarshal(w.Body.Bytes(), &revived)
+	if revived.ExpireAt == nil || !revived.ExpireAt.After(revived.CreatedAt) {
+		t.Errorf("The new match should last MATCH_TTL, got %v", revived.ExpireAt)
+	}
+}
@@ -0,0 +1,63 @@
+// Tests for replying to earlier messages.
+
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReplyPreviewAndTombstone(t *testing.T) {
+	r := setupMemoryRouter()
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+	matchID := createMatch(t, r, user1, user2)
+	w := sendMessage(r, user1, matchID, "Do you like hiking?")
+	var quoted map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &quoted)
+	quotedID := quoted["id"].(string)
+
+	reply := func(replyTo string) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(map[string]string{"match_id": matchID, "content": "Yes, a lot!", "reply_to_id": replyTo})
+		req, _ := http.NewRequest("POST", "/api/message", bytes.NewBuffer(payload))
+		req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user2))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	if w := reply("22222222-2222-2222-2222-222222222222"); w.Code != http.StatusBadRequest {
+		t.Errorf("Replying to a message outside the match should fail: %d", w.Code)
+	}
+	if w := reply(quotedID); w.Code != http.StatusCreated {
+		t.Fatalf("Reply failed: %d %s", w.Code, w.Body.String())
+	}
+
+	getReplyPreview := func() map[string]interface{} {
+		req, _ := http.NewRequest("GET", "/api/messages/"+matchID, nil)
+		req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user2))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var messages []map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &messages)
+		for _, m := range messages {
+			if preview, ok := m["reply_to"].(map[string]interface{}); ok {
+				return preview
+			}
+		}
+		return nil
+	}
+	if preview := getReplyPreview(); preview == nil || preview["content"] != "Do you like hiking?" {
+		t.Errorf("Expected a preview of the quoted message, got %v", preview)
+	}
+
+	req, _ := http.NewRequest("DELETE", "/api/messages/"+quotedID, nil)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user1))
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if preview := getReplyPreview(); preview == nil || preview["deleted"] != true || preview["content"] != nil {
+		t.Errorf("Expected a tombstone for the deleted message, got %v", preview)
+	}
+}
@@ -0,0 +1,62 @@
+// Tests for the message retention purge.
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"way-d-interactions/config"
+	"way-d-interactions/models"
+	"way-d-interactions/workers"
+
+	"github.com/google/uuid"
+)
+
+func TestRetentionPurge(t *testing.T) {
+	setupTestDB(t)
+	db := config.GetDB()
+	user1 := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	user2 := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	old := time.Now().Add(-200 * 24 * time.Hour)
+	newMsg := func(deleted bool) models.Message {
+		m := models.Message{ID: uuid.New(), SenderID: user1, ReceiverID: user2, Content: "hi", CreatedAt: old, Deleted: deleted}
+		if deleted {
+			m.DeletedAt = &old
+		}
+		db.Create(&m)
+		return m
+	}
+	newMsg(true)
+	reported := newMsg(true)
+	newMsg(false)
+	db.Create(&models.Report{ID: uuid.New(), MessageID: reported.ID, ReporterID: user2, Reason: "spam", Status: models.ReportStatusOpen, CreatedAt: old})
+
+	policy := workers.RetentionPolicy{
+		DeletedMessagesAfter:        30 * 24 * time.Hour,
+		UnmatchedConversationsAfter: 90 * 24 * time.Hour,
+		BatchSize:                   1,
+		DryRun:                      true,
+	}
+	purger := &workers.Purger{DB: db, Policy: policy}
+	report, err := purger.Purge(context.Background())
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	var count int64
+	db.Model(&models.Message{}).Count(&count)
+	if count != 3 || report.DeletedMessages != 1 {
+		t.Errorf("Dry run should only count: %d messages left, report %+v", count, report)
+	}
+
+	purger.Policy.DryRun = false
+	if _, err := purger.Purge(context.Background()); err != nil {
+		t.Fatalf("Purge failed: %v", err)
+	}
+	var left []models.Message
+	db.Find(&left)
+	if len(left) != 1 || left[0].ID != reported.ID {
+		t.Errorf("Only the reported message should survive, got %d messages", len(left))
+	}
+}
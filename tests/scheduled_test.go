@@ -0,0 +1,139 @@
+// Tests for scheduled messages.
+
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"way-d-interactions/config"
+	"way-d-interactions/controllers"
+	"way-d-interactions/models"
+	"way-d-interactions/store"
+	"way-d-interactions/workers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func scheduleMessage(r *gin.Engine, userID, matchID, content string, sendAt time.Time) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(map[string]string{"match_id": matchID, "content": content, "send_at": sendAt.Format(time.RFC3339)})
+	req, _ := http.NewRequest("POST", "/api/message", bytes.NewBuffer(payload))
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(userID))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestScheduledMessageDelivery(t *testing.T) {
+	setupTestDB(t)
+	r := setupRouter()
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+	user3 := "22222222-2222-2222-2222-222222222222"
+	match12 := createMatch(t, r, user1, user2)
+	match13 := createMatch(t, r, user1, user3)
+
+	if w := scheduleMessage(r, user1, match12, "Too late", time.Now().Add(-time.Hour)); w.Code != http.StatusBadRequest {
+		t.Errorf("A send_at in the past should be rejected: %d", w.Code)
+	}
+	if w := scheduleMessage(r, user1, match12, "Happy birthday!", time.Now().Add(time.Hour)); w.Code != http.StatusAccepted {
+		t.Fatalf("Scheduling failed: %d %s", w.Code, w.Body.String())
+	}
+	if w := scheduleMessage(r, user1, match13, "See you tomorrow", time.Now().Add(time.Hour)); w.Code != http.StatusAccepted {
+		t.Fatalf("Scheduling failed: %d %s", w.Code, w.Body.String())
+	}
+	req, _ := http.NewRequest("GET", "/api/messages/scheduled", nil)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user1))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var pending []models.ScheduledMessage
+	_ = json.Unmarshal(w.Body.Bytes(), &pending)
+	if len(pending) != 2 {
+		t.Fatalf("Expected 2 pending scheduled messages, got %d", len(pending))
+	}
+
+	// Make both due, and let user3 block user1 before delivery.
+	db := config.GetDB()
+	db.Model(&models.ScheduledMessage{}).Where("1 = 1").Update("send_at", time.Now().Add(-time.Minute))
+	blockUser(r, user3, user1)
+	deliver := func(ctx context.Context, tx *gorm.DB, scheduled models.ScheduledMessage) (uuid.UUID, string, error) {
+		return controllers.NewHandler(store.NewGorm(tx)).DeliverScheduledMessage(ctx, scheduled)
+	}
+	dispatcher := &workers.ScheduledDispatcher{DB: db, Deliver: deliver, BatchSize: 10}
+	sent, failed, err := dispatcher.Dispatch(context.Background())
+	if err != nil || sent != 1 || failed != 1 {
+		t.Fatalf("Expected 1 sent and 1 failed, got %d, %d (%v)", sent, failed, err)
+	}
+	var delivered int64
+	db.Model(&models.Message{}).Where("sender_id = ? AND receiver_id = ? AND content = ?", user1, user2, "Happy birthday!").Count(&delivered)
+	if delivered != 1 {
+		t.Error("Scheduled message was not delivered")
+	}
+	var failedMsg models.ScheduledMessage
+	if err := db.Where("status = ?", models.ScheduledStatusFailed).First(&failedMsg).Error; err != nil || failedMsg.FailureReason == "" {
+		t.Errorf("Expected the message to the blocking user to fail with a reason, got %+v", failedMsg)
+	}
+
+	req, _ = http.NewRequest("DELETE", "/api/messages/scheduled/"+failedMsg.ID.String(), nil)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user1))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Dismissing a failed scheduled message failed: %d", w.Code)
+	}
+}
+
+func TestScheduledMessageRetriesTransientFailures(t *testing.T) {
+	setupTestDB(t)
+	r := setupRouter()
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+	matchID := createMatch(t, r, user1, user2)
+	scheduleMessage(r, user1, matchID, "Unlucky", time.Now().Add(time.Hour))
+	scheduleMessage(r, user1, matchID, "Lucky", time.Now().Add(2*time.Hour))
+	db := config.GetDB()
+	db.Model(&models.ScheduledMessage{}).Where("content = ?", "Unlucky").Update("send_at", time.Now().Add(-2*time.Minute))
+	db.Model(&models.ScheduledMessage{}).Where("content = ?", "Lucky").Update("send_at", time.Now().Add(-time.Minute))
+
+	tries := 0
+	deliver := func(ctx context.Context, tx *gorm.DB, scheduled models.ScheduledMessage) (uuid.UUID, string, error) {
+		if scheduled.Content == "Unlucky" {
+			tries++
+			return uuid.Nil, "", errors.New("connection reset")
+		}
+		return controllers.NewHandler(store.NewGorm(tx)).DeliverScheduledMessage(ctx, scheduled)
+	}
+	dispatcher := &workers.ScheduledDispatcher{DB: db, Deliver: deliver, BatchSize: 10, RetryDelay: time.Hour, MaxAttempts: 2}
+	sent, failed, err := dispatcher.Dispatch(context.Background())
+	if err != nil || sent != 1 || failed != 0 || tries != 1 {
+		t.Fatalf("Expected the failing message to be tried once and the next one sent, got %d sent, %d failed, %d tries (%v)", sent, failed, tries, err)
+	}
+	var unlucky models.ScheduledMessage
+	db.Where("content = ?", "Unlucky").First(&unlucky)
+	if unlucky.Status != models.ScheduledStatusPending || unlucky.Attempts != 1 || unlucky.NextAttemptAt == nil || !unlucky.NextAttemptAt.After(time.Now()) {
+		t.Fatalf("Expected a pending message backing off after 1 attempt, got %+v", unlucky)
+	}
+
+	if sent, failed, _ := dispatcher.Dispatch(context.Background()); sent != 0 || failed != 0 || tries != 1 {
+		t.Errorf("A message backing off should be skipped, got %d sent, %d failed, %d tries", sent, failed, tries)
+	}
+
+	db.Model(&unlucky).Update("next_attempt_at", time.Now().Add(-time.Second))
+	if _, failed, err := dispatcher.Dispatch(context.Background()); err != nil || failed != 1 || tries != 2 {
+		t.Fatalf("The last attempt should mark the message failed, got %d failed, %d tries (%v)", failed, tries, err)
+	}
+	db.Where("id = ?", unlucky.ID).First(&unlucky)
+	if unlucky.Status != models.ScheduledStatusFailed || !strings.Contains(unlucky.FailureReason, "connection reset") {
+		t.Errorf("Expected the message failed with the last error, got %+v", unlucky)
+	}
+}
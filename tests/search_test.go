@@ -0,0 +1,77 @@
+// Tests for message search.
+
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"way-d-interactions/config"
+	"way-d-interactions/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func searchMessages(r *gin.Engine, userID, query string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/api/messages/search?q="+url.QueryEscape(query), nil)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(userID))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestSearchMessagesScope(t *testing.T) {
+	setupTestDB(t)
+	r := setupRouter()
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+	user3 := "22222222-2222-2222-2222-222222222222"
+	match12 := createMatch(t, r, user1, user2)
+	match13 := createMatch(t, r, user1, user3)
+	sendMessage(r, user1, match12, "Shall we go to the <museum> on Sunday?")
+	sendMessage(r, user3, match13, "The museum was closed yesterday")
+	sendMessage(r, user2, match12, "Sounds good")
+
+	w := searchMessages(r, user2, "museum")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Search failed: %d %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Results []map[string]interface{} `json:"results"`
+		HasMore bool                     `json:"has_more"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Results) != 1 || resp.Results[0]["match_id"] != match12 {
+		t.Fatalf("Expected only the message from user2's match, got %v", resp.Results)
+	}
+	snippet, _ := resp.Results[0]["snippet"].(string)
+	if !strings.Contains(snippet, "<mark>museum</mark>") || strings.Contains(snippet, "<museum>") {
+		t.Errorf("Expected an escaped, highlighted snippet, got %q", snippet)
+	}
+
+	_ = json.Unmarshal(searchMessages(r, user1, "museum").Body.Bytes(), &resp)
+	if len(resp.Results) != 2 {
+		t.Errorf("Expected results from both of user1's matches, got %d", len(resp.Results))
+	}
+
+	blockUser(r, user1, user3)
+	_ = json.Unmarshal(searchMessages(r, user1, "museum").Body.Bytes(), &resp)
+	if len(resp.Results) != 1 {
+		t.Errorf("Blocked conversations should not be searchable, got %d results", len(resp.Results))
+	}
+
+	config.GetDB().Model(&models.Match{}).Where("id = ?", match12).Update("expire_at", time.Now().Add(-time.Hour))
+	_ = json.Unmarshal(searchMessages(r, user1, "museum").Body.Bytes(), &resp)
+	if len(resp.Results) != 0 {
+		t.Errorf("Expired conversations should not be searchable, got %d results", len(resp.Results))
+	}
+
+	if w := searchMessages(r, user1, "   "); w.Code != http.StatusBadRequest {
+		t.Errorf("Empty query should be rejected: %d", w.Code)
+	}
+}
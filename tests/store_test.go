@@ -0,0 +1,237 @@
+// Tests for the like, match, conversation and block endpoints on in-memory
+// stores. They need no database.
+
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"way-d-interactions/controllers"
+	"way-d-interactions/models"
+	"way-d-interactions/routes"
+	"way-d-interactions/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func setupMemoryRouter() *gin.Engine {
+	return setupRouterWith(store.NewMemory())
+}
+
+// setupRouterWith serves the API from stores, for tests that fill them
+// beforehand.
+func setupRouterWith(stores store.Stores) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	routes.RegisterRoutesWith(r, controllers.NewHandler(stores))
+	return r
+}
+
+func doJSON(r *gin.Engine, method, path, userID string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&buf).Encode(body)
+	}
+	req, _ := http.NewRequest(method, path, &buf)
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(userID))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMemoryStoreLikeMatchBlock(t *testing.T) {
+	r := setupMemoryRouter()
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+
+	if w := doJSON(r, "POST", "/api/like", user1, map[string]string{"target_id": user2, "note": "Hi there"}); w.Code != http.StatusCreated {
+		t.Fatalf("Like failed: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "POST", "/api/like", user1, map[string]string{"target_id": user2}); w.Code != http.StatusConflict {
+		t.Errorf("A second like should conflict, got %d", w.Code)
+	}
+	if w := doJSON(r, "POST", "/api/dislike", user1, map[string]string{"target_id": user2}); w.Code != http.StatusConflict {
+		t.Errorf("Disliking a liked user should conflict, got %d", w.Code)
+	}
+	if w := doJSON(r, "POST", "/api/like", user2, map[string]string{"target_id": user1}); w.Code != http.StatusCreated {
+		t.Fatalf("Like back failed: %d %s", w.Code, w.Body.String())
+	}
+
+	var matches []map[string]interface{}
+	_ = json.Unmarshal(doJSON(r, "GET", "/api/matches", user1, nil).Body.Bytes(), &matches)
+	if len(matches) != 1 || matches[0]["initiator_id"] != user1 || matches[0]["first_message_at"] == nil {
+		t.Fatalf("Expected one match initiated by user1 and opened by the note, got %v", matches)
+	}
+	matchID := matches[0]["id"].(string)
+
+	var messages []map[string]interface{}
+	_ = json.Unmarshal(doJSON(r, "GET", "/api/messages/"+matchID, user2, nil).Body.Bytes(), &messages)
+	if len(messages) != 1 || messages[0]["content"] != "Hi there" {
+		t.Fatalf("Expected the note as first message, got %v", messages)
+	}
+	if w := doJSON(r, "DELETE", "/api/messages/"+messages[0]["id"].(string), user2, nil); w.Code != http.StatusNotFound {
+		t.Errorf("Only the sender may delete a message, got %d", w.Code)
+	}
+	if w := doJSON(r, "DELETE", "/api/messages/"+messages[0]["id"].(string), user1, nil); w.Code != http.StatusOK {
+		t.Errorf("Deleting own message failed: %d %s", w.Code, w.Body.String())
+	}
+	_ = json.Unmarshal(doJSON(r, "GET", "/api/messages/"+matchID, user2, nil).Body.Bytes(), &messages)
+	if len(messages) != 0 {
+		t.Errorf("Deleted messages should be hidden, got %v", messages)
+	}
+
+	if w := doJSON(r, "POST", "/api/block", user2, map[string]string{"blocked_id": user1}); w.Code != http.StatusCreated {
+		t.Fatalf("Block failed: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "POST", "/api/block", user2, map[string]string{"blocked_id": user1}); w.Code != http.StatusConflict {
+		t.Errorf("A second block should conflict, got %d", w.Code)
+	}
+	_ = json.Unmarshal(doJSON(r, "GET", "/api/matches", user1, nil).Body.Bytes(), &matches)
+	if len(matches) != 0 {
+		t.Errorf("Blocking should remove the match, got %v", matches)
+	}
+	if w := doJSON(r, "GET", "/api/messages/"+matchID, user1, nil); w.Code != http.StatusForbidden {
+		t.Errorf("The conversation should be gone after a block, got %d", w.Code)
+	}
+	if w := doJSON(r, "POST", "/api/like", user1, map[string]string{"target_id": user2}); w.Code != http.StatusForbidden {
+		t.Errorf("Liking a user who blocked you should be forbidden, got %d", w.Code)
+	}
+	var blocks []map[string]interface{}
+	_ = json.Unmarshal(doJSON(r, "GET", "/api/blocks", user2, nil).Body.Bytes(), &blocks)
+	if len(blocks) != 1 || blocks[0]["blocked_id"] != user1 {
+		t.Errorf("Expected user2's block in the list, got %v", blocks)
+	}
+}
+
+func TestMemoryStoreRejectsDuplicates(t *testing.T) {
+	stores := store.NewMemory()
+	ctx := context.Background()
+	user, target := uuid.New(), uuid.New()
+
+	if err := stores.Likes.CreateLike(ctx, &models.Like{ID: uuid.New(), UserID: user, TargetID: target}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Likes.CreateLike(ctx, &models.Like{ID: uuid.New(), UserID: user, TargetID: target}); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("A duplicate like should conflict, got %v", err)
+	}
+	if err := stores.Blocks.Create(ctx, &models.Block{ID: uuid.New(), UserID: user, BlockedID: target}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Blocks.Create(ctx, &models.Block{ID: uuid.New(), UserID: user, BlockedID: target}); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("A duplicate block should conflict, got %v", err)
+	}
+}
+
+// failingLikes is a like store whose pair cleanup fails.
+type failingLikes struct{ store.LikeStore }
+
+func (failingLikes) DeletePair(context.Context, uuid.UUID, uuid.UUID) error {
+	return errors.New("disk full")
+}
+
+func TestBlockIsRolledBackWhenCleanupFails(t *testing.T) {
+	stores := store.NewMemory()
+	r := setupRouterWith(stores)
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+	createMatch(t, r, user1, user2)
+	stores.Likes = failingLikes{stores.Likes}
+	r = setupRouterWith(stores)
+
+	if w := blockUser(r, user1, user2); w.Code != http.StatusInternalServerError {
+		t.Fatalf("A failed cleanup should fail the block: %d %s", w.Code, w.Body.String())
+	}
+	ctx := context.Background()
+	if _, err := stores.Blocks.Find(ctx, uuid.MustParse(user1), uuid.MustParse(user2)); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("The block should not be kept, got %v", err)
+	}
+	if matches, _ := stores.Matches.ListBetween(ctx, uuid.MustParse(user1), uuid.MustParse(user2)); len(matches) != 1 {
+		t.Errorf("The match should be kept, got %v", matches)
+	}
+}
+
+// failingBlocks is a block store whose lookups fail.
+type failingBlocks struct{ store.BlockStore }
+
+func (failingBlocks) Blocked(context.Context, uuid.UUID, uuid.UUID) (bool, error) {
+	return false, errors.New("connection reset")
+}
+
+func TestDislikeFailsWhenBlocksCannotBeChecked(t *testing.T) {
+	stores := store.NewMemory()
+	stores.Blocks = failingBlocks{stores.Blocks}
+	r := setupRouterWith(stores)
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+
+	if w := doJSON(r, "POST", "/api/dislike", user1, map[string]string{"target_id": user2}); w.Code != http.StatusInternalServerError {
+		t.Fatalf("An unknown block status should fail the dislike: %d %s", w.Code, w.Body.String())
+	}
+	if _, err := stores.Likes.FindDislike(context.Background(), uuid.MustParse(user1), uuid.MustParse(user2)); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("The dislike should not be stored, got %v", err)
+	}
+}
+
+func TestMemoryStoreDeleteForMatches(t *testing.T) {
+	stores := store.NewMemory()
+	ctx := context.Background()
+	user1, user2 := uuid.New(), uuid.New()
+	expired := models.Match{ID: uuid.New(), User1ID: user1, User2ID: user2}
+	revived := models.Match{ID: uuid.New(), User1ID: user1, User2ID: user2, PreviousMatchID: &expired.ID}
+	for _, match := range []models.Match{expired, revived} {
+		_ = stores.Matches.Create(ctx, &match)
+		_ = stores.Messages.Create(ctx, &models.Message{ID: uuid.New(), MatchID: match.ID, SenderID: user1, ReceiverID: user2, Content: "Hi"})
+	}
+
+	if err := stores.Messages.DeleteForMatches(ctx, []uuid.UUID{expired.ID}); err != nil {
+		t.Fatalf("Deleting failed: %v", err)
+	}
+	if messages, _ := stores.Messages.ListConversation(ctx, expired, user1); len(messages) != 0 {
+		t.Errorf("The messages of the deleted match should be gone, got %v", messages)
+	}
+	if messages, _ := stores.Messages.ListConversation(ctx, revived, user1); len(messages) != 1 {
+		t.Errorf("Other matches between the pair should keep their messages, got %v", messages)
+	}
+	if matches, _ := stores.Matches.ListBetween(ctx, user2, user1); len(matches) != 2 {
+		t.Errorf("Expected both matches between the pair, got %v", matches)
+	}
+}
+
+// failingMatches is a match store whose writes fail.
+type failingMatches struct{ store.MatchStore }
+
+func (failingMatches) Create(context.Context, *models.Match) error {
+	return errors.New("disk full")
+}
+
+func TestLikeIsRolledBackWhenMatchFails(t *testing.T) {
+	stores := store.NewMemory()
+	stores.Matches = failingMatches{stores.Matches}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	routes.RegisterRoutesWith(r, controllers.NewHandler(stores))
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+
+	if w := doJSON(r, "POST", "/api/like", user1, map[string]string{"target_id": user2}); w.Code != http.StatusCreated {
+		t.Fatalf("Like failed: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "POST", "/api/like", user2, map[string]string{"target_id": user1}); w.Code != http.StatusInternalServerError {
+		t.Fatalf("A failed match should fail the like: %d %s", w.Code, w.Body.String())
+	}
+	ctx := context.Background()
+	if _, err := stores.Likes.FindLike(ctx, uuid.MustParse(user2), uuid.MustParse(user1)); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("The like back should not be kept, got %v", err)
+	}
+	if like, _ := stores.Likes.FindLike(ctx, uuid.MustParse(user1), uuid.MustParse(user2)); like.Match {
+		t.Error("The first like should not be marked as matched")
+	}
+}
@@ -0,0 +1,120 @@
+// Tests for request and query tracing.
+
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"way-d-interactions/controllers"
+	"way-d-interactions/models"
+	"way-d-interactions/routes"
+	"way-d-interactions/store"
+	"way-d-interactions/testutil"
+	"way-d-interactions/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a tracer provider recording every span for the rest
+// of the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		provider.Shutdown(context.Background())
+	})
+	return recorder
+}
+
+func TestTracing(t *testing.T) {
+	recorder := recordSpans(t)
+	gin.SetMode(gin.TestMode)
+	r := routes.SetupRouter()
+	routes.RegisterRoutesWith(r, controllers.NewHandler(store.NewMemory()))
+	user1 := "00000000-0000-0000-0000-000000000001"
+	user2 := "11111111-1111-1111-1111-111111111111"
+	doJSON(r, "POST", "/api/like", user1, map[string]string{"target_id": user2})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, _ := http.NewRequest("POST", "/api/like", strings.NewReader(`{"target_id":"`+user2+`"}`))
+	req.Header.Set("Authorization", "Bearer "+GenerateTestJWT(user1))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("A second like should conflict, got %d", w.Code)
+	}
+	if got := w.Header().Get(tracing.TraceIDHeader); got != traceID {
+		t.Errorf("The response should carry the caller's trace ID, got %q", got)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("The error body should stay valid JSON: %v (%s)", err, w.Body.String())
+	}
+	if body["trace_id"] != traceID || body["error"] == "" {
+		t.Errorf("The error body should include the trace ID, got %v", body)
+	}
+
+	var span sdktrace.ReadOnlySpan
+	for _, s := range recorder.Ended() {
+		if s.SpanContext().TraceID().String() == traceID {
+			span = s
+		}
+	}
+	if span == nil {
+		t.Fatal("The request span should continue the caller's trace")
+	}
+	if span.Name() != "POST /api/like" || span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Unexpected request span %q with parent %s", span.Name(), span.Parent().SpanID())
+	}
+
+	w = doJSON(r, "GET", "/api/blocks", user1, nil)
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "trace_id") {
+		t.Errorf("Successful responses should be left as is, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestGormTracing(t *testing.T) {
+	db := testutil.DB(t)
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+	recorder := recordSpans(t)
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	var likes []models.Like
+	if err := db.WithContext(ctx).Where("user_id = ?", testutil.NewUserID()).Find(&likes).Error; err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	for _, s := range recorder.Ended() {
+		if s.Name() != "gorm.query" {
+			continue
+		}
+		if s.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Error("The query span should be a child of the request span")
+		}
+		for _, attr := range s.Attributes() {
+			if attr.Key == "db.query.text" && !strings.Contains(attr.Value.AsString(), "likes") {
+				t.Errorf("Unexpected statement %q", attr.Value.AsString())
+			}
+		}
+		return
+	}
+	t.Error("The query should be traced")
+}
@@ -0,0 +1,60 @@
+// Tests for message content validation and structured error responses.
+
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"way-d-interactions/validation"
+)
+
+func TestMessageContentRules(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  string
+		ok    bool
+	}{
+		{"trims whitespace", "  hello \r\n", "hello", true},
+		{"normalises to NFC", "cafe\u0301", "caf\u00e9", true},
+		{"keeps inner newlines", "hi\r\nthere", "hi\nthere", true},
+		{"rejects whitespace only", " \n\t ", "", false},
+		{"rejects control characters", "hi\x07", "", false},
+		{"rejects bidi overrides", "abc\u202edef", "", false},
+		{"rejects overlong content", strings.Repeat("é", validation.DefaultMaxMessageRunes+1), "", false},
+		{"counts runes not bytes", strings.Repeat("é", validation.DefaultMaxMessageRunes), strings.Repeat("é", validation.DefaultMaxMessageRunes), true},
+	}
+	for _, tc := range cases {
+		got, err := validation.MessageContent(tc.input)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("%s: got %q, %v", tc.name, got, err)
+		}
+	}
+}
+
+func TestStructuredValidationErrors(t *testing.T) {
+	r := setupMemoryRouter()
+	jwt := GenerateTestJWT("00000000-0000-0000-0000-000000000001")
+	body := `{"target_id": "not-a-uuid"}`
+	req, _ := http.NewRequest("POST", "/api/like", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Invalid target_id should be rejected: %d %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Error  string                  `json:"error"`
+		Fields []validation.FieldError `json:"fields"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Fields) != 1 || resp.Fields[0].Field != "target_id" {
+		t.Errorf("Expected a target_id field error, got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,47 @@
+// Package testutil is the shared harness of the service's tests: test
+// credentials, an isolated PostgreSQL schema per test and factories for the
+// common fixtures.
+package testutil
+
+import (
+	"os"
+	"time"
+
+	"way-d-interactions/config"
+	"way-d-interactions/middleware"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Credentials the harness configures the service with.
+const (
+	JWTSecret     = "e5b9922f19cf240b093a3e851f905bce71d8444b44c13d616c9c58bf2cbb8b78"
+	InternalToken = "test-internal-token"
+)
+
+// SetEnv points the service's configuration at the test credentials and a
+// temporary attachment directory.
+func SetEnv() {
+	os.Setenv("JWT_SECRET", JWTSecret)
+	os.Setenv("INTERNAL_API_TOKEN", InternalToken)
+	os.Setenv("ATTACHMENT_STORAGE_DIR", os.TempDir()+"/wayd-interactions-test-attachments")
+	config.Set(config.FromEnv())
+}
+
+// Token returns a JWT for userID, valid for an hour.
+func Token(userID string) string {
+	return TokenWithLocale(userID, "")
+}
+
+// TokenWithLocale returns a JWT for userID carrying a locale claim.
+func TokenWithLocale(userID, locale string) string {
+	claims := middleware.JWTClaims{
+		UserID: userID,
+		Locale: locale,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	signed, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(JWTSecret))
+	return signed
+}
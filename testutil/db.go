@@ -0,0 +1,178 @@
+package testutil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"way-d-interactions/config"
+	"way-d-interactions/migrations"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// errNoServer reports that no PostgreSQL could be started for the tests,
+// typically because the binaries cannot be downloaded.
+var errNoServer = errors.New("no test database")
+
+// server is the PostgreSQL instance shared by the tests of a package.
+var server struct {
+	once     sync.Once
+	url      string
+	err      error
+	embedded *embeddedpostgres.EmbeddedPostgres
+	dir      string
+}
+
+// serverURL returns the URL of TEST_DATABASE_URL when it is set, and
+// otherwise starts an embedded PostgreSQL on a free port. The embedded
+// server runs the binaries in TEST_POSTGRES_BINARIES (a directory holding
+// bin/pg_ctl) when set; otherwise they are downloaded on first use and
+// cached in ~/.embedded-postgres-go.
+func serverURL() (string, error) {
+	server.once.Do(func() {
+		if u := os.Getenv("TEST_DATABASE_URL"); u != "" {
+			server.url = u
+			return
+		}
+		port, err := freePort()
+		if err != nil {
+			server.err = err
+			return
+		}
+		if server.dir, err = os.MkdirTemp("", "wayd-interactions-pg-"); err != nil {
+			server.err = err
+			return
+		}
+		cfg := embeddedpostgres.DefaultConfig().
+			Version(embeddedpostgres.V16).
+			Port(port).
+			RuntimePath(filepath.Join(server.dir, "runtime")).
+			DataPath(filepath.Join(server.dir, "data")).
+			Logger(io.Discard)
+		if dir := os.Getenv("TEST_POSTGRES_BINARIES"); dir != "" {
+			cfg = cfg.BinariesPath(dir)
+		}
+		server.embedded = embeddedpostgres.NewDatabase(cfg)
+		if err := server.embedded.Start(); err != nil {
+			server.err = fmt.Errorf("%w: starting embedded PostgreSQL: %v", errNoServer, err)
+			server.embedded = nil
+			return
+		}
+		server.url = cfg.GetConnectionURL()
+	})
+	return server.url, server.err
+}
+
+func freePort() (uint32, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return uint32(l.Addr().(*net.TCPAddr).Port), nil
+}
+
+// Run runs the tests of a package and stops the embedded PostgreSQL
+// afterwards. Call it from TestMain:
+//
+//	func TestMain(m *testing.M) { os.Exit(testutil.Run(m)) }
+func Run(m *testing.M) int {
+	SetEnv()
+	code := m.Run()
+	if server.embedded != nil {
+		server.embedded.Stop()
+	}
+	if server.dir != "" {
+		os.RemoveAll(server.dir)
+	}
+	return code
+}
+
+// withSearchPath returns rawURL with search_path set to schema and TLS
+// disabled unless configured.
+func withSearchPath(rawURL, schema string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("TEST_DATABASE_URL must be a postgres:// URL: %w", err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	if q.Get("sslmode") == "" {
+		q.Set("sslmode", "disable")
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// DB returns a connection to a fresh schema holding the service's tables,
+// installs it as config.DB and drops the schema when t finishes. Each test
+// thus starts from empty tables and cannot see another test's rows.
+//
+// When no embedded server can be started, as offline without cached
+// binaries, the test is skipped; set TEST_DATABASE_URL or
+// TEST_POSTGRES_BINARIES to run it. A TEST_DATABASE_URL that cannot be
+// reached fails the test, or skips it with -short.
+func DB(t testing.TB) *gorm.DB {
+	t.Helper()
+	base, err := serverURL()
+	if errors.Is(err, errNoServer) {
+		t.Skipf("%v; set TEST_DATABASE_URL or TEST_POSTGRES_BINARIES to run database tests", err)
+	}
+	if err != nil {
+		t.Fatalf("no test database: %v", err)
+	}
+	admin, err := gorm.Open(postgres.Open(base), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		if testing.Short() {
+			t.Skipf("no test database: %v", err)
+		}
+		t.Fatalf("connecting to the test database: %v", err)
+	}
+	suffix := make([]byte, 6)
+	rand.Read(suffix)
+	schema := "test_" + hex.EncodeToString(suffix)
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("creating schema: %v", err)
+	}
+	dsn, err := withSearchPath(base, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connecting to schema %s: %v", schema, err)
+	}
+	previous := config.DB
+	config.DB = db
+	t.Cleanup(func() {
+		config.DB = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	m, err := migrations.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("migrating schema %s: %v", schema, err)
+	}
+	return db
+}
@@ -0,0 +1,84 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	"way-d-interactions/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// NewUserID returns the ID of a new user. Users live in another service, so
+// nothing is stored.
+func NewUserID() string {
+	return uuid.NewString()
+}
+
+// create stores v or fails t.
+func create(t testing.TB, db *gorm.DB, v interface{}) {
+	t.Helper()
+	if err := db.Create(v).Error; err != nil {
+		t.Fatalf("creating %T: %v", v, err)
+	}
+}
+
+// Like stores a like from userID to targetID.
+func Like(t testing.TB, db *gorm.DB, userID, targetID string) models.Like {
+	t.Helper()
+	like := models.Like{ID: uuid.New(), UserID: uuid.MustParse(userID), TargetID: uuid.MustParse(targetID), CreatedAt: time.Now()}
+	create(t, db, &like)
+	return like
+}
+
+// Dislike stores a dislike from userID to targetID.
+func Dislike(t testing.TB, db *gorm.DB, userID, targetID string) models.Dislike {
+	t.Helper()
+	dislike := models.Dislike{ID: uuid.New(), UserID: uuid.MustParse(userID), TargetID: uuid.MustParse(targetID), CreatedAt: time.Now()}
+	create(t, db, &dislike)
+	return dislike
+}
+
+// Match stores the likes of user1 then user2 and the match they led to, as
+// liking through the API would.
+func Match(t testing.TB, db *gorm.DB, user1, user2 string) models.Match {
+	t.Helper()
+	first := Like(t, db, user1, user2)
+	second := Like(t, db, user2, user1)
+	db.Model(&models.Like{}).Where("id IN ?", []uuid.UUID{first.ID, second.ID}).Update("match", true)
+	match := models.Match{
+		ID:               uuid.New(),
+		User1ID:          second.UserID,
+		User2ID:          second.TargetID,
+		CreatedAt:        second.CreatedAt,
+		InitiatorID:      &first.UserID,
+		InitiatorLikedAt: &first.CreatedAt,
+		ResponderLikedAt: &second.CreatedAt,
+		Source:           models.MatchSourceLike,
+	}
+	create(t, db, &match)
+	return match
+}
+
+// Message stores a message from senderID to the other participant of match.
+func Message(t testing.TB, db *gorm.DB, match models.Match, senderID, content string) models.Message {
+	t.Helper()
+	sender := uuid.MustParse(senderID)
+	receiver := match.User1ID
+	if receiver == sender {
+		receiver = match.User2ID
+	}
+	msg := models.Message{ID: uuid.New(), MatchID: match.ID, SenderID: sender, ReceiverID: receiver, Content: content, CreatedAt: time.Now()}
+	create(t, db, &msg)
+	db.Model(&models.Match{}).Where("id = ? AND first_message_at IS NULL", match.ID).Update("first_message_at", msg.CreatedAt)
+	return msg
+}
+
+// Block stores a block of blockedID by userID.
+func Block(t testing.TB, db *gorm.DB, userID, blockedID string) models.Block {
+	t.Helper()
+	block := models.Block{ID: uuid.New(), UserID: uuid.MustParse(userID), BlockedID: uuid.MustParse(blockedID), CreatedAt: time.Now()}
+	create(t, db, &block)
+	return block
+}
@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceIDHeader carries the trace ID of every traced response, so that a
+// client reporting an error can quote it.
+const TraceIDHeader = "X-Trace-Id"
+
+// Middleware starts a server span for each request, as a child of the trace
+// context in its traceparent header if any. The span is named after the
+// route pattern, and its context replaces the request's so that the stores
+// and GORM queries nest under it. Error responses with a JSON object body
+// also get a trace_id field.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		route := c.FullPath()
+		name := c.Request.Method + " " + route
+		if route == "" {
+			name = c.Request.Method
+		}
+		ctx, span := tracer().Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.URLPath(c.Request.URL.Path),
+			))
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		if traceID := TraceID(ctx); traceID != "" {
+			c.Header(TraceIDHeader, traceID)
+			c.Writer = &errorWriter{ResponseWriter: c.Writer, traceID: traceID}
+		}
+		c.Next()
+
+		status := c.Writer.Status()
+		if route != "" {
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		for _, err := range c.Errors {
+			span.RecordError(err.Err)
+		}
+	}
+}
+
+// errorWriter adds the trace ID to the JSON object written for an error
+// response, e.g. {"error":"..."} becomes {"trace_id":"...","error":"..."}.
+// Handlers write such bodies with c.JSON, in a single Write.
+type errorWriter struct {
+	gin.ResponseWriter
+	traceID string
+}
+
+func (w *errorWriter) Write(data []byte) (int, error) {
+	if w.Status() < http.StatusBadRequest || w.Written() ||
+		!strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") ||
+		!bytes.HasPrefix(data, []byte("{")) {
+		return w.ResponseWriter.Write(data)
+	}
+	field := `{"trace_id":` + strconv.Quote(w.traceID)
+	if !bytes.HasPrefix(bytes.TrimSpace(data[1:]), []byte("}")) {
+		field += ","
+	}
+	if _, err := w.ResponseWriter.Write(append([]byte(field), data[1:]...)); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (w *errorWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
@@ -0,0 +1,147 @@
+// Package validation normalises user input and reports field-level errors
+// in a format shared by every controller.
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"way-d-interactions/config"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/text/unicode/norm"
+)
+
+// FieldError describes why a single request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors is a list of field errors. It implements error so it can be
+// returned from validation helpers.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + " " + fe.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+// NewError returns an Errors holding a single field error.
+func NewError(field, message string) Errors {
+	return Errors{{Field: field, Message: message}}
+}
+
+func init() {
+	// Report JSON field names rather than Go struct field names.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return f.Name
+			}
+			return name
+		})
+	}
+}
+
+// FromBindError converts an error returned by gin's ShouldBind* helpers into
+// field errors.
+func FromBindError(err error) Errors {
+	var fieldErrs Errors
+	if errors.As(err, &fieldErrs) {
+		return fieldErrs
+	}
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		out := make(Errors, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			out = append(out, FieldError{Field: fe.Field(), Message: tagMessage(fe)})
+		}
+		return out
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return NewError(typeErr.Field, "must be of type "+typeErr.Type.String())
+	}
+	if errors.Is(err, io.EOF) {
+		return NewError("body", "is required")
+	}
+	return NewError("body", "is not valid JSON")
+}
+
+func tagMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "uuid", "uuid4":
+		return "must be a valid UUID"
+	case "max":
+		return "must be at most " + fe.Param() + " characters"
+	case "min":
+		return "must be at least " + fe.Param() + " characters"
+	case "oneof":
+		return "must be one of: " + fe.Param()
+	default:
+		return fmt.Sprintf("failed the %q check", fe.Tag())
+	}
+}
+
+// DefaultMaxMessageRunes is the default of MESSAGE_MAX_RUNES.
+const DefaultMaxMessageRunes = 2000
+
+// MaxMessageRunes returns the configured maximum message length in runes.
+func MaxMessageRunes() int {
+	return config.Get().Messages.MaxRunes
+}
+
+// Text normalises free text submitted in field and checks it against the
+// content rules:
+//   - the text is converted to Unicode NFC and CRLF line endings become LF;
+//   - leading and trailing whitespace is trimmed;
+//   - control characters other than newline and tab, and bidirectional
+//     override characters, are rejected;
+//   - the trimmed text must be non-empty and at most maxRunes runes long.
+func Text(field, raw string, maxRunes int) (string, error) {
+	if !utf8.ValidString(raw) {
+		return "", NewError(field, "must be valid UTF-8")
+	}
+	s := norm.NFC.String(raw)
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.TrimSpace(s)
+	for _, r := range s {
+		if (unicode.IsControl(r) && r != '\n' && r != '\t') || isBidiOverride(r) {
+			return "", NewError(field, "must not contain control characters")
+		}
+	}
+	if s == "" {
+		return "", NewError(field, "must not be empty")
+	}
+	if n := utf8.RuneCountInString(s); n > maxRunes {
+		return "", NewError(field, "must be at most "+strconv.Itoa(maxRunes)+" characters")
+	}
+	return s, nil
+}
+
+// MessageContent applies Text to a chat message's content field.
+func MessageContent(raw string) (string, error) {
+	return Text("content", raw, MaxMessageRunes())
+}
+
+func isBidiOverride(r rune) bool {
+	return (r >= '\u202a' && r <= '\u202e') || (r >= '\u2066' && r <= '\u2069')
+}
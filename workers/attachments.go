@@ -0,0 +1,88 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"way-d-interactions/config"
+	"way-d-interactions/models"
+	"way-d-interactions/storage"
+
+	"gorm.io/gorm"
+)
+
+// AttachmentJanitor deletes orphaned attachments: uploads never sent in a
+// message within MaxAge, unless a scheduled message still refers to them, and
+// attachments whose message no longer exists.
+type AttachmentJanitor struct {
+	DB        *gorm.DB
+	Storage   storage.Storage
+	MaxAge    time.Duration
+	Interval  time.Duration
+	BatchSize int
+}
+
+// NewAttachmentJanitor returns a janitor using the orphan TTL and cleanup
+// interval of cfg.
+func NewAttachmentJanitor(db *gorm.DB, store storage.Storage, cfg config.AttachmentsConfig) *AttachmentJanitor {
+	return &AttachmentJanitor{
+		DB:        db,
+		Storage:   store,
+		MaxAge:    cfg.OrphanTTL,
+		Interval:  cfg.CleanupInterval,
+		BatchSize: 100,
+	}
+}
+
+// Cleanup deletes orphaned attachments and their blobs and returns how many
+// it removed.
+func (j *AttachmentJanitor) Cleanup(ctx context.Context) (int, error) {
+	db := j.DB.WithContext(ctx)
+	cutoff := time.Now().Add(-j.MaxAge)
+	removed := 0
+	for {
+		var orphans []models.Attachment
+		err := db.Where(`(message_id IS NULL AND created_at < ?
+				AND NOT EXISTS (SELECT 1 FROM scheduled_messages WHERE scheduled_messages.attachment_id = attachments.id AND scheduled_messages.status = ?))
+			OR (message_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM messages WHERE messages.id = attachments.message_id))`,
+			cutoff, models.ScheduledStatusPending).
+			Limit(j.BatchSize).Find(&orphans).Error
+		if err != nil || len(orphans) == 0 {
+			return removed, err
+		}
+		for _, a := range orphans {
+			// Remove the blob first: a row without a blob is retried, a blob
+			// without a row would leak.
+			if err := j.Storage.Delete(ctx, a.StorageKey); err != nil {
+				return removed, err
+			}
+			if err := db.Delete(&a).Error; err != nil {
+				return removed, err
+			}
+			removed++
+		}
+		if len(orphans) < j.BatchSize {
+			return removed, nil
+		}
+	}
+}
+
+// Run cleans up once per Interval until ctx is cancelled.
+func (j *AttachmentJanitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+	for {
+		removed, err := j.Cleanup(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Attachment cleanup failed", "error", err)
+		} else if removed > 0 {
+			slog.Info("Attachment cleanup done", "removed", removed)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+)
+
+// Group runs the background goroutines of the service under one context, so
+// they can be stopped together on shutdown and watched by the readiness
+// probe.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	workers map[string]bool
+}
+
+// NewGroup returns a group whose goroutines run until parent is cancelled or
+// Shutdown is called.
+func NewGroup(parent context.Context) *Group {
+	ctx, cancel := context.WithCancel(parent)
+	return &Group{ctx: ctx, cancel: cancel, workers: make(map[string]bool)}
+}
+
+// Go runs the long-running worker name. It is expected to return only once
+// the group is stopped; until then Stopped reports it if it does.
+func (g *Group) Go(name string, run func(ctx context.Context)) {
+	g.mu.Lock()
+	g.workers[name] = true
+	g.mu.Unlock()
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		run(g.ctx)
+		g.mu.Lock()
+		g.workers[name] = false
+		g.mu.Unlock()
+		if g.ctx.Err() == nil {
+			slog.Error("Worker stopped unexpectedly", "worker", name)
+		}
+	}()
+}
+
+// Task runs a one-off job that shutdown waits for but readiness ignores.
+func (g *Group) Task(run func(ctx context.Context)) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		run(g.ctx)
+	}()
+}
+
+// Running reports each worker started with Go and whether it still runs.
+func (g *Group) Running() map[string]bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	running := make(map[string]bool, len(g.workers))
+	for name, r := range g.workers {
+		running[name] = r
+	}
+	return running
+}
+
+// Stopped returns the names of the workers that are no longer running, in
+// alphabetical order.
+func (g *Group) Stopped() []string {
+	var stopped []string
+	for name, running := range g.Running() {
+		if !running {
+			stopped = append(stopped, name)
+		}
+	}
+	sort.Strings(stopped)
+	return stopped
+}
+
+// Shutdown cancels the context of every goroutine and waits for them to
+// return, or for ctx to be done.
+func (g *Group) Shutdown(ctx context.Context) error {
+	g.cancel()
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,162 @@
+// Package workers contains the service's background jobs.
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"way-d-interactions/config"
+	"way-d-interactions/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RetentionPolicy configures which messages the purge job removes. A zero
+// duration disables the corresponding rule. Messages with an open report are
+// always kept.
+type RetentionPolicy struct {
+	// DeletedMessagesAfter purges soft-deleted messages this long after deletion.
+	DeletedMessagesAfter time.Duration
+	// UnmatchedConversationsAfter purges messages this old whose match no
+	// longer exists, or expired at least this long ago.
+	UnmatchedConversationsAfter time.Duration
+	// BatchSize is the maximum number of rows deleted per statement.
+	BatchSize int
+	// Interval is the time between two scheduled purges.
+	Interval time.Duration
+	// DryRun counts matching messages without deleting them.
+	DryRun bool
+}
+
+// RetentionPolicyFrom returns the policy configured by the RETENTION_*
+// settings.
+func RetentionPolicyFrom(cfg config.RetentionConfig) RetentionPolicy {
+	return RetentionPolicy{
+		DeletedMessagesAfter:        cfg.DeletedMessagesAfter,
+		UnmatchedConversationsAfter: cfg.UnmatchedConversationsAfter,
+		BatchSize:                   cfg.BatchSize,
+		Interval:                    cfg.PurgeInterval,
+		DryRun:                      cfg.DryRun,
+	}
+}
+
+// PurgeReport summarises one purge run.
+type PurgeReport struct {
+	DryRun                 bool          `json:"dry_run"`
+	DeletedMessages        int64         `json:"deleted_messages"`
+	UnmatchedConversations int64         `json:"unmatched_conversation_messages"`
+	Duration               time.Duration `json:"duration_ns"`
+}
+
+// Purger enforces a RetentionPolicy on the messages table.
+type Purger struct {
+	DB     *gorm.DB
+	Policy RetentionPolicy
+}
+
+const notReported = `NOT EXISTS (SELECT 1 FROM reports WHERE reports.message_id = messages.id AND reports.status = ?)`
+
+const noLiveMatch = `NOT EXISTS (
+	SELECT 1 FROM matches
+	WHERE matches.id = messages.match_id
+		AND (matches.expire_at IS NULL OR matches.expire_at > ?))`
+
+// Purge runs every enabled retention rule once.
+func (p *Purger) Purge(ctx context.Context) (PurgeReport, error) {
+	started := time.Now()
+	report := PurgeReport{DryRun: p.Policy.DryRun}
+	db := p.DB.WithContext(ctx)
+
+	if p.Policy.DeletedMessagesAfter > 0 {
+		cutoff := started.Add(-p.Policy.DeletedMessagesAfter)
+		q := db.Model(&models.Message{}).
+			Where("deleted = true AND COALESCE(deleted_at, created_at) < ?", cutoff).
+			Where(notReported, models.ReportStatusOpen)
+		n, err := p.apply(ctx, q)
+		report.DeletedMessages = n
+		if err != nil {
+			return report, err
+		}
+	}
+	if p.Policy.UnmatchedConversationsAfter > 0 {
+		cutoff := started.Add(-p.Policy.UnmatchedConversationsAfter)
+		q := db.Model(&models.Message{}).
+			Where("created_at < ?", cutoff).
+			Where(noLiveMatch, cutoff).
+			Where(notReported, models.ReportStatusOpen)
+		n, err := p.apply(ctx, q)
+		report.UnmatchedConversations = n
+		if err != nil {
+			return report, err
+		}
+	}
+	report.Duration = time.Since(started)
+	return report, nil
+}
+
+// apply counts the messages selected by q in dry-run mode and deletes them,
+// with their reactions, in batches otherwise.
+func (p *Purger) apply(ctx context.Context, q *gorm.DB) (int64, error) {
+	if p.Policy.DryRun {
+		var n int64
+		err := q.Count(&n).Error
+		return n, err
+	}
+	batchSize := p.Policy.BatchSize
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		var ids []uuid.UUID
+		if err := q.Session(&gorm.Session{}).Limit(batchSize).Pluck("id", &ids).Error; err != nil {
+			return total, err
+		}
+		if len(ids) == 0 {
+			return total, nil
+		}
+		err := p.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("message_id IN ?", ids).Delete(&models.Reaction{}).Error; err != nil {
+				return err
+			}
+			res := tx.Where("id IN ?", ids).Delete(&models.Message{})
+			total += res.RowsAffected
+			return res.Error
+		})
+		if err != nil {
+			return total, err
+		}
+		if len(ids) < batchSize {
+			return total, nil
+		}
+	}
+}
+
+// Run purges once per Policy.Interval until ctx is cancelled.
+func (p *Purger) Run(ctx context.Context) {
+	interval := p.Policy.Interval
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		report, err := p.Purge(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Retention purge failed", "error", err)
+		} else if err == nil {
+			slog.Info("Retention purge done", "dry_run", report.DryRun, "deleted_messages", report.DeletedMessages,
+				"unmatched_conversation_messages", report.UnmatchedConversations, "duration", report.Duration)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
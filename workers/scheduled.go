@@ -0,0 +1,164 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"way-d-interactions/config"
+	"way-d-interactions/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DeliverFunc sends a scheduled message using db. A non-empty failure is a
+// permanent reason it cannot be sent; err is a transient error and the
+// message is retried after a delay.
+type DeliverFunc func(ctx context.Context, db *gorm.DB, scheduled models.ScheduledMessage) (messageID uuid.UUID, failure string, err error)
+
+// maxRetryDelay bounds the wait between two attempts at a scheduled message.
+const maxRetryDelay = 24 * time.Hour
+
+// ScheduledDispatcher delivers scheduled messages once they are due.
+type ScheduledDispatcher struct {
+	DB        *gorm.DB
+	Deliver   DeliverFunc
+	Interval  time.Duration
+	BatchSize int
+	// RetryDelay is the wait after the first transient failure of a
+	// message; it doubles with every further one.
+	RetryDelay time.Duration
+	// MaxAttempts is the number of deliveries tried before a message is
+	// marked failed; 0 retries forever.
+	MaxAttempts int
+}
+
+// NewScheduledDispatcher returns a dispatcher configured by cfg.
+func NewScheduledDispatcher(db *gorm.DB, deliver DeliverFunc, cfg config.MessagesConfig) *ScheduledDispatcher {
+	return &ScheduledDispatcher{
+		DB:          db,
+		Deliver:     deliver,
+		Interval:    cfg.ScheduledDispatchInterval,
+		BatchSize:   100,
+		RetryDelay:  cfg.ScheduledRetryDelay,
+		MaxAttempts: cfg.ScheduledMaxAttempts,
+	}
+}
+
+// Dispatch delivers every due scheduled message and returns how many were
+// sent and how many failed permanently.
+//
+// Each message is delivered in its own transaction holding a row lock, so
+// concurrent dispatchers skip it and a cancellation waits for the outcome.
+// The message and the removal of the scheduled row commit together.
+// A message whose delivery fails with a transient error is skipped until
+// its next attempt is due, and marked failed after MaxAttempts.
+func (d *ScheduledDispatcher) Dispatch(ctx context.Context) (sent, failed int, err error) {
+	for processed := 0; processed < d.BatchSize; processed++ {
+		if err := ctx.Err(); err != nil {
+			return sent, failed, err
+		}
+		found := false
+		var scheduled models.ScheduledMessage
+		var failure string
+		var deliveryErr error
+		err := d.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			now := time.Now()
+			res := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+				Where("status = ? AND send_at <= ?", models.ScheduledStatusPending, now).
+				Where("next_attempt_at IS NULL OR next_attempt_at <= ?", now).
+				Order("send_at asc").Limit(1).Find(&scheduled)
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+			found = true
+			_, failure, deliveryErr = d.Deliver(ctx, tx, scheduled)
+			if deliveryErr != nil {
+				return deliveryErr
+			}
+			if failure == "" {
+				return tx.Delete(&scheduled).Error
+			}
+			return tx.Model(&scheduled).Updates(map[string]interface{}{
+				"status":         models.ScheduledStatusFailed,
+				"failure_reason": failure,
+				"updated_at":     time.Now(),
+			}).Error
+		})
+		if deliveryErr != nil && ctx.Err() == nil {
+			// The delivery was rolled back; record the attempt on its own.
+			if failure, err = d.retryLater(ctx, scheduled, deliveryErr); err != nil {
+				return sent, failed, err
+			}
+			if failure == "" {
+				continue
+			}
+		} else if err != nil {
+			return sent, failed, err
+		}
+		if !found {
+			return sent, failed, nil
+		}
+		if failure == "" {
+			sent++
+		} else {
+			failed++
+		}
+	}
+	return sent, failed, nil
+}
+
+// retryLater records a transient failure to deliver scheduled and when to try
+// again. It returns the failure reason once MaxAttempts is reached and the
+// message is marked failed.
+func (d *ScheduledDispatcher) retryLater(ctx context.Context, scheduled models.ScheduledMessage, deliveryErr error) (string, error) {
+	attempts := scheduled.Attempts + 1
+	now := time.Now()
+	updates := map[string]interface{}{"attempts": attempts, "updated_at": now}
+	failure := ""
+	if d.MaxAttempts > 0 && attempts >= d.MaxAttempts {
+		failure = fmt.Sprintf("delivery failed %d times: %v", attempts, deliveryErr)
+		updates["status"] = models.ScheduledStatusFailed
+		updates["failure_reason"] = failure
+		updates["next_attempt_at"] = nil
+	} else {
+		updates["next_attempt_at"] = now.Add(d.retryDelay(attempts))
+		slog.Warn("Scheduled message delivery failed, retrying later", "scheduled_id", scheduled.ID, "attempts", attempts, "error", deliveryErr)
+	}
+	// Only a row still pending is updated: it may have been cancelled since.
+	err := d.DB.WithContext(ctx).Model(&models.ScheduledMessage{}).
+		Where("id = ? AND status = ?", scheduled.ID, models.ScheduledStatusPending).
+		Updates(updates).Error
+	return failure, err
+}
+
+// retryDelay returns the wait after the given number of failed attempts.
+func (d *ScheduledDispatcher) retryDelay(attempts int) time.Duration {
+	delay := d.RetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// Run dispatches once per Interval until ctx is cancelled.
+func (d *ScheduledDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+	for {
+		sent, failed, err := d.Dispatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Scheduled message dispatch failed", "error", err)
+		} else if sent > 0 || failed > 0 {
+			slog.Info("Scheduled messages dispatched", "sent", sent, "failed", failed)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}